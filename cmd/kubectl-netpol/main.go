@@ -0,0 +1,10 @@
+// kubectl-netpol is netpoltool packaged as a kubectl plugin. Put it on the PATH and run `kubectl netpol eval ...`.
+package main
+
+import (
+	"github.com/cheriot/netpoltool/internal/cli"
+)
+
+func main() {
+	cli.Main("kubectl netpol")
+}
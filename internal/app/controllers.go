@@ -3,41 +3,124 @@ package app
 import (
 	"context"
 	"fmt"
+	"io"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 
+	"github.com/cheriot/netpoltool/internal/app/coverage"
+	"github.com/cheriot/netpoltool/internal/app/lint"
 	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/app/netpolgen"
+	"github.com/cheriot/netpoltool/internal/app/resolver"
 	"github.com/cheriot/netpoltool/internal/k8s"
+	"github.com/cheriot/netpoltool/internal/util"
 )
 
 type App struct {
-	k8sSession *k8s.K8sSession
+	cluster          k8s.Cluster
+	defaultNamespace string
+	resolver         resolver.Resolver
 }
 
-func NewApp(kubeconfig string) (*App, error) {
-	k8sSession, err := k8s.NewSession(kubeconfig)
+func NewApp(configFlags *genericclioptions.ConfigFlags) (*App, error) {
+	k8sSession, err := k8s.NewSession(configFlags)
 	if err != nil {
 		return nil, fmt.Errorf("error creating k8s session: %w", err)
 	}
 
+	return NewClusterApp(k8sSession, k8sSession.DefaultNamespace()), nil
+}
+
+// NewInClusterApp evaluates against the cluster netpoltool is deployed in, using its service account.
+func NewInClusterApp() (*App, error) {
+	k8sSession, err := k8s.NewInClusterSession()
+	if err != nil {
+		return nil, fmt.Errorf("error creating in-cluster k8s session: %w", err)
+	}
+
+	return NewClusterApp(k8sSession, k8sSession.DefaultNamespace()), nil
+}
+
+// NewSnapshotApp evaluates against a snapshot file written by WriteSnapshot instead of a live cluster.
+func NewSnapshotApp(snapshotPath string) (*App, error) {
+	snapshot, err := k8s.LoadSnapshot(snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading snapshot %s: %w", snapshotPath, err)
+	}
+
+	return NewClusterApp(snapshot, metav1.NamespaceDefault), nil
+}
+
+// NewClusterApp evaluates against any source of cluster state, most often an in memory Snapshot.
+func NewClusterApp(cluster k8s.Cluster, defaultNamespace string) *App {
 	return &App{
-		k8sSession: k8sSession,
-	}, nil
+		cluster:          cluster,
+		defaultNamespace: defaultNamespace,
+		resolver:         resolver.NetResolver{},
+	}
+}
+
+// Cached evaluates against informer caches of a's live cluster, for servers that evaluate on every request. A
+// snapshot is already in memory and is returned as is.
+func (a *App) Cached(ctx context.Context) (*App, error) {
+	session, ok := a.cluster.(*k8s.K8sSession)
+	if !ok {
+		return a, nil
+	}
+	cached, err := session.NewCachedCluster(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error caching the cluster: %w", err)
+	}
+
+	c := NewClusterApp(cached, a.defaultNamespace)
+	c.resolver = a.resolver
+	return c, nil
+}
+
+// SetResolver changes how hostname destinations are resolved, for example to a hosts file when evaluating a snapshot.
+func (a *App) SetResolver(r resolver.Resolver) {
+	a.resolver = r
+}
+
+// DefaultNamespace is used when the user does not specify a namespace. It comes from the kubeconfig context.
+func (a *App) DefaultNamespace() string {
+	return a.defaultNamespace
+}
+
+func (a *App) WriteSnapshot(w io.Writer, namespaces []string) error {
+	// The snapshot may later be used to evaluate connections with nodes
+	snapshot, err := a.TakeSnapshot(context.TODO(), namespaces, true)
+	if err != nil {
+		return err
+	}
+	return snapshot.Write(w)
+}
+
+// TakeSnapshot copies the cluster into memory. Nodes are only captured when requested.
+func (a *App) TakeSnapshot(ctx context.Context, namespaces []string, nodes bool) (*k8s.Snapshot, error) {
+	snapshot, err := k8s.TakeSnapshot(ctx, a.cluster, namespaces, nodes)
+	if err != nil {
+		return nil, fmt.Errorf("error taking snapshot: %w", err)
+	}
+	return snapshot, nil
 }
 
 func (a *App) queryConnectionSide(ctx context.Context, namespaceName, podName string, portNameOrNum string) (*eval.PodConnection, error) {
-	pod, err := a.k8sSession.QueryPod(ctx, namespaceName, podName)
+	pod, err := a.cluster.QueryPod(ctx, namespaceName, podName)
 	if err != nil {
 		return nil, fmt.Errorf("error querying pod %s %s: %w", namespaceName, podName, err)
 	}
 
-	namespace, err := a.k8sSession.QueryNamespace(ctx, namespaceName)
+	namespace, err := a.cluster.QueryNamespace(ctx, namespaceName)
 	if err != nil {
 		return nil, fmt.Errorf("error querying for namespace %s: %w", namespaceName, err)
 	}
 
-	netpolList, err := a.k8sSession.QueryNetPolList(ctx, namespaceName)
+	netpolList, err := a.cluster.QueryNetPolList(ctx, namespaceName)
 	if err != nil {
 		return nil, fmt.Errorf("error querying for netpol list %s: %w", namespace, err)
 	}
@@ -45,47 +128,221 @@ func (a *App) queryConnectionSide(ctx context.Context, namespaceName, podName st
 	return eval.NewPodConnection(pod, namespace, netpolList.Items, portNameOrNum)
 }
 
-func (a *App) CheckAccess(v ConsoleView,
-	namespaceName string,
-	podName string,
-	toNamespaceName string,
-	toPodName string,
-	toPortStr string,
-	toExternalIP string,
-	toProtocolName string) error {
+// EvalQuery identifies a connection from a pod to either another pod or an external IP.
+type EvalQuery struct {
+	Namespace    string
+	PodName      string
+	ToNamespace  string
+	ToPodName    string
+	ToPort       string
+	ToExternalIP string
+	ToProtocol   string
+	// ToNode is the name of a node, evaluated at each of its InternalIPs.
+	ToNode string
+	// ToAPIServer evaluates the addresses of the default/kubernetes Service.
+	ToAPIServer bool
+	// CheckDNS evaluates the source's connections to cluster DNS even when no egress policy selects it.
+	CheckDNS bool
+}
+
+// Evaluation is the result of evaluating an EvalQuery, along with the connection sides needed to explain it.
+type Evaluation struct {
+	Source  *eval.PodConnection
+	Dest    eval.ConnectionSide
+	Results []eval.PortResult
+	// Via explains how the destination was found when it is not what the user asked for, like the backend of a
+	// Service or the IP of a hostname.
+	Via string
+}
+
+func (e *Evaluation) AllowedCount() int {
+	return len(util.Filter(e.Results, func(pr eval.PortResult) bool { return pr.Allowed }))
+}
 
+// Verdict summarizes the ports of one or more evaluations.
+type Verdict uint8
+
+const (
+	VerdictAllowed Verdict = iota
+	VerdictPartiallyAllowed
+	VerdictDenied
+)
+
+// NewVerdict is allowed when every one of total ports is allowed and denied when none are. No ports is denied since
+// nothing can be reached.
+func NewVerdict(allowed, total int) Verdict {
+	switch {
+	case allowed == 0:
+		return VerdictDenied
+	case allowed < total:
+		return VerdictPartiallyAllowed
+	}
+	return VerdictAllowed
+}
+
+func (v Verdict) String() string {
+	return []string{"allowed", "partially allowed", "denied"}[v]
+}
+
+// Evaluate evaluates the connections q describes. A pod or an IP outside the cluster is a single evaluation. A
+// Service ClusterIP is evaluated against each of its backends, a hostname against each IP it resolves to, a node
+// against each InternalIP and the API server against each of its endpoints.
+func (a *App) Evaluate(ctx context.Context, q EvalQuery) ([]Evaluation, error) {
 	// UI layer should do user friendly validation. This can just error.
-	var err error
-	var dest eval.ConnectionSide
-	if toPodName != "" {
-		dest, err = a.queryConnectionSide(context.TODO(), toNamespaceName, toPodName, toPortStr)
-	} else if toExternalIP != "" {
-		dest, err = eval.NewExternalConnection(toExternalIP, toPortStr, toProtocolName)
-	} else {
-		return fmt.Errorf("no destination specified")
+	if q.ToPodName != "" {
+		dest, err := a.queryConnectionSide(ctx, q.ToNamespace, q.ToPodName, "")
+		if err != nil {
+			return nil, fmt.Errorf("error querying destination: %w", err)
+		}
+		if q.ToPort != "" {
+			dest, err = dest.ForPorts(q.ToPort, corev1.Protocol(strings.ToUpper(q.ToProtocol)))
+			if err != nil {
+				return nil, fmt.Errorf("invalid destination: %w", err)
+			}
+		}
+		source, err := a.queryConnectionSide(ctx, q.Namespace, q.PodName, "")
+		if err != nil {
+			return nil, fmt.Errorf("error querying source: %w", err)
+		}
+		return []Evaluation{{
+			Source:  source,
+			Dest:    dest,
+			Results: eval.Eval(source, dest),
+		}}, nil
 	}
-	if err != nil {
-		return fmt.Errorf("error querying destination: %w", err)
+	if q.ToExternalIP == "" && q.ToNode == "" && !q.ToAPIServer {
+		return nil, fmt.Errorf("no destination specified")
 	}
 
 	// TODO parallelize data access
-	source, err := a.queryConnectionSide(context.TODO(), namespaceName, podName, "")
+	source, err := a.queryConnectionSide(ctx, q.Namespace, q.PodName, "")
+	if err != nil {
+		return nil, fmt.Errorf("error querying source: %w", err)
+	}
+	if q.ToNode != "" {
+		return a.evaluateNode(ctx, source, q.ToNode, q.ToPort, q.ToProtocol)
+	}
+	if q.ToAPIServer {
+		return a.evaluateAPIServer(ctx, source, q.ToPort, q.ToProtocol)
+	}
+	return a.evaluateAddress(ctx, source, q.ToExternalIP, q.ToPort, q.ToProtocol)
+}
+
+// CheckAccess renders the evaluations q describes and summarizes every port of every destination. The error is only
+// for connections that could not be evaluated.
+func (a *App) CheckAccess(v ConsoleView, q EvalQuery) (Verdict, error) {
+	es, err := a.Evaluate(context.TODO(), q)
 	if err != nil {
-		return fmt.Errorf("error querying source: %w", err)
+		return VerdictDenied, err
+	}
+	if len(es) == 0 {
+		return VerdictDenied, fmt.Errorf("no addresses to evaluate for the destination")
+	}
+
+	allowed, total := 0, 0
+	for i, e := range es {
+		if e.Via != "" {
+			if i > 0 {
+				fmt.Fprintln(v.Writer)
+			}
+			fmt.Fprintf(v.Writer, "%s\n", e.Via)
+		}
+		RenderCheckAccess(v, e.Results, e.Source, e.Dest)
+		allowed += e.AllowedCount()
+		total += len(e.Results)
 	}
+	verdict := NewVerdict(allowed, total)
 
-	results := eval.Eval(source, dest)
-	return RenderCheckAccess(v, results, source, dest)
+	source := es[0].Source
+	if q.CheckDNS || IsSelectedByEgressPolicy(source) {
+		dns, dnsErr := a.CheckDNS(context.TODO(), source)
+		if dnsErr != nil && q.CheckDNS {
+			return verdict, dnsErr
+		}
+		if dnsErr != nil {
+			// The automatic check is advice and must not replace the verdict the user asked for
+			fmt.Fprintf(v.Writer, "\nWarning: unable to check DNS: %s\n", dnsErr)
+		} else {
+			RenderDNSCheck(v, dns, verdict != VerdictDenied, q.CheckDNS)
+		}
+	}
+
+	if len(es) == 1 {
+		e := es[0]
+		if e.AllowedCount() == 0 && len(e.Results) == 1 {
+			RenderSuggestions(v, netpolgen.Suggest(e.Source, e.Dest, e.Results[0]))
+		} else if e.AllowedCount() == 0 && len(e.Results) > 1 {
+			fmt.Fprintln(v.Writer, "Use --to-port with a single port to see changes that would allow the connection.")
+		}
+	}
+	return verdict, nil
+}
 
+// AllowedPorts computes every port of every protocol the source pod may connect to on the destination pod, not only
+// the ports the destination declares.
+func (a *App) AllowedPorts(ctx context.Context, q EvalQuery) (*eval.PodConnection, *eval.PodConnection, eval.PortSetResult, error) {
+	dest, err := a.queryConnectionSide(ctx, q.ToNamespace, q.ToPodName, "")
+	if err != nil {
+		return nil, nil, eval.PortSetResult{}, fmt.Errorf("error querying destination: %w", err)
+	}
+	source, err := a.queryConnectionSide(ctx, q.Namespace, q.PodName, "")
+	if err != nil {
+		return nil, nil, eval.PortSetResult{}, fmt.Errorf("error querying source: %w", err)
+	}
+	return source, dest, eval.EvalPortSet(source, dest), nil
+}
+
+// CheckAllPorts renders the ports the source may connect to on the destination. The verdict is allowed only when
+// every port of every protocol is.
+func (a *App) CheckAllPorts(v ConsoleView, q EvalQuery) (Verdict, error) {
+	source, dest, result, err := a.AllowedPorts(context.TODO(), q)
+	if err != nil {
+		return VerdictDenied, err
+	}
+	RenderPortSet(v, result, source, dest)
+	switch {
+	case result.Allowed.IsEmpty():
+		return VerdictDenied, nil
+	case eval.AllPorts().Subtract(result.Allowed).IsEmpty():
+		return VerdictAllowed, nil
+	}
+	return VerdictPartiallyAllowed, nil
+}
+
+// Coverage reports how well each namespace is isolated by NetworkPolicies. No namespaces means all namespaces.
+func (a *App) Coverage(ctx context.Context, namespaces []string) ([]coverage.NamespaceCoverage, error) {
+	var err error
+	if len(namespaces) == 0 {
+		namespaces, err = a.queryNamespaceNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	coverages := make([]coverage.NamespaceCoverage, 0, len(namespaces))
+	for _, namespaceName := range namespaces {
+		podList, err := a.cluster.QueryPodList(ctx, namespaceName)
+		if err != nil {
+			return nil, fmt.Errorf("error querying for pod list %s: %w", namespaceName, err)
+		}
+
+		netpolList, err := a.cluster.QueryNetPolList(ctx, namespaceName)
+		if err != nil {
+			return nil, fmt.Errorf("error querying for netpol list %s: %w", namespaceName, err)
+		}
+
+		coverages = append(coverages, coverage.Compute(namespaceName, podList.Items, netpolList.Items))
+	}
+	return coverages, nil
 }
 
 func (a *App) InspectEgress(namespace string, podName string) error {
-	pod, err := a.k8sSession.QueryPod(context.TODO(), namespace, podName)
+	pod, err := a.cluster.QueryPod(context.TODO(), namespace, podName)
 	if err != nil {
 		return fmt.Errorf("error from InspectEgress: %w", err)
 	}
 
-	netpolList, err := a.k8sSession.QueryNetPolList(context.TODO(), namespace)
+	netpolList, err := a.cluster.QueryNetPolList(context.TODO(), namespace)
 	matches := filterMatchingNetpols(netpolList, pod)
 	RenderNetPolMatch(matches)
 
@@ -103,3 +360,24 @@ func filterMatchingNetpols(netpolList *nwv1.NetworkPolicyList, pod *corev1.Pod)
 
 	return filteredNetPols
 }
+
+// Lint checks the policies of namespaces for rules that grant broad access. No namespaces means all namespaces.
+func (a *App) Lint(ctx context.Context, namespaces []string) ([]lint.Finding, error) {
+	var err error
+	if len(namespaces) == 0 {
+		namespaces, err = a.queryNamespaceNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	netpols := make([]nwv1.NetworkPolicy, 0)
+	for _, namespaceName := range namespaces {
+		netpolList, err := a.cluster.QueryNetPolList(ctx, namespaceName)
+		if err != nil {
+			return nil, fmt.Errorf("error querying for netpol list %s: %w", namespaceName, err)
+		}
+		netpols = append(netpols, netpolList.Items...)
+	}
+	return lint.Lint(netpols), nil
+}
@@ -0,0 +1,104 @@
+package coverage
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// NamespaceCoverage describes how much of a namespace is isolated by NetworkPolicies.
+type NamespaceCoverage struct {
+	Namespace string
+	Pods      []string
+	// Pods not selected by any policy of that type. Kubernetes allows all traffic of that direction to them.
+	PodsWithoutIngress []string
+	PodsWithoutEgress  []string
+	// Policies that select every pod and allow nothing, the usual default-deny pattern.
+	DefaultDenyIngress []string
+	DefaultDenyEgress  []string
+}
+
+func (c NamespaceCoverage) HasDefaultDenyIngress() bool {
+	return len(c.DefaultDenyIngress) > 0
+}
+
+func (c NamespaceCoverage) HasDefaultDenyEgress() bool {
+	return len(c.DefaultDenyEgress) > 0
+}
+
+// Score is the percentage of pods isolated in each direction, 0 to 100. A pod is isolated in a direction when a
+// policy of that type selects it, whether or not the policy's rules are strict. A namespace without pods scores by its
+// default-deny policies, which will isolate the pods created later.
+func (c NamespaceCoverage) Score() int {
+	if len(c.Pods) == 0 {
+		score := 0
+		if c.HasDefaultDenyIngress() {
+			score += 50
+		}
+		if c.HasDefaultDenyEgress() {
+			score += 50
+		}
+		return score
+	}
+	isolated := 2*len(c.Pods) - len(c.PodsWithoutIngress) - len(c.PodsWithoutEgress)
+	return isolated * 100 / (2 * len(c.Pods))
+}
+
+// Compute reports the coverage of the pods in one namespace by the policies of that namespace.
+func Compute(namespace string, pods []corev1.Pod, netpols []nwv1.NetworkPolicy) NamespaceCoverage {
+	c := NamespaceCoverage{
+		Namespace:          namespace,
+		Pods:               make([]string, 0, len(pods)),
+		PodsWithoutIngress: make([]string, 0),
+		PodsWithoutEgress:  make([]string, 0),
+		DefaultDenyIngress: make([]string, 0),
+		DefaultDenyEgress:  make([]string, 0),
+	}
+
+	for _, np := range netpols {
+		if IsDefaultDeny(np, nwv1.PolicyTypeIngress) {
+			c.DefaultDenyIngress = append(c.DefaultDenyIngress, np.Name)
+		}
+		if IsDefaultDeny(np, nwv1.PolicyTypeEgress) {
+			c.DefaultDenyEgress = append(c.DefaultDenyEgress, np.Name)
+		}
+	}
+
+	for _, pod := range pods {
+		c.Pods = append(c.Pods, pod.Name)
+		if !isSelected(pod, netpols, nwv1.PolicyTypeIngress) {
+			c.PodsWithoutIngress = append(c.PodsWithoutIngress, pod.Name)
+		}
+		if !isSelected(pod, netpols, nwv1.PolicyTypeEgress) {
+			c.PodsWithoutEgress = append(c.PodsWithoutEgress, pod.Name)
+		}
+	}
+
+	return c
+}
+
+// IsDefaultDeny is true when np selects all pods in its namespace and has no rules for policyType.
+func IsDefaultDeny(np nwv1.NetworkPolicy, policyType nwv1.PolicyType) bool {
+	if !util.Contains(np.Spec.PolicyTypes, policyType) {
+		return false
+	}
+	selector := np.Spec.PodSelector
+	if len(selector.MatchLabels)+len(selector.MatchExpressions) != 0 {
+		return false
+	}
+	if policyType == nwv1.PolicyTypeIngress {
+		return len(np.Spec.Ingress) == 0
+	}
+	return len(np.Spec.Egress) == 0
+}
+
+func isSelected(pod corev1.Pod, netpols []nwv1.NetworkPolicy, policyType nwv1.PolicyType) bool {
+	for _, np := range netpols {
+		if util.Contains(np.Spec.PolicyTypes, policyType) && eval.MatchLabelSelector(np.Spec.PodSelector, pod.Labels) {
+			return true
+		}
+	}
+	return false
+}
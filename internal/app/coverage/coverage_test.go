@@ -0,0 +1,78 @@
+package coverage
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCompute(t *testing.T) {
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "PodOne", Labels: map[string]string{"app": "one"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "PodTwo", Labels: map[string]string{"app": "two"}}},
+	}
+
+	Convey("No policies leaves every pod open", t, func() {
+		c := Compute("NamespaceOne", pods, nil)
+		So(c.PodsWithoutIngress, ShouldResemble, []string{"PodOne", "PodTwo"})
+		So(c.PodsWithoutEgress, ShouldResemble, []string{"PodOne", "PodTwo"})
+		So(c.HasDefaultDenyIngress(), ShouldBeFalse)
+		So(c.HasDefaultDenyEgress(), ShouldBeFalse)
+	})
+
+	Convey("A default-deny ingress policy covers every pod for ingress only", t, func() {
+		c := Compute("NamespaceOne", pods, []nwv1.NetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "DenyIngress"},
+			Spec:       nwv1.NetworkPolicySpec{PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress}},
+		}})
+		So(c.PodsWithoutIngress, ShouldBeEmpty)
+		So(c.PodsWithoutEgress, ShouldHaveLength, 2)
+		So(c.DefaultDenyIngress, ShouldResemble, []string{"DenyIngress"})
+		So(c.HasDefaultDenyEgress(), ShouldBeFalse)
+	})
+
+	Convey("A policy with rules or a pod selector is not default-deny", t, func() {
+		c := Compute("NamespaceOne", pods, []nwv1.NetworkPolicy{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "SelectOne"},
+				Spec: nwv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "one"}},
+					PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeEgress},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "AllowAll"},
+				Spec: nwv1.NetworkPolicySpec{
+					PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+					Ingress:     []nwv1.NetworkPolicyIngressRule{{}},
+				},
+			},
+		})
+		So(c.PodsWithoutEgress, ShouldResemble, []string{"PodTwo"})
+		So(c.PodsWithoutIngress, ShouldBeEmpty)
+		So(c.HasDefaultDenyIngress(), ShouldBeFalse)
+		So(c.HasDefaultDenyEgress(), ShouldBeFalse)
+	})
+}
+
+func TestScore(t *testing.T) {
+	Convey("Each pod counts once per direction", t, func() {
+		c := NamespaceCoverage{
+			Pods:               []string{"PodOne", "PodTwo"},
+			PodsWithoutIngress: []string{},
+			PodsWithoutEgress:  []string{"PodOne", "PodTwo"},
+		}
+		So(c.Score(), ShouldEqual, 50)
+
+		c.PodsWithoutEgress = []string{"PodTwo"}
+		So(c.Score(), ShouldEqual, 75)
+	})
+
+	Convey("A namespace without pods scores by its default-deny policies", t, func() {
+		So(NamespaceCoverage{}.Score(), ShouldEqual, 0)
+		So(NamespaceCoverage{DefaultDenyIngress: []string{"DenyIngress"}}.Score(), ShouldEqual, 50)
+	})
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+// evaluateAddress evaluates source connecting to an IP or hostname given as --to-ext-ip.
+func (a *App) evaluateAddress(ctx context.Context, source *eval.PodConnection, address, port, protocol string) ([]Evaluation, error) {
+	if net.ParseIP(address) != nil {
+		return a.evaluateIP(ctx, source, address, port, protocol, "")
+	}
+
+	ips, err := a.resolver.LookupHost(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	evaluations := make([]Evaluation, 0, len(ips))
+	for _, ip := range ips {
+		es, err := a.evaluateIP(ctx, source, ip, port, protocol, fmt.Sprintf("%s resolves to %s", address, ip))
+		if err != nil {
+			return nil, err
+		}
+		evaluations = append(evaluations, es...)
+	}
+	return evaluations, nil
+}
+
+// evaluateIP evaluates an IP outside the cluster, or the backends of the Service when it is a ClusterIP. Policies
+// apply after the ClusterIP is translated to a backend so the ClusterIP itself never matches an ipBlock.
+func (a *App) evaluateIP(ctx context.Context, source *eval.PodConnection, ip, port, protocol, via string) ([]Evaluation, error) {
+	svc, err := a.queryServiceByClusterIP(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	if svc != nil {
+		serviceVia := fmt.Sprintf("%s is the ClusterIP of Service %s/%s", ip, svc.Namespace, svc.Name)
+		if via != "" {
+			serviceVia = via + ", " + serviceVia
+		}
+		return a.evaluateService(ctx, source, svc, port, protocol, serviceVia)
+	}
+
+	dest, err := eval.NewExternalConnection(ip, port, protocol)
+	if err != nil {
+		return nil, fmt.Errorf("error querying destination: %w", err)
+	}
+	return []Evaluation{{
+		Source:  source,
+		Dest:    dest,
+		Results: eval.Eval(source, dest),
+		Via:     via,
+	}}, nil
+}
+
+// evaluateService evaluates each ready backend of svc on the target ports of the service port named or numbered
+// port. An empty port means every service port.
+func (a *App) evaluateService(ctx context.Context, source *eval.PodConnection, svc *corev1.Service, port, protocol, via string) ([]Evaluation, error) {
+	servicePorts, err := selectServicePorts(svc, port, protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints, err := a.cluster.QueryEndpoints(ctx, svc.Namespace, svc.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error querying endpoints of Service %s/%s: %w", svc.Namespace, svc.Name, err)
+	}
+
+	evaluations := make([]Evaluation, 0)
+	for _, subset := range endpoints.Subsets {
+		targetPorts := selectTargetPorts(subset, servicePorts)
+		if len(targetPorts) == 0 {
+			continue
+		}
+
+		for _, address := range subset.Addresses {
+			dest, results, err := a.evaluateBackend(ctx, source, address, targetPorts)
+			if err != nil {
+				return nil, err
+			}
+			evaluations = append(evaluations, Evaluation{
+				Source:  source,
+				Dest:    dest,
+				Results: results,
+				Via:     fmt.Sprintf("%s, backend %s", via, address.IP),
+			})
+		}
+	}
+	if len(evaluations) == 0 {
+		return nil, fmt.Errorf("Service %s/%s has no ready backends for port %s", svc.Namespace, svc.Name, port)
+	}
+	return evaluations, nil
+}
+
+// selectServicePorts finds the ports of svc named or numbered port. An empty port means every service port.
+func selectServicePorts(svc *corev1.Service, port, protocol string) ([]corev1.ServicePort, error) {
+	servicePorts := make([]corev1.ServicePort, 0)
+	for _, sp := range svc.Spec.Ports {
+		if port != "" && sp.Name != port && strconv.Itoa(int(sp.Port)) != port {
+			continue
+		}
+		if protocol != "" && !strings.EqualFold(string(eval.ProtocolOrTCP(sp.Protocol)), protocol) {
+			continue
+		}
+		servicePorts = append(servicePorts, sp)
+	}
+	if len(servicePorts) == 0 {
+		return nil, fmt.Errorf("Service %s/%s has no %s port %s", svc.Namespace, svc.Name, protocol, port)
+	}
+	return servicePorts, nil
+}
+
+// selectTargetPorts finds the ports of subset that implement servicePorts.
+func selectTargetPorts(subset corev1.EndpointSubset, servicePorts []corev1.ServicePort) []corev1.EndpointPort {
+	targetPorts := make([]corev1.EndpointPort, 0, len(servicePorts))
+	for _, sp := range servicePorts {
+		for _, ep := range subset.Ports {
+			// Endpoints ports are named after the service port they implement
+			if ep.Name == sp.Name && eval.ProtocolOrTCP(ep.Protocol) == eval.ProtocolOrTCP(sp.Protocol) {
+				targetPorts = append(targetPorts, ep)
+			}
+		}
+	}
+	return targetPorts
+}
+
+// evaluateBackend evaluates one address of a Service's Endpoints. Addresses that are not pods, like the endpoints of a
+// Service without a selector, are evaluated as IPs outside the cluster.
+func (a *App) evaluateBackend(ctx context.Context, source eval.ConnectionSide, address corev1.EndpointAddress, ports []corev1.EndpointPort) (eval.ConnectionSide, []eval.PortResult, error) {
+	results := make([]eval.PortResult, 0, len(ports))
+	if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+		pod, err := a.queryConnectionSide(ctx, address.TargetRef.Namespace, address.TargetRef.Name, "")
+		if err != nil {
+			return nil, nil, fmt.Errorf("error querying backend: %w", err)
+		}
+		for _, p := range ports {
+			results = append(results, eval.Eval(source, pod.ForPort(p.Port, eval.ProtocolOrTCP(p.Protocol)))...)
+		}
+		return pod, results, nil
+	}
+
+	var dest eval.ConnectionSide
+	for _, p := range ports {
+		external, err := eval.NewExternalConnection(address.IP, strconv.Itoa(int(p.Port)), string(p.Protocol))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error querying backend: %w", err)
+		}
+		dest = external
+		results = append(results, eval.Eval(source, external)...)
+	}
+	return dest, results, nil
+}
+
+// evaluateNode evaluates source connecting to each InternalIP of the node named nodeName. Only egress ipBlock rules can
+// select a node.
+func (a *App) evaluateNode(ctx context.Context, source *eval.PodConnection, nodeName, port, protocol string) ([]Evaluation, error) {
+	nodeList, err := a.cluster.QueryNodeList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying for nodes: %w", err)
+	}
+
+	evaluations := make([]Evaluation, 0)
+	for _, node := range nodeList.Items {
+		if node.Name != nodeName {
+			continue
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type != corev1.NodeInternalIP {
+				continue
+			}
+			dest, err := eval.NewExternalConnection(address.Address, port, protocol)
+			if err != nil {
+				return nil, fmt.Errorf("error querying destination: %w", err)
+			}
+			via := fmt.Sprintf("InternalIP %s of node %s", address.Address, nodeName)
+			if source.Pod.Spec.NodeName == nodeName {
+				// "traffic to and from the node where a Pod is running is always allowed"
+				via += ", the node of the source pod so Kubernetes allows it regardless of policy"
+			}
+			evaluations = append(evaluations, Evaluation{
+				Source:  source,
+				Dest:    dest,
+				Results: eval.Eval(source, dest),
+				Via:     via,
+			})
+		}
+		if len(evaluations) == 0 {
+			return nil, fmt.Errorf("node %s has no InternalIP", nodeName)
+		}
+		return evaluations, nil
+	}
+	return nil, fmt.Errorf("unable to find node %s", nodeName)
+}
+
+// evaluateAPIServer evaluates source connecting to the API server through the default/kubernetes Service. Its
+// Endpoints are the API server's addresses, which are not pods, so only egress ipBlock rules can select them.
+func (a *App) evaluateAPIServer(ctx context.Context, source *eval.PodConnection, port, protocol string) ([]Evaluation, error) {
+	svc, err := a.queryService(ctx, metav1.NamespaceDefault, "kubernetes")
+	if err != nil {
+		return nil, fmt.Errorf("error querying for the API server: %w", err)
+	}
+	return a.evaluateService(ctx, source, svc, port, protocol, "API server")
+}
+
+// queryServiceByClusterIP finds the Service in any namespace with the ClusterIP ip, or nil if there isn't one.
+func (a *App) queryServiceByClusterIP(ctx context.Context, ip string) (*corev1.Service, error) {
+	serviceList, err := a.cluster.QueryServiceList(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("error querying for services: %w", err)
+	}
+	for i, svc := range serviceList.Items {
+		if svc.Spec.ClusterIP == ip {
+			return &serviceList.Items[i], nil
+		}
+		for _, clusterIP := range svc.Spec.ClusterIPs {
+			if clusterIP == ip {
+				return &serviceList.Items[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/cheriot/netpoltool/internal/app/resolver"
+	"github.com/cheriot/netpoltool/internal/k8s"
+)
+
+func TestEvaluateAddress(t *testing.T) {
+	newPod := func(name, ip, app string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "NamespaceOne", Labels: map[string]string{"app": app}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+			}}},
+			Status: corev1.PodStatus{PodIP: ip},
+		}
+	}
+	snapshot := &k8s.Snapshot{
+		Version:    k8s.SnapshotVersion,
+		Namespaces: []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "NamespaceOne"}}},
+		Pods: []corev1.Pod{
+			newPod("Client", "10.0.0.1", "client"),
+			newPod("WebOne", "10.0.0.2", "web"),
+			newPod("WebTwo", "10.0.0.3", "locked"),
+		},
+		NetworkPolicies: []nwv1.NetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "DenyLocked", Namespace: "NamespaceOne"},
+			Spec: nwv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "locked"}},
+				PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+			},
+		}},
+		Services: []corev1.Service{{
+			ObjectMeta: metav1.ObjectMeta{Name: "Web", Namespace: "NamespaceOne"},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: "10.96.0.10",
+				Ports:     []corev1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromString("http"), Protocol: corev1.ProtocolTCP}},
+			},
+		}},
+		Endpoints: []corev1.Endpoints{{
+			ObjectMeta: metav1.ObjectMeta{Name: "Web", Namespace: "NamespaceOne"},
+			Subsets: []corev1.EndpointSubset{{
+				Addresses: []corev1.EndpointAddress{
+					{IP: "10.0.0.2", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "NamespaceOne", Name: "WebOne"}},
+					{IP: "10.0.0.3", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "NamespaceOne", Name: "WebTwo"}},
+				},
+				Ports: []corev1.EndpointPort{{Name: "http", Port: 8080, Protocol: corev1.ProtocolTCP}},
+			}},
+		}},
+	}
+	a := NewClusterApp(snapshot, "NamespaceOne")
+	hosts, err := resolver.ReadHosts(strings.NewReader("10.96.0.10 web.internal\n1.2.3.4 example.com\n"))
+	if err != nil {
+		panic(err.Error())
+	}
+	a.SetResolver(hosts)
+	query := func(address, port string) EvalQuery {
+		return EvalQuery{Namespace: "NamespaceOne", PodName: "Client", ToExternalIP: address, ToPort: port, ToProtocol: "tcp"}
+	}
+
+	Convey("A ClusterIP is evaluated against each backend on the target port", t, func() {
+		es, err := a.Evaluate(context.TODO(), query("10.96.0.10", "80"))
+		So(err, ShouldBeNil)
+		So(es, ShouldHaveLength, 2)
+
+		So(es[0].Dest.GetName(), ShouldEqual, "NamespaceOne/WebOne")
+		So(es[0].Results, ShouldHaveLength, 1)
+		So(es[0].Results[0].ToPort.Num, ShouldEqual, 8080)
+		So(es[0].Results[0].ToPort.Name, ShouldEqual, "http")
+		So(es[0].AllowedCount(), ShouldEqual, 1)
+		So(es[0].Via, ShouldEqual, "10.96.0.10 is the ClusterIP of Service NamespaceOne/Web, backend 10.0.0.2")
+
+		So(es[1].Dest.GetName(), ShouldEqual, "NamespaceOne/WebTwo")
+		So(es[1].AllowedCount(), ShouldEqual, 0)
+	})
+
+	Convey("Service ports may be given by name", t, func() {
+		es, err := a.Evaluate(context.TODO(), query("10.96.0.10", "http"))
+		So(err, ShouldBeNil)
+		So(es, ShouldHaveLength, 2)
+
+		_, err = a.Evaluate(context.TODO(), query("10.96.0.10", "443"))
+		So(err, ShouldBeError, "Service NamespaceOne/Web has no tcp port 443")
+	})
+
+	Convey("Hostnames are resolved and the IP shown", t, func() {
+		es, err := a.Evaluate(context.TODO(), query("example.com", "443"))
+		So(err, ShouldBeNil)
+		So(es, ShouldHaveLength, 1)
+		So(es[0].Dest.GetName(), ShouldEqual, "1.2.3.4:443")
+		So(es[0].Via, ShouldEqual, "example.com resolves to 1.2.3.4")
+
+		es, err = a.Evaluate(context.TODO(), query("web.internal", "80"))
+		So(err, ShouldBeNil)
+		So(es, ShouldHaveLength, 2)
+		So(es[0].Via, ShouldStartWith, "web.internal resolves to 10.96.0.10, 10.96.0.10 is the ClusterIP")
+
+		_, err = a.Evaluate(context.TODO(), query("missing.example.com", "80"))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestEvaluateNodeAndAPIServer(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	snapshot := &k8s.Snapshot{
+		Version: k8s.SnapshotVersion,
+		Namespaces: []corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "NamespaceOne"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		},
+		Nodes: []corev1.Node{{
+			ObjectMeta: metav1.ObjectMeta{Name: "NodeOne"},
+			Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: "node-one"},
+				{Type: corev1.NodeInternalIP, Address: "192.168.0.1"},
+			}},
+		}},
+		Pods: []corev1.Pod{{
+			ObjectMeta: metav1.ObjectMeta{Name: "Operator", Namespace: "NamespaceOne", Labels: map[string]string{"app": "operator"}},
+			Spec:       corev1.PodSpec{NodeName: "NodeTwo"},
+			Status:     corev1.PodStatus{PodIP: "10.0.0.1"},
+		}},
+		NetworkPolicies: []nwv1.NetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "AllowAPIServer", Namespace: "NamespaceOne"},
+			Spec: nwv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeEgress},
+				Egress: []nwv1.NetworkPolicyEgressRule{{
+					To:    []nwv1.NetworkPolicyPeer{{IPBlock: &nwv1.IPBlock{CIDR: "172.16.0.0/24"}}},
+					Ports: []nwv1.NetworkPolicyPort{{Protocol: &tcp, Port: &intstr.IntOrString{IntVal: 6443}}},
+				}},
+			},
+		}},
+		Services: []corev1.Service{{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "default"},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: "10.96.0.1",
+				Ports:     []corev1.ServicePort{{Name: "https", Port: 443, TargetPort: intstr.FromInt(6443), Protocol: corev1.ProtocolTCP}},
+			},
+		}},
+		Endpoints: []corev1.Endpoints{{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "default"},
+			Subsets: []corev1.EndpointSubset{{
+				Addresses: []corev1.EndpointAddress{{IP: "172.16.0.10"}, {IP: "172.16.1.10"}},
+				Ports:     []corev1.EndpointPort{{Name: "https", Port: 6443, Protocol: corev1.ProtocolTCP}},
+			}},
+		}},
+	}
+	a := NewClusterApp(snapshot, "NamespaceOne")
+
+	Convey("The API server is evaluated at each endpoint by egress ipBlock", t, func() {
+		es, err := a.Evaluate(context.TODO(), EvalQuery{Namespace: "NamespaceOne", PodName: "Operator", ToAPIServer: true})
+		So(err, ShouldBeNil)
+		So(es, ShouldHaveLength, 2)
+		So(es[0].Dest.GetName(), ShouldEqual, "172.16.0.10:6443")
+		So(es[0].AllowedCount(), ShouldEqual, 1)
+		So(es[1].Dest.GetName(), ShouldEqual, "172.16.1.10:6443")
+		So(es[1].AllowedCount(), ShouldEqual, 0)
+	})
+
+	Convey("A node is evaluated at its InternalIP", t, func() {
+		es, err := a.Evaluate(context.TODO(), EvalQuery{Namespace: "NamespaceOne", PodName: "Operator", ToNode: "NodeOne", ToPort: "10250"})
+		So(err, ShouldBeNil)
+		So(es, ShouldHaveLength, 1)
+		So(es[0].Dest.GetName(), ShouldEqual, "192.168.0.1:10250")
+		So(es[0].Via, ShouldEqual, "InternalIP 192.168.0.1 of node NodeOne")
+		So(es[0].AllowedCount(), ShouldEqual, 0)
+
+		_, err = a.Evaluate(context.TODO(), EvalQuery{Namespace: "NamespaceOne", PodName: "Operator", ToNode: "NodeThree", ToPort: "10250"})
+		So(err, ShouldBeError, "unable to find node NodeThree")
+	})
+	Convey("A hostname without addresses is an error rather than a verdict", t, func() {
+		empty := NewClusterApp(a.cluster, "NamespaceOne")
+		empty.SetResolver(noAddresses{})
+		_, err := empty.CheckAccess(ConsoleView{Writer: bufio.NewWriter(io.Discard)}, EvalQuery{Namespace: "NamespaceOne", PodName: "Operator", ToExternalIP: "nothing.internal", ToPort: "443", ToProtocol: "tcp"})
+		So(err, ShouldBeError, "no addresses to evaluate for the destination")
+	})
+}
+
+// noAddresses resolves every host to nothing, as a name with only records of another type can.
+type noAddresses struct{}
+
+func (noAddresses) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, nil
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+const (
+	dnsNamespace = "kube-system"
+	// dnsService is the name of the Service in front of both kube-dns and CoreDNS.
+	dnsService = "kube-dns"
+	// dnsLabel is set on the pods of both kube-dns and CoreDNS, for clusters without the Service.
+	dnsLabel = "k8s-app"
+	dnsValue = "kube-dns"
+	dnsPort  = 53
+)
+
+// DNSCheck is the evaluation of a pod's connections to the cluster DNS pods on port 53.
+type DNSCheck struct {
+	// Evaluations has one per DNS pod with results for UDP and TCP.
+	Evaluations []Evaluation
+	// Selector found the DNS pods, like k8s-app=kube-dns.
+	Selector string
+}
+
+// Allowed is true when at least one DNS pod can be reached with protocol. Resolvers use UDP and fall back to TCP for
+// large responses.
+func (c DNSCheck) Allowed(protocol corev1.Protocol) bool {
+	for _, e := range c.Evaluations {
+		for _, pr := range e.Results {
+			if pr.ToPort.Protocol == protocol && pr.Allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsSelectedByEgressPolicy is true when a policy restricts the pod's egress, which makes it likely DNS is blocked.
+func IsSelectedByEgressPolicy(pod *eval.PodConnection) bool {
+	for _, np := range pod.GetPolicies() {
+		if util.Contains(np.Spec.PolicyTypes, nwv1.PolicyTypeEgress) && pod.MatchPodSelector(np.Spec.PodSelector) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckDNS evaluates source connecting to each cluster DNS pod on UDP and TCP 53. DNS pods are selected by the
+// kube-system/kube-dns Service, or labeled k8s-app=kube-dns in kube-system when there is no such Service. It returns
+// nil when there are no DNS pods.
+func (a *App) CheckDNS(ctx context.Context, source *eval.PodConnection) (*DNSCheck, error) {
+	podList, err := a.cluster.QueryPodList(ctx, dnsNamespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(podList.Items) == 0 {
+		// Also the case when kube-system is not in a snapshot
+		util.Log.Debugf("No pods in %s", dnsNamespace)
+		return nil, nil
+	}
+
+	selector, err := a.queryDNSSelector(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dnsPods, _, err := a.queryPodConnections(ctx, []string{dnsNamespace})
+	if err != nil {
+		return nil, err
+	}
+	dnsPods = util.Filter(dnsPods, func(p *eval.PodConnection) bool { return selector.Matches(labels.Set(p.Pod.Labels)) })
+	if len(dnsPods) == 0 {
+		util.Log.Debugf("No pods match %s in %s", selector, dnsNamespace)
+		return nil, nil
+	}
+
+	check := &DNSCheck{Selector: selector.String()}
+	for _, dnsPod := range dnsPods {
+		e := Evaluation{Source: source, Dest: dnsPod}
+		for _, protocol := range []corev1.Protocol{corev1.ProtocolUDP, corev1.ProtocolTCP} {
+			e.Results = append(e.Results, eval.Eval(source, dnsPod.ForPort(dnsPort, protocol))...)
+		}
+		check.Evaluations = append(check.Evaluations, e)
+	}
+	return check, nil
+}
+
+// queryDNSSelector is the selector of the kube-dns Service, which managed clusters may point at pods labeled
+// differently than k8s-app=kube-dns.
+func (a *App) queryDNSSelector(ctx context.Context) (labels.Selector, error) {
+	serviceList, err := a.cluster.QueryServiceList(ctx, dnsNamespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range serviceList.Items {
+		if svc.Name == dnsService && len(svc.Spec.Selector) > 0 {
+			return labels.SelectorFromSet(svc.Spec.Selector), nil
+		}
+	}
+	util.Log.Debugf("No Service %s with a selector in %s", dnsService, dnsNamespace)
+	return labels.SelectorFromSet(labels.Set{dnsLabel: dnsValue}), nil
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cheriot/netpoltool/internal/k8s"
+)
+
+// failingServices cannot list Services, as during an API server outage.
+type failingServices struct {
+	*k8s.Snapshot
+}
+
+func (f *failingServices) QueryServiceList(ctx context.Context, namespace string) (*corev1.ServiceList, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestCheckDNS(t *testing.T) {
+	newSnapshot := func(egress []nwv1.NetworkPolicyEgressRule) *k8s.Snapshot {
+		return &k8s.Snapshot{
+			Version: k8s.SnapshotVersion,
+			Namespaces: []corev1.Namespace{
+				{ObjectMeta: metav1.ObjectMeta{Name: "NamespaceOne"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", Labels: map[string]string{"kubernetes.io/metadata.name": "kube-system"}}},
+			},
+			Pods: []corev1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "PodOne", Namespace: "NamespaceOne"},
+					Status:     corev1.PodStatus{PodIP: "10.0.0.1"},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system", Labels: map[string]string{"k8s-app": "kube-dns"}},
+					Status:     corev1.PodStatus{PodIP: "10.0.0.53"},
+				},
+			},
+			NetworkPolicies: []nwv1.NetworkPolicy{{
+				ObjectMeta: metav1.ObjectMeta{Name: "Egress", Namespace: "NamespaceOne"},
+				Spec: nwv1.NetworkPolicySpec{
+					PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeEgress},
+					Egress:      egress,
+				},
+			}},
+		}
+	}
+	check := func(snapshot *k8s.Snapshot) *DNSCheck {
+		a := NewClusterApp(snapshot, "NamespaceOne")
+		source, err := a.queryConnectionSide(context.TODO(), "NamespaceOne", "PodOne", "")
+		So(err, ShouldBeNil)
+		So(IsSelectedByEgressPolicy(source), ShouldBeTrue)
+		dns, err := a.CheckDNS(context.TODO(), source)
+		So(err, ShouldBeNil)
+		return dns
+	}
+
+	Convey("Default-deny egress blocks DNS", t, func() {
+		dns := check(newSnapshot(nil))
+		So(dns.Evaluations, ShouldHaveLength, 1)
+		So(dns.Allowed(corev1.ProtocolUDP), ShouldBeFalse)
+		So(dns.Allowed(corev1.ProtocolTCP), ShouldBeFalse)
+	})
+
+	Convey("Allowing UDP 53 to kube-system leaves TCP blocked", t, func() {
+		udp := corev1.ProtocolUDP
+		dns := check(newSnapshot([]nwv1.NetworkPolicyEgressRule{{
+			To: []nwv1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "kube-system"}},
+			}},
+			Ports: []nwv1.NetworkPolicyPort{{Protocol: &udp}},
+		}}))
+		So(dns.Allowed(corev1.ProtocolUDP), ShouldBeTrue)
+		So(dns.Allowed(corev1.ProtocolTCP), ShouldBeFalse)
+	})
+
+	Convey("DNS pods are found through the selector of the kube-dns Service", t, func() {
+		snapshot := newSnapshot(nil)
+		snapshot.Pods[1].Labels = map[string]string{"app": "managed-dns"}
+		snapshot.Services = []corev1.Service{{
+			ObjectMeta: metav1.ObjectMeta{Name: "kube-dns", Namespace: "kube-system"},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "managed-dns"}},
+		}}
+		dns := check(snapshot)
+		So(dns.Evaluations, ShouldHaveLength, 1)
+		So(dns.Evaluations[0].Dest.GetName(), ShouldEqual, "kube-system/coredns")
+		So(dns.Selector, ShouldEqual, "app=managed-dns")
+	})
+
+	Convey("A failed automatic DNS check warns without replacing the verdict", t, func() {
+		a := NewClusterApp(&failingServices{newSnapshot(nil)}, "NamespaceOne")
+		buf := &bytes.Buffer{}
+		v := ConsoleView{Writer: bufio.NewWriter(buf)}
+		q := EvalQuery{Namespace: "NamespaceOne", PodName: "PodOne", ToNamespace: "kube-system", ToPodName: "coredns"}
+
+		verdict, err := a.CheckAccess(v, q)
+		v.Flush()
+		So(err, ShouldBeNil)
+		So(verdict, ShouldEqual, VerdictDenied)
+		So(buf.String(), ShouldContainSubstring, "Warning: unable to check DNS")
+
+		q.CheckDNS = true
+		_, err = a.CheckAccess(v, q)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("No DNS pods is not an error", t, func() {
+		snapshot := newSnapshot(nil)
+		snapshot.Pods = snapshot.Pods[:1]
+		So(check(snapshot), ShouldBeNil)
+	})
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	nwv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// Counterexample is a pair of pods whose allowed ports differ between two sets of policies.
+type Counterexample struct {
+	Source *eval.PodConnection
+	Dest   *eval.PodConnection
+	// Lost are the ports only the before policies allow, Gained the ports only the after policies allow.
+	Lost   eval.PortSet
+	Gained eval.PortSet
+}
+
+type ChangeType string
+
+const (
+	PolicyAdded   ChangeType = "added"
+	PolicyRemoved ChangeType = "removed"
+	PolicyChanged ChangeType = "changed"
+)
+
+// PolicyChange is a policy, by namespace/name, whose spec differs between two sets of policies.
+type PolicyChange struct {
+	Policy string
+	Change ChangeType
+}
+
+// Equivalence is the result of comparing the connectivity of two sets of policies.
+type Equivalence struct {
+	// Namespaces are the namespaces whose policies were replaced.
+	Namespaces []string
+	// Changes are the policies that differ, whether or not connectivity does.
+	Changes []PolicyChange
+	// Pairs is the number of pairs of pods compared.
+	Pairs           int
+	Counterexamples []Counterexample
+	// Skipped are the reasons pods could not be compared.
+	Skipped []error
+}
+
+func (e *Equivalence) Equivalent() bool {
+	return len(e.Counterexamples) == 0
+}
+
+// Equivalence compares the connectivity between every pair of pods in namespaces under the before policies and
+// under the after policies. Each replaces the current policies of every namespace either set has a policy in, so
+// policies elsewhere still apply to egress and ingress across namespaces. The exact set of allowed ports is compared,
+// so equivalence holds on undeclared ports too. No namespaces means all namespaces.
+func (a *App) Equivalence(ctx context.Context, before, after []nwv1.NetworkPolicy, namespaces []string) (*Equivalence, error) {
+	snapshot, err := a.TakeSnapshot(ctx, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced := make([]string, 0)
+	for _, np := range append(append([]nwv1.NetworkPolicy{}, before...), after...) {
+		if !util.Contains(replaced, np.Namespace) {
+			replaced = append(replaced, np.Namespace)
+		}
+	}
+
+	if len(namespaces) == 0 {
+		namespaces, err = a.queryNamespaceNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	beforePods, skipped, err := NewClusterApp(snapshot.WithNamespacePolicies(replaced, before), a.defaultNamespace).queryPodConnections(ctx, namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("error loading before policies: %w", err)
+	}
+	afterPods, _, err := NewClusterApp(snapshot.WithNamespacePolicies(replaced, after), a.defaultNamespace).queryPodConnections(ctx, namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("error loading after policies: %w", err)
+	}
+
+	// Both come from the same snapshot so the pods are in the same order
+	e := &Equivalence{
+		Namespaces:      replaced,
+		Changes:         policyChanges(before, after),
+		Skipped:         skipped,
+		Counterexamples: make([]Counterexample, 0),
+	}
+	for i := range beforePods {
+		for j := range beforePods {
+			if i == j {
+				continue
+			}
+			e.Pairs++
+			beforeAllowed := eval.EvalPortSet(beforePods[i], beforePods[j]).Allowed
+			afterAllowed := eval.EvalPortSet(afterPods[i], afterPods[j]).Allowed
+			lost := beforeAllowed.Subtract(afterAllowed)
+			gained := afterAllowed.Subtract(beforeAllowed)
+			if lost.IsEmpty() && gained.IsEmpty() {
+				continue
+			}
+			e.Counterexamples = append(e.Counterexamples, Counterexample{
+				Source: beforePods[i],
+				Dest:   beforePods[j],
+				Lost:   lost,
+				Gained: gained,
+			})
+		}
+	}
+	return e, nil
+}
+
+// policyChanges compares policies by namespace and name, in the order they were given.
+func policyChanges(before, after []nwv1.NetworkPolicy) []PolicyChange {
+	key := func(np nwv1.NetworkPolicy) string { return np.Namespace + "/" + np.Name }
+	afterByKey := make(map[string]nwv1.NetworkPolicy, len(after))
+	for _, np := range after {
+		afterByKey[key(np)] = np
+	}
+
+	changes := make([]PolicyChange, 0)
+	beforeKeys := make(map[string]bool, len(before))
+	for _, np := range before {
+		beforeKeys[key(np)] = true
+		a, ok := afterByKey[key(np)]
+		if !ok {
+			changes = append(changes, PolicyChange{Policy: key(np), Change: PolicyRemoved})
+		} else if !equality.Semantic.DeepEqual(np.Spec, a.Spec) {
+			changes = append(changes, PolicyChange{Policy: key(np), Change: PolicyChanged})
+		}
+	}
+	for _, np := range after {
+		if !beforeKeys[key(np)] {
+			changes = append(changes, PolicyChange{Policy: key(np), Change: PolicyAdded})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/cheriot/netpoltool/internal/k8s"
+)
+
+func TestEquivalence(t *testing.T) {
+	newPod := func(namespace, name, ip, app string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": app}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+			}}},
+			Status: corev1.PodStatus{PodIP: ip},
+		}
+	}
+	allow := func(name string, from string, ports ...intstr.IntOrString) nwv1.NetworkPolicy {
+		tcp := corev1.ProtocolTCP
+		rule := nwv1.NetworkPolicyIngressRule{
+			From: []nwv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": from}}}},
+		}
+		for i := range ports {
+			rule.Ports = append(rule.Ports, nwv1.NetworkPolicyPort{Protocol: &tcp, Port: &ports[i]})
+		}
+		return nwv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "Shop"},
+			Spec: nwv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+				Ingress:     []nwv1.NetworkPolicyIngressRule{rule},
+			},
+		}
+	}
+	snapshot := &k8s.Snapshot{
+		Version: k8s.SnapshotVersion,
+		Namespaces: []corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "Shop"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "Other"}},
+		},
+		Pods: []corev1.Pod{
+			newPod("Shop", "Api", "10.0.0.1", "api"),
+			newPod("Shop", "Web", "10.0.0.2", "web"),
+			newPod("Other", "Batch", "10.0.0.3", "batch"),
+		},
+		NetworkPolicies: []nwv1.NetworkPolicy{
+			allow("Current", "api"),
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "DenyEgress", Namespace: "Other"},
+				Spec:       nwv1.NetworkPolicySpec{PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeEgress}},
+			},
+		},
+	}
+	a := NewClusterApp(snapshot, "Shop")
+
+	before := []nwv1.NetworkPolicy{allow("Http", "api", intstr.FromString("http")), allow("Alt", "api", intstr.FromInt(8443))}
+
+	Convey("Equivalence", t, func() {
+		Convey("Consolidated policies are equivalent", func() {
+			after := []nwv1.NetworkPolicy{allow("Web", "api", intstr.FromInt(8080), intstr.FromInt(8443))}
+			e, err := a.Equivalence(context.TODO(), before, after, nil)
+			So(err, ShouldBeNil)
+			So(e.Namespaces, ShouldResemble, []string{"Shop"})
+			So(e.Pairs, ShouldEqual, 6)
+			So(e.Equivalent(), ShouldBeTrue)
+			So(e.Changes, ShouldResemble, []PolicyChange{
+				{Policy: "Shop/Http", Change: PolicyRemoved},
+				{Policy: "Shop/Alt", Change: PolicyRemoved},
+				{Policy: "Shop/Web", Change: PolicyAdded},
+			})
+		})
+
+		Convey("Reports the ports that differ", func() {
+			after := []nwv1.NetworkPolicy{allow("Web", "api", intstr.FromInt(8080), intstr.FromInt(9090))}
+			e, err := a.Equivalence(context.TODO(), before, after, nil)
+			So(err, ShouldBeNil)
+			So(e.Counterexamples, ShouldHaveLength, 1)
+			So(e.Counterexamples[0].Source.GetName(), ShouldEqual, "Shop/Api")
+			So(e.Counterexamples[0].Dest.GetName(), ShouldEqual, "Shop/Web")
+			So(e.Counterexamples[0].Lost.String(), ShouldEqual, "TCP 8443")
+			So(e.Counterexamples[0].Gained.String(), ShouldEqual, "TCP 9090")
+
+			Convey("And the policies that changed", func() {
+				after[0].Name = "Http"
+				e, err := a.Equivalence(context.TODO(), before, after, nil)
+				So(err, ShouldBeNil)
+				So(e.Changes, ShouldResemble, []PolicyChange{
+					{Policy: "Shop/Http", Change: PolicyChanged},
+					{Policy: "Shop/Alt", Change: PolicyRemoved},
+				})
+			})
+		})
+
+		Convey("A manifest that omits policyTypes isolates like the API server's default", func() {
+			manifests, err := k8s.ReadManifests("web.yaml", strings.NewReader(`
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: Web
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+  ingress:
+  - from:
+    - podSelector:
+        matchLabels:
+          app: api
+    ports:
+    - port: 8080
+    - port: 8443
+`), "Shop")
+			So(err, ShouldBeNil)
+			after := []nwv1.NetworkPolicy{manifests[0].NetworkPolicy}
+			e, err := a.Equivalence(context.TODO(), before, after, nil)
+			So(err, ShouldBeNil)
+			So(e.Equivalent(), ShouldBeTrue)
+		})
+
+		Convey("Policies of other namespaces still apply", func() {
+			// Batch cannot egress, so allowing it ingress changes nothing
+			after := append(append([]nwv1.NetworkPolicy{}, before...), allow("Batch", "batch"))
+			after[2].Spec.Ingress[0].From[0].NamespaceSelector = &metav1.LabelSelector{}
+			e, err := a.Equivalence(context.TODO(), before, after, nil)
+			So(err, ShouldBeNil)
+			So(e.Equivalent(), ShouldBeTrue)
+		})
+	})
+}
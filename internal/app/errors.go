@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrorClass says whose fault an error is, so the CLI and the server can report it with an exit code or a status.
+type ErrorClass int
+
+const (
+	// ErrorInput is a missing or invalid object, a hostname that does not resolve, or anything else the user can fix.
+	ErrorInput ErrorClass = iota
+	// ErrorCluster is the API server refusing a request.
+	ErrorCluster
+	// ErrorUnavailable is an API server that cannot be reached or did not answer in time.
+	ErrorUnavailable
+)
+
+// ClassifyError classifies errors from querying the cluster. Errors it does not recognize are input errors.
+func ClassifyError(err error) ErrorClass {
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		if apierrors.IsNotFound(err) || apierrors.IsInvalid(err) || apierrors.IsBadRequest(err) {
+			return ErrorInput
+		}
+		return ErrorCluster
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return ErrorInput
+	}
+
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &urlErr) || errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorUnavailable
+	}
+	return ErrorInput
+}
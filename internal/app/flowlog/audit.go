@@ -0,0 +1,123 @@
+package flowlog
+
+import (
+	"fmt"
+	"strconv"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+type Disagreement uint8
+
+const (
+	// DeniedButForwarded flows will break when the CNI starts enforcing the policies.
+	DeniedButForwarded Disagreement = iota
+	// AllowedButDropped flows were blocked by something other than NetworkPolicy, like a CNI specific policy, or
+	// netpoltool evaluated the policies differently than the CNI.
+	AllowedButDropped
+)
+
+func (d Disagreement) String() string {
+	return []string{"denied by policy but forwarded", "allowed by policy but dropped"}[d]
+}
+
+// Finding is an observed flow where NetworkPolicy evaluation and the CNI's verdict disagree.
+type Finding struct {
+	// Flow is the first of the flows with the same source, destination, port, protocol and verdict.
+	Flow         ResolvedFlow
+	Disagreement Disagreement
+	Result       eval.PortResult
+	// Count of flows the finding stands for.
+	Count int
+}
+
+func (f Finding) Message() string {
+	line := fmt.Sprintf("line %d", f.Flow.Line)
+	if f.Count > 1 {
+		line = fmt.Sprintf("line %d and %d more flows", f.Flow.Line, f.Count-1)
+	}
+	return fmt.Sprintf(
+		"%s: %s -> %s port %d/%s was %s. Egress %s. Ingress %s.",
+		line,
+		f.Flow.Flow.Source,
+		f.Flow.Flow.Dest,
+		f.Flow.Port,
+		f.Flow.Protocol,
+		f.Disagreement,
+		eval.Explain(f.Result.EgressAllowed, f.Result.Egress),
+		eval.Explain(f.Result.IngressAllowed, f.Result.Ingress))
+}
+
+// key identifies the connection of a flow, so the same connection logged by name on one line and by IP on another is
+// reported once.
+func (f ResolvedFlow) key() string {
+	name := func(e Endpoint, pod *eval.PodConnection) string {
+		if pod != nil {
+			return pod.GetName()
+		}
+		return e.String()
+	}
+	return fmt.Sprintf("%s %s %d/%s %s", name(f.Flow.Source, f.Source), name(f.Flow.Dest, f.Dest), f.Port, f.Protocol, f.Verdict)
+}
+
+// Audit evaluates each flow against the policies of its pods. A source outside the cluster is evaluated against the
+// ingress policies of the destination pod. Flows that can't be evaluated, because neither end is a known pod or a pod
+// no longer exists, are returned as skipped with the reason. Repeats of a flow are counted in the first one's finding.
+func Audit(flows []ResolvedFlow) ([]Finding, []string) {
+	findings := make([]Finding, 0)
+	skipped := make([]string, 0)
+	seen := make(map[string]int)
+	for _, f := range flows {
+		var source eval.ConnectionSide
+		if f.Source != nil {
+			source = f.Source
+		} else if f.Flow.Source.IsExternal(f.Source) && f.Dest != nil {
+			external, err := eval.NewExternalConnection(f.Flow.Source.IP, strconv.Itoa(int(f.Port)), string(f.Protocol))
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("line %d: %s", f.Line, err.Error()))
+				continue
+			}
+			source = external
+		} else {
+			skipped = append(skipped, fmt.Sprintf("line %d: %s is not a pod in the cluster", f.Line, f.Flow.Source))
+			continue
+		}
+
+		var dest eval.ConnectionSide
+		if f.Dest != nil {
+			dest = f.Dest.ForPort(f.Port, f.Protocol)
+		} else if f.Flow.Dest.IsExternal(f.Dest) {
+			external, err := eval.NewExternalConnection(f.Flow.Dest.IP, strconv.Itoa(int(f.Port)), string(f.Protocol))
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("line %d: %s", f.Line, err.Error()))
+				continue
+			}
+			dest = external
+		} else {
+			skipped = append(skipped, fmt.Sprintf("line %d: %s is not a pod in the cluster", f.Line, f.Flow.Dest))
+			continue
+		}
+
+		key := f.key()
+		if i, ok := seen[key]; ok {
+			if i >= 0 {
+				findings[i].Count++
+			}
+			continue
+		}
+		seen[key] = -1
+
+		for _, pr := range eval.Eval(source, dest) {
+			switch {
+			case !pr.Allowed && f.Verdict != VerdictDropped:
+				// A flow without a verdict was logged because it happened
+				seen[key] = len(findings)
+				findings = append(findings, Finding{Flow: f, Disagreement: DeniedButForwarded, Result: pr, Count: 1})
+			case pr.Allowed && f.Verdict == VerdictDropped:
+				seen[key] = len(findings)
+				findings = append(findings, Finding{Flow: f, Disagreement: AllowedButDropped, Result: pr, Count: 1})
+			}
+		}
+	}
+	return findings, skipped
+}
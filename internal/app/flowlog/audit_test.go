@@ -0,0 +1,119 @@
+package flowlog
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+func TestAudit(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-one"}}
+	denyIngress := nwv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns-one", Name: "deny-ingress"},
+		Spec: nwv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "locked"}},
+			PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+		},
+	}
+	newPod := func(name, ip, app string) *eval.PodConnection {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns-one", Name: name, Labels: map[string]string{"app": app}},
+			Status:     corev1.PodStatus{PodIP: ip},
+		}
+		conn, err := eval.NewPodConnection(pod, ns, []nwv1.NetworkPolicy{denyIngress}, "")
+		if err != nil {
+			panic(err.Error())
+		}
+		return conn
+	}
+	open := newPod("open", "10.0.0.1", "open")
+	locked := newPod("locked", "10.0.0.2", "locked")
+
+	flow := func(source, dest *eval.PodConnection, destIP string, verdict Verdict) ResolvedFlow {
+		return ResolvedFlow{
+			Flow: Flow{
+				Source:   Endpoint{Namespace: "ns-one", Pod: source.Pod.Name},
+				Dest:     Endpoint{IP: destIP},
+				Port:     8080,
+				Protocol: corev1.ProtocolTCP,
+				Verdict:  verdict,
+				Line:     1,
+			},
+			Source: source,
+			Dest:   dest,
+		}
+	}
+
+	Convey("Flows that agree with policy are not reported", t, func() {
+		findings, skipped := Audit([]ResolvedFlow{
+			flow(locked, open, "10.0.0.1", VerdictForwarded),
+			flow(open, locked, "10.0.0.2", VerdictDropped),
+			flow(open, nil, "1.1.1.1", VerdictUnknown),
+		})
+		So(findings, ShouldBeEmpty)
+		So(skipped, ShouldBeEmpty)
+	})
+
+	Convey("A forwarded flow that policy denies is a breakage risk", t, func() {
+		findings, _ := Audit([]ResolvedFlow{flow(open, locked, "10.0.0.2", VerdictForwarded)})
+		So(findings, ShouldHaveLength, 1)
+		So(findings[0].Disagreement, ShouldEqual, DeniedButForwarded)
+		So(findings[0].Message(), ShouldEqual, "line 1: ns-one/open -> 10.0.0.2 port 8080/TCP was denied by policy but forwarded. Egress allowed (no matching policies). Ingress denied by ns-one/deny-ingress.")
+	})
+
+	Convey("A dropped flow that policy allows was dropped by something else", t, func() {
+		findings, _ := Audit([]ResolvedFlow{flow(locked, nil, "1.1.1.1", VerdictDropped)})
+		So(findings, ShouldHaveLength, 1)
+		So(findings[0].Disagreement, ShouldEqual, AllowedButDropped)
+	})
+
+	Convey("A flow from outside the cluster is evaluated against the destination's ingress policies", t, func() {
+		external := flow(open, locked, "10.0.0.2", VerdictForwarded)
+		external.Source = nil
+		external.Flow.Source = Endpoint{IP: "203.0.113.7"}
+
+		findings, skipped := Audit([]ResolvedFlow{external})
+		So(skipped, ShouldBeEmpty)
+		So(findings, ShouldHaveLength, 1)
+		So(findings[0].Disagreement, ShouldEqual, DeniedButForwarded)
+		So(findings[0].Message(), ShouldContainSubstring, "203.0.113.7 -> 10.0.0.2 port 8080/TCP")
+
+		external.Dest = nil
+		external.Flow.Dest = Endpoint{IP: "1.1.1.1"}
+		_, skipped = Audit([]ResolvedFlow{external})
+		So(skipped, ShouldResemble, []string{"line 1: 203.0.113.7 is not a pod in the cluster"})
+	})
+
+	Convey("Repeats of a connection are one finding", t, func() {
+		byIP := flow(open, locked, "10.0.0.2", VerdictForwarded)
+		byName := flow(open, locked, "", VerdictForwarded)
+		byName.Flow.Dest = Endpoint{Namespace: "ns-one", Pod: "locked"}
+		byName.Line = 2
+		dropped := flow(open, locked, "10.0.0.2", VerdictDropped)
+		dropped.Line = 3
+
+		findings, _ := Audit([]ResolvedFlow{byIP, byName, dropped, byIP})
+		So(findings, ShouldHaveLength, 1)
+		So(findings[0].Count, ShouldEqual, 3)
+		So(findings[0].Message(), ShouldStartWith, "line 1 and 2 more flows: ns-one/open -> 10.0.0.2")
+	})
+
+	Convey("Flows without a source pod or with a deleted destination are skipped", t, func() {
+		external := flow(open, open, "", VerdictForwarded)
+		external.Source = nil
+		deleted := flow(open, nil, "", VerdictForwarded)
+		deleted.Flow.Dest = Endpoint{Namespace: "ns-one", Pod: "deleted"}
+
+		findings, skipped := Audit([]ResolvedFlow{external, deleted})
+		So(findings, ShouldBeEmpty)
+		So(skipped, ShouldResemble, []string{
+			"line 1: ns-one/open is not a pod in the cluster",
+			"line 1: ns-one/deleted is not a pod in the cluster",
+		})
+	})
+}
@@ -0,0 +1,327 @@
+package flowlog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+type Format string
+
+const (
+	FormatHubble Format = "hubble"
+	FormatCalico Format = "calico"
+	FormatCSV    Format = "csv"
+)
+
+type Verdict uint8
+
+const (
+	VerdictUnknown Verdict = iota
+	VerdictForwarded
+	VerdictDropped
+)
+
+func (v Verdict) String() string {
+	return []string{"unknown", "forwarded", "dropped"}[v]
+}
+
+// Endpoint is one side of a flow as the flow log recorded it. Namespace and Pod are empty when the log only has an
+// IP, for example for hosts outside the cluster.
+type Endpoint struct {
+	Namespace string
+	Pod       string
+	IP        string
+}
+
+func (e Endpoint) String() string {
+	if e.Pod != "" {
+		return e.Namespace + "/" + e.Pod
+	}
+	return e.IP
+}
+
+// Flow is a single observed connection attempt.
+type Flow struct {
+	Source   Endpoint
+	Dest     Endpoint
+	Port     int32
+	Protocol corev1.Protocol
+	Verdict  Verdict
+	// Line in the input the flow was read from, for error messages.
+	Line int
+}
+
+func Load(path string, format Format) ([]Flow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening flow log: %w", err)
+	}
+	defer f.Close()
+	return Read(f, format)
+}
+
+func Read(r io.Reader, format Format) ([]Flow, error) {
+	switch format {
+	case FormatHubble:
+		return readJSONLines(r, parseHubble)
+	case FormatCalico:
+		return readJSONLines(r, parseCalico)
+	case FormatCSV:
+		return readCSV(r)
+	}
+	return nil, fmt.Errorf("unknown flow log format %s", format)
+}
+
+// readJSONLines parses one JSON object per line. parse returns false for records that are not connection attempts,
+// like replies.
+func readJSONLines(r io.Reader, parse func([]byte) (Flow, bool, error)) ([]Flow, error) {
+	flows := make([]Flow, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		bs := scanner.Bytes()
+		if len(strings.TrimSpace(string(bs))) == 0 {
+			continue
+		}
+		flow, ok, err := parse(bs)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ok {
+			flow.Line = line
+			flows = append(flows, flow)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading flow log: %w", err)
+	}
+	return flows, nil
+}
+
+// hubbleFlow is the subset of a Hubble flow that identifies a connection. `hubble observe -o json` has written both
+// snake_case and camelCase field names depending on the version so both are accepted.
+type hubbleFlow struct {
+	Verdict string `json:"verdict"`
+	IP      struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+	} `json:"IP"`
+	L4 map[string]struct {
+		DestinationPort      int32 `json:"destination_port"`
+		DestinationPortCamel int32 `json:"destinationPort"`
+	} `json:"l4"`
+	Source       hubbleEndpoint `json:"source"`
+	Destination  hubbleEndpoint `json:"destination"`
+	IsReply      *bool          `json:"is_reply"`
+	IsReplyCamel *bool          `json:"isReply"`
+}
+
+type hubbleEndpoint struct {
+	Namespace    string `json:"namespace"`
+	PodName      string `json:"pod_name"`
+	PodNameCamel string `json:"podName"`
+}
+
+func parseHubble(bs []byte) (Flow, bool, error) {
+	wrapper := struct {
+		Flow *hubbleFlow `json:"flow"`
+	}{}
+	if err := json.Unmarshal(bs, &wrapper); err != nil {
+		return Flow{}, false, err
+	}
+	hf := wrapper.Flow
+	if hf == nil {
+		// `hubble observe -o json` writes the flow without the wrapper used by the API
+		hf = &hubbleFlow{}
+		if err := json.Unmarshal(bs, hf); err != nil {
+			return Flow{}, false, err
+		}
+	}
+
+	if hf.IsReply != nil && *hf.IsReply || hf.IsReplyCamel != nil && *hf.IsReplyCamel {
+		return Flow{}, false, nil
+	}
+
+	flow := Flow{
+		Source: Endpoint{
+			Namespace: hf.Source.Namespace,
+			Pod:       firstNonEmpty(hf.Source.PodName, hf.Source.PodNameCamel),
+			IP:        hf.IP.Source,
+		},
+		Dest: Endpoint{
+			Namespace: hf.Destination.Namespace,
+			Pod:       firstNonEmpty(hf.Destination.PodName, hf.Destination.PodNameCamel),
+			IP:        hf.IP.Destination,
+		},
+	}
+	for proto, l4 := range hf.L4 {
+		flow.Protocol = corev1.Protocol(strings.ToUpper(proto))
+		flow.Port = l4.DestinationPort
+		if flow.Port == 0 {
+			flow.Port = l4.DestinationPortCamel
+		}
+	}
+	if flow.Port == 0 {
+		// ICMP and other flows without ports can't be described by a NetworkPolicy
+		return Flow{}, false, nil
+	}
+
+	switch hf.Verdict {
+	case "FORWARDED":
+		flow.Verdict = VerdictForwarded
+	case "DROPPED":
+		flow.Verdict = VerdictDropped
+	}
+	return flow, true, nil
+}
+
+// calicoFlow is a Calico flow log record as exported by fluentd.
+type calicoFlow struct {
+	SourceNamespace string          `json:"source_namespace"`
+	SourceName      string          `json:"source_name"`
+	SourceIP        string          `json:"source_ip"`
+	DestNamespace   string          `json:"dest_namespace"`
+	DestName        string          `json:"dest_name"`
+	DestIP          string          `json:"dest_ip"`
+	DestPort        json.Number     `json:"dest_port"`
+	Proto           json.RawMessage `json:"proto"`
+	Action          string          `json:"action"`
+}
+
+func parseCalico(bs []byte) (Flow, bool, error) {
+	cf := calicoFlow{}
+	if err := json.Unmarshal(bs, &cf); err != nil {
+		return Flow{}, false, err
+	}
+
+	port, err := cf.DestPort.Int64()
+	if err != nil || port == 0 {
+		return Flow{}, false, nil
+	}
+
+	protocol, err := parseProtocol(strings.Trim(string(cf.Proto), `"`))
+	if err != nil {
+		return Flow{}, false, err
+	}
+
+	flow := Flow{
+		Source:   calicoEndpoint(cf.SourceNamespace, cf.SourceName, cf.SourceIP),
+		Dest:     calicoEndpoint(cf.DestNamespace, cf.DestName, cf.DestIP),
+		Port:     int32(port),
+		Protocol: protocol,
+	}
+	switch cf.Action {
+	case "allow":
+		flow.Verdict = VerdictForwarded
+	case "deny":
+		flow.Verdict = VerdictDropped
+	}
+	return flow, true, nil
+}
+
+// calicoEndpoint drops the placeholders Calico writes for aggregated or external endpoints.
+func calicoEndpoint(namespace, name, ip string) Endpoint {
+	if namespace == "-" {
+		namespace = ""
+	}
+	if name == "-" || strings.HasSuffix(name, "*") {
+		name = ""
+	}
+	if ip == "-" {
+		ip = ""
+	}
+	return Endpoint{Namespace: namespace, Pod: name, IP: ip}
+}
+
+// readCSV reads `source,destination,port,protocol[,verdict]` where source and destination are namespace/pod or an IP.
+// A header row is skipped.
+func readCSV(r io.Reader) ([]Flow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	flows := make([]Flow, 0)
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading flow log: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) < 4 || len(record) > 5 {
+			return nil, fmt.Errorf("line %d: expected source,destination,port,protocol[,verdict] but found %d fields", line, len(record))
+		}
+
+		port, err := strconv.Atoi(record[2])
+		if err != nil {
+			if first {
+				// header
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid port %s", line, record[2])
+		}
+		protocol, err := parseProtocol(record[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		flow := Flow{
+			Source:   csvEndpoint(record[0]),
+			Dest:     csvEndpoint(record[1]),
+			Port:     int32(port),
+			Protocol: protocol,
+			Line:     line,
+		}
+		if len(record) == 5 {
+			switch strings.ToLower(record[4]) {
+			case "forwarded", "allow", "allowed":
+				flow.Verdict = VerdictForwarded
+			case "dropped", "deny", "denied":
+				flow.Verdict = VerdictDropped
+			}
+		}
+		flows = append(flows, flow)
+	}
+	return flows, nil
+}
+
+func csvEndpoint(s string) Endpoint {
+	if namespace, pod, ok := strings.Cut(s, "/"); ok {
+		return Endpoint{Namespace: namespace, Pod: pod}
+	}
+	return Endpoint{IP: s}
+}
+
+func parseProtocol(s string) (corev1.Protocol, error) {
+	switch strings.ToUpper(s) {
+	case "TCP", "6":
+		return corev1.ProtocolTCP, nil
+	case "UDP", "17":
+		return corev1.ProtocolUDP, nil
+	case "SCTP", "132":
+		return corev1.ProtocolSCTP, nil
+	}
+	return "", fmt.Errorf("unsupported protocol %s", s)
+}
+
+func firstNonEmpty(ss ...string) string {
+	for _, s := range ss {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
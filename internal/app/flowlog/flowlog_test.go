@@ -0,0 +1,104 @@
+package flowlog
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+func TestRead(t *testing.T) {
+	Convey("Hubble flows with and without the API wrapper", t, func() {
+		log := `{"flow":{"verdict":"FORWARDED","IP":{"source":"10.0.0.1","destination":"10.0.0.2"},"l4":{"TCP":{"destination_port":8080}},"source":{"namespace":"ns-one","pod_name":"pod-one"},"destination":{"namespace":"ns-two","pod_name":"pod-two"}}}
+
+{"verdict":"DROPPED","IP":{"source":"10.0.0.1","destination":"1.1.1.1"},"l4":{"UDP":{"destinationPort":53}},"source":{"namespace":"ns-one","podName":"pod-one"},"destination":{}}
+{"flow":{"verdict":"FORWARDED","IP":{"source":"10.0.0.2","destination":"10.0.0.1"},"l4":{"TCP":{"destination_port":41000}},"is_reply":true}}
+{"flow":{"verdict":"FORWARDED","IP":{"source":"10.0.0.2","destination":"10.0.0.1"},"l4":{"ICMPv4":{}}}}`
+		flows, err := Read(strings.NewReader(log), FormatHubble)
+		So(err, ShouldBeNil)
+		So(flows, ShouldHaveLength, 2)
+
+		So(flows[0].Source, ShouldResemble, Endpoint{Namespace: "ns-one", Pod: "pod-one", IP: "10.0.0.1"})
+		So(flows[0].Dest, ShouldResemble, Endpoint{Namespace: "ns-two", Pod: "pod-two", IP: "10.0.0.2"})
+		So(flows[0].Port, ShouldEqual, 8080)
+		So(flows[0].Protocol, ShouldEqual, corev1.ProtocolTCP)
+		So(flows[0].Verdict, ShouldEqual, VerdictForwarded)
+		So(flows[0].Line, ShouldEqual, 1)
+
+		So(flows[1].Dest, ShouldResemble, Endpoint{IP: "1.1.1.1"})
+		So(flows[1].Port, ShouldEqual, 53)
+		So(flows[1].Protocol, ShouldEqual, corev1.ProtocolUDP)
+		So(flows[1].Verdict, ShouldEqual, VerdictDropped)
+		So(flows[1].Line, ShouldEqual, 3)
+	})
+
+	Convey("Calico flows drop placeholder and aggregated names", t, func() {
+		log := `{"source_namespace":"ns-one","source_name":"pod-one","source_ip":"10.0.0.1","dest_namespace":"ns-two","dest_name":"pod-two-*","dest_ip":"10.0.0.2","dest_port":5432,"proto":"6","action":"allow"}
+{"source_namespace":"-","source_name":"-","source_ip":"-","dest_namespace":"ns-two","dest_name":"pod-two","dest_ip":"10.0.0.2","dest_port":53,"proto":"udp","action":"deny"}`
+		flows, err := Read(strings.NewReader(log), FormatCalico)
+		So(err, ShouldBeNil)
+		So(flows, ShouldHaveLength, 2)
+		So(flows[0].Dest, ShouldResemble, Endpoint{Namespace: "ns-two", IP: "10.0.0.2"})
+		So(flows[0].Protocol, ShouldEqual, corev1.ProtocolTCP)
+		So(flows[0].Verdict, ShouldEqual, VerdictForwarded)
+		So(flows[1].Source, ShouldResemble, Endpoint{})
+		So(flows[1].Protocol, ShouldEqual, corev1.ProtocolUDP)
+		So(flows[1].Verdict, ShouldEqual, VerdictDropped)
+	})
+
+	Convey("CSV flows with a header", t, func() {
+		log := "source,destination,port,protocol,verdict\nns-one/pod-one,ns-two/pod-two,80,tcp,allowed\n# comment\n10.0.0.1,1.1.1.1,443,TCP\n"
+		flows, err := Read(strings.NewReader(log), FormatCSV)
+		So(err, ShouldBeNil)
+		So(flows, ShouldHaveLength, 2)
+		So(flows[0].Source, ShouldResemble, Endpoint{Namespace: "ns-one", Pod: "pod-one"})
+		So(flows[0].Verdict, ShouldEqual, VerdictForwarded)
+		So(flows[0].Line, ShouldEqual, 2)
+		So(flows[1].Dest, ShouldResemble, Endpoint{IP: "1.1.1.1"})
+		So(flows[1].Verdict, ShouldEqual, VerdictUnknown)
+		So(flows[1].Line, ShouldEqual, 4)
+	})
+
+	Convey("CSV errors report the line", t, func() {
+		_, err := Read(strings.NewReader("a,b,80,tcp\na,b,eighty,tcp\n"), FormatCSV)
+		So(err, ShouldBeError, "line 2: invalid port eighty")
+
+		_, err = Read(strings.NewReader("a,b,80,icmp\n"), FormatCSV)
+		So(err, ShouldBeError, "line 1: unsupported protocol icmp")
+	})
+}
+
+func TestResolve(t *testing.T) {
+	newPod := func(name, ip string, hostNetwork bool) *eval.PodConnection {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns-one", Name: name},
+			Spec:       corev1.PodSpec{HostNetwork: hostNetwork},
+			Status:     corev1.PodStatus{PodIP: ip},
+		}
+		conn, err := eval.NewPodConnection(pod, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-one"}}, nil, "")
+		if err != nil {
+			panic(err.Error())
+		}
+		return conn
+	}
+	podOne := newPod("pod-one", "10.0.0.1", false)
+	node := newPod("node-agent", "192.168.0.1", true)
+
+	Convey("Endpoints resolve by name, then by IP, and host network pods do not claim their IP", t, func() {
+		resolved := Resolve([]Flow{
+			{Source: Endpoint{Namespace: "ns-one", Pod: "pod-one"}, Dest: Endpoint{IP: "10.0.0.1"}},
+			{Source: Endpoint{IP: "192.168.0.1"}, Dest: Endpoint{Namespace: "ns-one", Pod: "deleted"}},
+		}, []*eval.PodConnection{podOne, node})
+
+		So(resolved[0].Source, ShouldEqual, podOne)
+		So(resolved[0].Dest, ShouldEqual, podOne)
+		So(resolved[1].Source, ShouldBeNil)
+		So(resolved[1].Flow.Source.IsExternal(resolved[1].Source), ShouldBeTrue)
+		So(resolved[1].Dest, ShouldBeNil)
+		So(resolved[1].Flow.Dest.IsExternal(resolved[1].Dest), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,55 @@
+package flowlog
+
+import (
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+// ResolvedFlow is a Flow with its endpoints matched to pods. A nil pod means the endpoint is outside the cluster, or
+// was a pod that no longer exists.
+type ResolvedFlow struct {
+	Flow
+	Source *eval.PodConnection
+	Dest   *eval.PodConnection
+}
+
+// IsExternal is true for an endpoint that never named a pod and whose IP does not belong to one.
+func (e Endpoint) IsExternal(pod *eval.PodConnection) bool {
+	return pod == nil && e.Pod == "" && e.IP != ""
+}
+
+// Resolve matches flow endpoints to pods, first by namespace and name and then by IP.
+func Resolve(flows []Flow, pods []*eval.PodConnection) []ResolvedFlow {
+	byName := make(map[string]*eval.PodConnection, len(pods))
+	byIP := make(map[string]*eval.PodConnection, len(pods))
+	for _, pod := range pods {
+		byName[pod.GetName()] = pod
+		if pod.Pod.Spec.HostNetwork {
+			// Shares the node's IP so the IP doesn't identify the pod
+			continue
+		}
+		byIP[pod.Pod.Status.PodIP] = pod
+		for _, podIP := range pod.Pod.Status.PodIPs {
+			byIP[podIP.IP] = pod
+		}
+	}
+
+	resolve := func(e Endpoint) *eval.PodConnection {
+		if e.Pod != "" {
+			return byName[e.Namespace+"/"+e.Pod]
+		}
+		if e.IP != "" {
+			return byIP[e.IP]
+		}
+		return nil
+	}
+
+	resolved := make([]ResolvedFlow, 0, len(flows))
+	for _, flow := range flows {
+		resolved = append(resolved, ResolvedFlow{
+			Flow:   flow,
+			Source: resolve(flow.Source),
+			Dest:   resolve(flow.Dest),
+		})
+	}
+	return resolved
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+
+	"github.com/cheriot/netpoltool/internal/app/flowlog"
+)
+
+// ResolveFlows matches the endpoints of observed flows to the pods of every namespace.
+func (a *App) ResolveFlows(ctx context.Context, flows []flowlog.Flow) ([]flowlog.ResolvedFlow, error) {
+	namespaces, err := a.queryNamespaceNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, _, err := a.queryPodConnections(ctx, namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	return flowlog.Resolve(flows, pods), nil
+}
+
+// AuditFlows compares the verdicts of observed flows to what the current policies allow.
+func (a *App) AuditFlows(ctx context.Context, flows []flowlog.Flow) ([]flowlog.Finding, []string, error) {
+	resolved, err := a.ResolveFlows(ctx, flows)
+	if err != nil {
+		return nil, nil, err
+	}
+	findings, skipped := flowlog.Audit(resolved)
+	return findings, skipped, nil
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// IngressQuery identifies traffic from addresses outside the cluster to a NodePort or LoadBalancer Service.
+type IngressQuery struct {
+	Namespace string
+	Service   string
+	Port      string
+	FromCIDR  string
+}
+
+// IngressEvaluation is the result of evaluating traffic from outside the cluster to one backend of a Service. Source
+// is the CIDR when the client IP is preserved, or the node that SNATs it.
+type IngressEvaluation struct {
+	Source  eval.ConnectionSide
+	Dest    eval.ConnectionSide
+	Results []eval.PortResult
+	Via     string
+}
+
+func (e *IngressEvaluation) AllowedCount() int {
+	return len(util.Filter(e.Results, func(pr eval.PortResult) bool { return pr.Allowed }))
+}
+
+// EvaluateIngress evaluates q.FromCIDR connecting to each ready backend of a NodePort or LoadBalancer Service. With
+// externalTrafficPolicy Local the client IP is preserved so ingress policies see the CIDR. With Cluster, kube-proxy
+// SNATs to the IP of whichever node received the traffic, so each node is evaluated instead.
+func (a *App) EvaluateIngress(ctx context.Context, q IngressQuery) ([]IngressEvaluation, error) {
+	cidr, err := eval.NewCIDRConnection(q.FromCIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := a.queryService(ctx, q.Namespace, q.Service)
+	if err != nil {
+		return nil, err
+	}
+	if svc.Spec.Type != corev1.ServiceTypeNodePort && svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return nil, fmt.Errorf("Service %s/%s is type %s, not NodePort or LoadBalancer", svc.Namespace, svc.Name, svc.Spec.Type)
+	}
+
+	servicePorts, err := selectServicePorts(svc, q.Port, "")
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints, err := a.cluster.QueryEndpoints(ctx, svc.Namespace, svc.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error querying endpoints of Service %s/%s: %w", svc.Namespace, svc.Name, err)
+	}
+
+	local := svc.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal
+	var nodes []*eval.NodeConnection
+	if !local {
+		nodes, err = a.queryNodeConnections(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	evaluations := make([]IngressEvaluation, 0)
+	for _, subset := range endpoints.Subsets {
+		targetPorts := selectTargetPorts(subset, servicePorts)
+		if len(targetPorts) == 0 {
+			continue
+		}
+
+		for _, address := range subset.Addresses {
+			if local {
+				dest, results, err := a.evaluateBackend(ctx, cidr, address, targetPorts)
+				if err != nil {
+					return nil, err
+				}
+				evaluations = append(evaluations, IngressEvaluation{
+					Source:  cidr,
+					Dest:    dest,
+					Results: results,
+					Via:     fmt.Sprintf("externalTrafficPolicy Local preserves the client IP %s, backend %s", cidr.GetName(), address.IP),
+				})
+				continue
+			}
+
+			for _, node := range nodes {
+				dest, results, err := a.evaluateBackend(ctx, node, address, targetPorts)
+				if err != nil {
+					return nil, err
+				}
+				via := fmt.Sprintf("externalTrafficPolicy Cluster SNATs %s to %s, backend %s", cidr.GetName(), node.GetName(), address.IP)
+				if dest.IsOnNode(node.Node.Name) {
+					// "traffic to and from the node where a Pod is running is always allowed"
+					via += ", on the same node so Kubernetes allows it regardless of policy"
+				}
+				evaluations = append(evaluations, IngressEvaluation{
+					Source:  node,
+					Dest:    dest,
+					Results: results,
+					Via:     via,
+				})
+			}
+		}
+	}
+	if len(evaluations) == 0 {
+		return nil, fmt.Errorf("Service %s/%s has no ready backends for port %s", svc.Namespace, svc.Name, q.Port)
+	}
+	return evaluations, nil
+}
+
+func (a *App) CheckIngress(v ConsoleView, q IngressQuery) (Verdict, error) {
+	es, err := a.EvaluateIngress(context.TODO(), q)
+	if err != nil {
+		return VerdictDenied, err
+	}
+
+	allowed, total := 0, 0
+	for i, e := range es {
+		if i > 0 {
+			fmt.Fprintln(v.Writer)
+		}
+		fmt.Fprintf(v.Writer, "%s\n", e.Via)
+		RenderCheckAccess(v, e.Results, e.Source, e.Dest)
+		allowed += e.AllowedCount()
+		total += len(e.Results)
+	}
+	return NewVerdict(allowed, total), nil
+}
+
+func (a *App) queryService(ctx context.Context, namespace, name string) (*corev1.Service, error) {
+	serviceList, err := a.cluster.QueryServiceList(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error querying for services %s: %w", namespace, err)
+	}
+	for i := range serviceList.Items {
+		if serviceList.Items[i].Name == name {
+			return &serviceList.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unable to find Service %s in %s", name, namespace)
+}
+
+// queryNodeConnections finds every node, any of which may receive NodePort or LoadBalancer traffic.
+func (a *App) queryNodeConnections(ctx context.Context) ([]*eval.NodeConnection, error) {
+	nodeList, err := a.cluster.QueryNodeList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying for nodes: %w", err)
+	}
+	if len(nodeList.Items) == 0 {
+		return nil, fmt.Errorf("no nodes found. Snapshots taken by older versions or without permission to list nodes do not include them")
+	}
+
+	nodes := make([]*eval.NodeConnection, 0, len(nodeList.Items))
+	for i := range nodeList.Items {
+		node, err := eval.NewNodeConnection(&nodeList.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
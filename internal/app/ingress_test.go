@@ -0,0 +1,121 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/cheriot/netpoltool/internal/k8s"
+)
+
+func TestEvaluateIngress(t *testing.T) {
+	newNode := func(name, ip string) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: ip}}},
+		}
+	}
+	newSnapshot := func(policy corev1.ServiceExternalTrafficPolicyType) *k8s.Snapshot {
+		return &k8s.Snapshot{
+			Version:    k8s.SnapshotVersion,
+			Namespaces: []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "NamespaceOne"}}},
+			Nodes:      []corev1.Node{newNode("NodeOne", "192.168.0.1"), newNode("NodeTwo", "192.168.0.2")},
+			Pods: []corev1.Pod{{
+				ObjectMeta: metav1.ObjectMeta{Name: "Web", Namespace: "NamespaceOne", Labels: map[string]string{"app": "web"}},
+				Spec: corev1.PodSpec{
+					NodeName: "NodeOne",
+					Containers: []corev1.Container{{
+						Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+					}},
+				},
+				Status: corev1.PodStatus{PodIP: "10.0.0.2"},
+			}},
+			NetworkPolicies: []nwv1.NetworkPolicy{{
+				ObjectMeta: metav1.ObjectMeta{Name: "AllowOffice", Namespace: "NamespaceOne"},
+				Spec: nwv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+					PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+					Ingress: []nwv1.NetworkPolicyIngressRule{{
+						From: []nwv1.NetworkPolicyPeer{{IPBlock: &nwv1.IPBlock{CIDR: "203.0.113.0/24"}}},
+					}},
+				},
+			}},
+			Services: []corev1.Service{{
+				ObjectMeta: metav1.ObjectMeta{Name: "Web", Namespace: "NamespaceOne"},
+				Spec: corev1.ServiceSpec{
+					Type:                  corev1.ServiceTypeLoadBalancer,
+					ExternalTrafficPolicy: policy,
+					Ports:                 []corev1.ServicePort{{Name: "http", Port: 80, NodePort: 30080, TargetPort: intstr.FromString("http"), Protocol: corev1.ProtocolTCP}},
+				},
+			}},
+			Endpoints: []corev1.Endpoints{{
+				ObjectMeta: metav1.ObjectMeta{Name: "Web", Namespace: "NamespaceOne"},
+				Subsets: []corev1.EndpointSubset{{
+					Addresses: []corev1.EndpointAddress{
+						{IP: "10.0.0.2", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "NamespaceOne", Name: "Web"}},
+					},
+					Ports: []corev1.EndpointPort{{Name: "http", Port: 8080, Protocol: corev1.ProtocolTCP}},
+				}},
+			}},
+		}
+	}
+	query := IngressQuery{Namespace: "NamespaceOne", Service: "Web", FromCIDR: "203.0.113.0/28"}
+
+	Convey("externalTrafficPolicy Local evaluates the client CIDR", t, func() {
+		a := NewClusterApp(newSnapshot(corev1.ServiceExternalTrafficPolicyTypeLocal), "NamespaceOne")
+		es, err := a.EvaluateIngress(context.TODO(), query)
+		So(err, ShouldBeNil)
+		So(es, ShouldHaveLength, 1)
+		So(es[0].Source.GetName(), ShouldEqual, "203.0.113.0/28")
+		So(es[0].Results[0].ToPort.Num, ShouldEqual, 8080)
+		So(es[0].AllowedCount(), ShouldEqual, 1)
+
+		Convey("A CIDR partly outside the ipBlock is denied", func() {
+			q := query
+			q.FromCIDR = "203.0.112.0/23"
+			es, err := a.EvaluateIngress(context.TODO(), q)
+			So(err, ShouldBeNil)
+			So(es[0].AllowedCount(), ShouldEqual, 0)
+		})
+
+		Convey("CheckIngress summarizes the ports as a verdict", func() {
+			v := ConsoleView{Writer: bufio.NewWriter(io.Discard)}
+			verdict, err := a.CheckIngress(v, query)
+			So(err, ShouldBeNil)
+			So(verdict, ShouldEqual, VerdictAllowed)
+
+			q := query
+			q.FromCIDR = "203.0.112.0/23"
+			verdict, err = a.CheckIngress(v, q)
+			So(err, ShouldBeNil)
+			So(verdict, ShouldEqual, VerdictDenied)
+		})
+	})
+
+	Convey("externalTrafficPolicy Cluster evaluates each node", t, func() {
+		a := NewClusterApp(newSnapshot(corev1.ServiceExternalTrafficPolicyTypeCluster), "NamespaceOne")
+		es, err := a.EvaluateIngress(context.TODO(), query)
+		So(err, ShouldBeNil)
+		So(es, ShouldHaveLength, 2)
+		So(es[0].Source.GetName(), ShouldEqual, "node/NodeOne (192.168.0.1)")
+		So(es[0].Via, ShouldContainSubstring, "same node")
+		So(es[0].AllowedCount(), ShouldEqual, 0)
+		So(es[1].Source.GetName(), ShouldEqual, "node/NodeTwo (192.168.0.2)")
+		So(es[1].Via, ShouldNotContainSubstring, "same node")
+		So(es[1].AllowedCount(), ShouldEqual, 0)
+	})
+
+	Convey("A ClusterIP Service is an error", t, func() {
+		snapshot := newSnapshot("")
+		snapshot.Services[0].Spec.Type = corev1.ServiceTypeClusterIP
+		_, err := NewClusterApp(snapshot, "NamespaceOne").EvaluateIngress(context.TODO(), query)
+		So(err, ShouldBeError)
+	})
+}
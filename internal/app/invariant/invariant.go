@@ -0,0 +1,173 @@
+package invariant
+
+import (
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+type Expectation string
+
+const (
+	ExpectAllow Expectation = "allow"
+	ExpectDeny  Expectation = "deny"
+)
+
+// Config is the file format for invariants.
+//
+//	invariants:
+//	  - name: monitoring-scrapes-everything
+//	    from: {namespace: monitoring}
+//	    to: {namespaceSelector: {}}
+//	    port: 9090
+//	    expect: allow
+type Config struct {
+	Invariants []Invariant `json:"invariants"`
+}
+
+// Invariant is a statement about connectivity that must hold for every pod matching From connecting to every pod
+// matching To.
+type Invariant struct {
+	Name string `json:"name"`
+	From Peer   `json:"from"`
+	To   Peer   `json:"to"`
+	// Port is a number or name. Without one, every port declared by the destination pod is checked. A number is
+	// checked even when the destination does not declare it.
+	Port     *intstr.IntOrString `json:"port,omitempty"`
+	Protocol corev1.Protocol     `json:"protocol,omitempty"`
+	Expect   Expectation         `json:"expect"`
+}
+
+// Peer selects pods. An empty Peer selects every pod in every namespace.
+type Peer struct {
+	// Namespace is shorthand for a namespaceSelector on the name of the namespace.
+	Namespace         string                `json:"namespace,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	PodSelector       *metav1.LabelSelector `json:"podSelector,omitempty"`
+}
+
+// Violation is a source and destination port where an invariant does not hold.
+type Violation struct {
+	Invariant Invariant
+	Source    string
+	Dest      string
+	Result    eval.PortResult
+}
+
+func Load(path string) ([]Invariant, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading invariants %s: %w", path, err)
+	}
+	return Parse(bs)
+}
+
+func Parse(bs []byte) ([]Invariant, error) {
+	config := Config{}
+	if err := yaml.UnmarshalStrict(bs, &config); err != nil {
+		return nil, fmt.Errorf("error parsing invariants: %w", err)
+	}
+
+	for i, inv := range config.Invariants {
+		if inv.Name == "" {
+			return nil, fmt.Errorf("invariant %d has no name", i)
+		}
+		if inv.Expect != ExpectAllow && inv.Expect != ExpectDeny {
+			return nil, fmt.Errorf("invariant %s must expect %s or %s, not \"%s\"", inv.Name, ExpectAllow, ExpectDeny, inv.Expect)
+		}
+		if inv.From.Namespace != "" && inv.From.NamespaceSelector != nil || inv.To.Namespace != "" && inv.To.NamespaceSelector != nil {
+			return nil, fmt.Errorf("invariant %s may specify namespace or namespaceSelector, but not both", inv.Name)
+		}
+	}
+	return config.Invariants, nil
+}
+
+func (p Peer) Matches(pod *eval.PodConnection) bool {
+	if p.Namespace != "" && !pod.IsInNamespace(p.Namespace) {
+		return false
+	}
+	if p.NamespaceSelector != nil && !pod.MatchNamespaceSelector(*p.NamespaceSelector) {
+		return false
+	}
+	if p.PodSelector != nil && !pod.MatchPodSelector(*p.PodSelector) {
+		return false
+	}
+	return true
+}
+
+// Ports narrows dest to the ports the invariant checks. A numeric port is evaluated even when dest does not declare it,
+// as TCP unless the invariant has a protocol, since a container may listen on ports it does not declare.
+func (inv Invariant) Ports(dest *eval.PodConnection) *eval.PodConnection {
+	if inv.Port == nil || inv.Port.Type == intstr.String {
+		return dest
+	}
+	narrowed, err := dest.ForPorts(inv.Port.String(), inv.Protocol)
+	if err != nil {
+		// Not declared and no protocol to evaluate it as an undeclared port with
+		return dest.ForPort(inv.Port.IntVal, corev1.ProtocolTCP)
+	}
+	return narrowed
+}
+
+// AppliesTo is true when the invariant describes toPort.
+func (inv Invariant) AppliesTo(toPort eval.DestinationPort) bool {
+	if inv.Protocol != "" && inv.Protocol != eval.ProtocolOrTCP(toPort.Protocol) {
+		return false
+	}
+	if inv.Port == nil {
+		return true
+	}
+	if inv.Port.Type == intstr.String {
+		return inv.Port.StrVal == toPort.Name
+	}
+	return inv.Port.IntVal == toPort.Num
+}
+
+// Check compares the results of evaluating source connecting to dest against the invariant.
+func (inv Invariant) Check(source, dest eval.ConnectionSide, results []eval.PortResult) []Violation {
+	violations := make([]Violation, 0)
+	for _, pr := range results {
+		if !inv.AppliesTo(pr.ToPort) {
+			continue
+		}
+		if pr.Allowed != (inv.Expect == ExpectAllow) {
+			violations = append(violations, Violation{
+				Invariant: inv,
+				Source:    source.GetName(),
+				Dest:      dest.GetName(),
+				Result:    pr,
+			})
+		}
+	}
+	return violations
+}
+
+// Key identifies the violation independent of the policies that caused it.
+func (v Violation) Key() string {
+	return fmt.Sprintf("%s %s %s %d/%s", v.Invariant.Name, v.Source, v.Dest, v.Result.ToPort.Num, v.Result.ToPort.Protocol)
+}
+
+// Message explains the violation in terms of the policies that allowed or denied the connection.
+func (v Violation) Message() string {
+	outcome := "denied"
+	if v.Result.Allowed {
+		outcome = "allowed"
+	}
+	return fmt.Sprintf(
+		"invariant %s (expect %s) violated: %s -> %s port %d/%s is %s. Egress %s. Ingress %s.",
+		v.Invariant.Name,
+		v.Invariant.Expect,
+		v.Source,
+		v.Dest,
+		v.Result.ToPort.Num,
+		v.Result.ToPort.Protocol,
+		outcome,
+		eval.Explain(v.Result.EgressAllowed, v.Result.Egress),
+		eval.Explain(v.Result.IngressAllowed, v.Result.Ingress))
+}
@@ -0,0 +1,115 @@
+package invariant
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+func TestParse(t *testing.T) {
+	Convey("Parses ports by number or name", t, func() {
+		invariants, err := Parse([]byte(`
+invariants:
+  - name: by-number
+    from: {namespace: monitoring}
+    to: {}
+    port: 9090
+    expect: allow
+  - name: by-name
+    from: {}
+    to: {podSelector: {matchLabels: {app: db}}}
+    port: postgres
+    protocol: TCP
+    expect: deny
+`))
+		So(err, ShouldBeNil)
+		So(invariants, ShouldHaveLength, 2)
+		So(invariants[0].Port.IntVal, ShouldEqual, 9090)
+		So(invariants[1].Port.StrVal, ShouldEqual, "postgres")
+		So(invariants[1].To.PodSelector.MatchLabels, ShouldResemble, map[string]string{"app": "db"})
+	})
+
+	Convey("Requires an expectation", t, func() {
+		_, err := Parse([]byte("invariants:\n  - name: no-expect\n"))
+		So(err, ShouldBeError)
+	})
+
+	Convey("Rejects unknown fields", t, func() {
+		_, err := Parse([]byte("invariants:\n  - name: typo\n    expect: allow\n    form: {}\n"))
+		So(err, ShouldBeError)
+	})
+}
+
+func TestCheck(t *testing.T) {
+	makeConnection := func(name, namespace string) *eval.PodConnection {
+		conn, err := eval.NewPodConnection(
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": name}},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Ports: []corev1.ContainerPort{
+					{Name: "metrics", ContainerPort: 9090, Protocol: corev1.ProtocolTCP},
+					{Name: "api", ContainerPort: 3000, Protocol: corev1.ProtocolTCP},
+				}}}},
+				Status: corev1.PodStatus{PodIP: "10.0.0.1"},
+			},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}},
+			[]nwv1.NetworkPolicy{},
+			"")
+		So(err, ShouldBeNil)
+		return conn
+	}
+
+	Convey("Peers select by namespace and pod labels", t, func() {
+		prom := makeConnection("prometheus", "monitoring")
+		So(Peer{}.Matches(prom), ShouldBeTrue)
+		So(Peer{Namespace: "monitoring"}.Matches(prom), ShouldBeTrue)
+		So(Peer{Namespace: "payments"}.Matches(prom), ShouldBeFalse)
+		So(Peer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}}.Matches(prom), ShouldBeFalse)
+	})
+
+	Convey("Only the invariant's port is checked", t, func() {
+		source := makeConnection("prometheus", "monitoring")
+		dest := makeConnection("db", "payments")
+		results := eval.Eval(source, dest)
+
+		port := intstr.FromInt(9090)
+		inv := Invariant{Name: "metrics-denied", Port: &port, Expect: ExpectDeny}
+		violations := inv.Check(source, dest, results)
+		So(violations, ShouldHaveLength, 1)
+		So(violations[0].Result.ToPort.Num, ShouldEqual, 9090)
+		So(violations[0].Message(), ShouldEqual, "invariant metrics-denied (expect deny) violated: monitoring/prometheus -> payments/db port 9090/TCP is allowed. Egress allowed (no matching policies). Ingress allowed (no matching policies).")
+
+		inv.Expect = ExpectAllow
+		So(inv.Check(source, dest, results), ShouldBeEmpty)
+	})
+
+	Convey("A numeric port the destination does not declare is still checked", t, func() {
+		source := makeConnection("prometheus", "monitoring")
+		dest := makeConnection("db", "payments")
+
+		port := intstr.FromInt(5432)
+		inv := Invariant{Name: "db-denied", Port: &port, Expect: ExpectDeny}
+		violations := inv.Check(source, dest, eval.Eval(source, inv.Ports(dest)))
+		So(violations, ShouldHaveLength, 1)
+		So(violations[0].Result.ToPort.Num, ShouldEqual, 5432)
+		So(violations[0].Result.ToPort.Protocol, ShouldEqual, corev1.ProtocolTCP)
+		So(violations[0].Result.ToPort.Undeclared, ShouldBeTrue)
+
+		inv.Protocol = corev1.ProtocolUDP
+		violations = inv.Check(source, dest, eval.Eval(source, inv.Ports(dest)))
+		So(violations, ShouldHaveLength, 1)
+		So(violations[0].Result.ToPort.Protocol, ShouldEqual, corev1.ProtocolUDP)
+	})
+
+	Convey("A port without a protocol is TCP", t, func() {
+		inv := Invariant{Protocol: corev1.ProtocolTCP}
+		So(inv.AppliesTo(eval.DestinationPort{Num: 80}), ShouldBeTrue)
+		inv.Protocol = corev1.ProtocolUDP
+		So(inv.AppliesTo(eval.DestinationPort{Num: 80}), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,323 @@
+package labelspace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// Requirement constrains one label. Any requirement other than Absent also requires the label be present unless
+// OrAbsent, the same as netpoleval.MatchLabelSelector.
+type Requirement struct {
+	Key    string
+	Absent bool
+	// In is the values the label may have. nil means any value.
+	In    []string
+	NotIn []string
+	// OrAbsent also matches labels without the key, as a NotIn selector does. Only set when In is nil.
+	OrAbsent bool
+}
+
+func (r Requirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+	if r.Absent {
+		return !ok
+	}
+	if !ok {
+		return r.OrAbsent
+	}
+	if r.In != nil && !util.Contains(r.In, v) {
+		return false
+	}
+	return !util.Contains(r.NotIn, v)
+}
+
+// implies is true when every label set r matches is also matched by o.
+func (r Requirement) implies(o Requirement) bool {
+	if r.Absent {
+		return o.Absent || o.OrAbsent
+	}
+	if o.Absent || r.OrAbsent && !o.OrAbsent {
+		return false
+	}
+	if o.In != nil {
+		if r.In == nil {
+			return false
+		}
+		for _, v := range r.In {
+			if !util.Contains(o.In, v) {
+				return false
+			}
+		}
+	}
+	for _, v := range o.NotIn {
+		if r.In != nil {
+			if util.Contains(r.In, v) {
+				return false
+			}
+		} else if !util.Contains(r.NotIn, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// negate is the requirements, any one of which matches the labels r does not.
+func (r Requirement) negate() []Requirement {
+	switch {
+	case r.Absent:
+		return []Requirement{{Key: r.Key}}
+	case r.In != nil:
+		return []Requirement{{Key: r.Key, NotIn: r.In, OrAbsent: true}}
+	case r.OrAbsent && len(r.NotIn) > 0:
+		return []Requirement{{Key: r.Key, In: r.NotIn}}
+	case r.OrAbsent:
+		// Matches every label set
+		return nil
+	case len(r.NotIn) > 0:
+		return []Requirement{{Key: r.Key, In: r.NotIn}, {Key: r.Key, Absent: true}}
+	}
+	return []Requirement{{Key: r.Key, Absent: true}}
+}
+
+func (r Requirement) String() string {
+	if !r.Absent && r.In == nil && !r.OrAbsent && len(r.NotIn) > 0 {
+		// The selector syntax for the key being present, since key!=value alone also matches its absence
+		return r.Key + ", " + Requirement{Key: r.Key, NotIn: r.NotIn, OrAbsent: true}.String()
+	}
+	switch {
+	case r.Absent:
+		return "!" + r.Key
+	case len(r.In) == 1:
+		return r.Key + "=" + r.In[0]
+	case r.In != nil:
+		return fmt.Sprintf("%s in (%s)", r.Key, strings.Join(r.In, ","))
+	case len(r.NotIn) == 1:
+		return r.Key + "!=" + r.NotIn[0]
+	case len(r.NotIn) > 1:
+		return fmt.Sprintf("%s notin (%s)", r.Key, strings.Join(r.NotIn, ","))
+	}
+	return r.Key
+}
+
+// merge is the requirement matching what both r and o match. ok is false when nothing can.
+func merge(r, o Requirement) (Requirement, bool) {
+	if r.Absent || o.Absent {
+		absent := Requirement{Key: r.Key, Absent: true}
+		return absent, absent.implies(r) && absent.implies(o)
+	}
+
+	m := Requirement{Key: r.Key, In: r.In, OrAbsent: r.OrAbsent && o.OrAbsent}
+	if o.In != nil {
+		if m.In == nil {
+			m.In = o.In
+		} else {
+			m.In = util.Filter(m.In, func(v string) bool { return util.Contains(o.In, v) })
+		}
+	}
+	notIn := sortedUnique(append(append([]string{}, r.NotIn...), o.NotIn...))
+	if m.In != nil {
+		m.In = sortedUnique(util.Filter(m.In, func(v string) bool { return !util.Contains(notIn, v) }))
+		return m, len(m.In) > 0
+	}
+	if len(notIn) > 0 {
+		m.NotIn = notIn
+	}
+	return m, true
+}
+
+// Term is the set of pods whose labels meet every requirement. An empty Term is every pod.
+type Term struct {
+	// Requirements are sorted by key with at most one per key.
+	Requirements []Requirement
+	// Via names the policies that allow the pods.
+	Via []string
+}
+
+func (t Term) Matches(labels map[string]string) bool {
+	for _, r := range t.Requirements {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsBroad is true when the term admits pods without naming a single label value, like every pod with an app label.
+func (t Term) IsBroad() bool {
+	for _, r := range t.Requirements {
+		if r.In != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// and is the term matching what both t and o match. ok is false when nothing can.
+func (t Term) and(o Term) (Term, bool) {
+	byKey := make(map[string]Requirement)
+	for _, r := range t.Requirements {
+		byKey[r.Key] = r
+	}
+	for _, r := range o.Requirements {
+		if existing, found := byKey[r.Key]; found {
+			merged, ok := merge(existing, r)
+			if !ok {
+				return Term{}, false
+			}
+			byKey[r.Key] = merged
+		} else {
+			byKey[r.Key] = r
+		}
+	}
+	return newTerm(byKey, sortedUnique(append(append([]string{}, t.Via...), o.Via...))), true
+}
+
+// implies is true when every pod t matches is also matched by o.
+func (t Term) implies(o Term) bool {
+	for _, or := range o.Requirements {
+		found := false
+		for _, tr := range t.Requirements {
+			if tr.Key == or.Key {
+				found = tr.implies(or)
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (t Term) String() string {
+	if len(t.Requirements) == 0 {
+		return "any pod"
+	}
+	return strings.Join(util.Map(t.Requirements, Requirement.String), ", ")
+}
+
+func newTerm(byKey map[string]Requirement, via []string) Term {
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return Term{Requirements: util.Map(keys, func(k string) Requirement { return byKey[k] }), Via: via}
+}
+
+// DNF is a union of terms. An empty DNF matches nothing.
+type DNF []Term
+
+// Everything matches every pod.
+func Everything(via ...string) DNF {
+	return DNF{{Via: via}}
+}
+
+// FromSelector converts a label selector to the single term it describes, or nothing when its requirements conflict.
+func FromSelector(selector metav1.LabelSelector, via ...string) DNF {
+	t := Term{Via: via}
+	for k, v := range selector.MatchLabels {
+		var ok bool
+		if t, ok = t.and(Term{Requirements: []Requirement{{Key: k, In: []string{v}}}}); !ok {
+			return DNF{}
+		}
+	}
+	for _, e := range selector.MatchExpressions {
+		r := Requirement{Key: e.Key}
+		switch e.Operator {
+		case metav1.LabelSelectorOpIn:
+			r.In = sortedUnique(e.Values)
+		case metav1.LabelSelectorOpNotIn:
+			r.NotIn = sortedUnique(e.Values)
+			r.OrAbsent = true
+		case metav1.LabelSelectorOpDoesNotExist:
+			r.Absent = true
+		}
+		var ok bool
+		if t, ok = t.and(Term{Requirements: []Requirement{r}}); !ok {
+			return DNF{}
+		}
+	}
+	return DNF{t}
+}
+
+func (d DNF) Matches(labels map[string]string) bool {
+	for _, t := range d {
+		if t.Matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d DNF) Or(o DNF) DNF {
+	return append(append(DNF{}, d...), o...).simplify()
+}
+
+func (d DNF) And(o DNF) DNF {
+	product := DNF{}
+	for _, t := range d {
+		for _, u := range o {
+			if tu, ok := t.and(u); ok {
+				product = append(product, tu)
+			}
+		}
+	}
+	return product.simplify()
+}
+
+// Not matches the pods d does not. Via is dropped because the result is not allowed by any policy.
+func (d DNF) Not() DNF {
+	result := Everything()
+	for _, t := range d {
+		negated := DNF{}
+		for _, r := range t.Requirements {
+			for _, nr := range r.negate() {
+				negated = append(negated, Term{Requirements: []Requirement{nr}})
+			}
+		}
+		result = result.And(negated)
+	}
+	return result
+}
+
+func (d DNF) String() string {
+	if len(d) == 0 {
+		return "nothing"
+	}
+	return strings.Join(util.Map(d, Term.String), " or ")
+}
+
+// simplify drops terms another term already covers, keeping the first of equal terms.
+func (d DNF) simplify() DNF {
+	simplified := DNF{}
+	for i, t := range d {
+		covered := false
+		for j, u := range d {
+			if i != j && t.implies(u) && (!u.implies(t) || j < i) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			simplified = append(simplified, t)
+		}
+	}
+	return simplified
+}
+
+func sortedUnique(values []string) []string {
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !util.Contains(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,161 @@
+package labelspace
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+func TestDNF(t *testing.T) {
+	Convey("FromSelector", t, func() {
+		d := FromSelector(metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "api"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "tier", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"batch"}},
+				{Key: "canary", Operator: metav1.LabelSelectorOpDoesNotExist},
+			},
+		})
+		So(d, ShouldHaveLength, 1)
+		So(d[0].String(), ShouldEqual, "app=api, !canary, tier!=batch")
+		So(d.Matches(map[string]string{"app": "api", "tier": "web"}), ShouldBeTrue)
+		So(d.Matches(map[string]string{"app": "api", "tier": "batch"}), ShouldBeFalse)
+		So(d.Matches(map[string]string{"app": "api"}), ShouldBeTrue)
+
+		Convey("Conflicting requirements match nothing", func() {
+			conflict := FromSelector(metav1.LabelSelector{
+				MatchLabels:      map[string]string{"app": "api"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: metav1.LabelSelectorOpIn, Values: []string{"web"}}},
+			})
+			So(conflict, ShouldBeEmpty)
+		})
+	})
+
+	Convey("And, Or and Not", t, func() {
+		api := FromSelector(metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}})
+		anyApp := FromSelector(metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: metav1.LabelSelectorOpExists}}})
+
+		So(api.Or(anyApp).String(), ShouldEqual, anyApp.String())
+		So(api.And(anyApp).String(), ShouldEqual, api.String())
+		So(api.Not().String(), ShouldEqual, "app!=api")
+		So(api.Not().Matches(map[string]string{}), ShouldBeTrue)
+		So(api.Not().Not().String(), ShouldEqual, api.String())
+		So(anyApp.Not().String(), ShouldEqual, "!app")
+		So(api.And(api.Not()), ShouldBeEmpty)
+		So(Everything().Not(), ShouldBeEmpty)
+		So(Everything().Not().String(), ShouldEqual, "nothing")
+		So(anyApp[0].IsBroad(), ShouldBeTrue)
+		So(api[0].IsBroad(), ShouldBeFalse)
+	})
+}
+
+func TestSources(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	port := intstr.FromInt(5432)
+	payments := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"team": "payments"}}}
+	web := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"team": "web"}}}
+
+	allowDB := nwv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "allow-db", Namespace: "payments"},
+		Spec: nwv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+			Ingress: []nwv1.NetworkPolicyIngressRule{{
+				Ports: []nwv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}},
+				From: []nwv1.NetworkPolicyPeer{
+					{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}},
+					{
+						NamespaceSelector: &metav1.LabelSelector{},
+						PodSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+							{Key: "app", Operator: metav1.LabelSelectorOpExists},
+							{Key: "role", Operator: metav1.LabelSelectorOpIn, Values: []string{"migrate", "backup"}},
+						}},
+					},
+					{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "web"}}, PodSelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: metav1.LabelSelectorOpExists}},
+					}},
+				},
+			}},
+		},
+	}
+	webEgress := nwv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "frontend-egress", Namespace: "web"},
+		Spec: nwv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+			PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeEgress},
+		},
+	}
+
+	db, err := eval.NewPodConnection(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "payments", Labels: map[string]string{"app": "db"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Ports: []corev1.ContainerPort{{ContainerPort: 5432, Protocol: tcp}}}}},
+		Status:     corev1.PodStatus{PodIP: "10.0.0.1"},
+	}, payments, []nwv1.NetworkPolicy{allowDB}, "5432")
+	if err != nil {
+		panic(err.Error())
+	}
+	toPort := db.GetPorts()[0]
+
+	Convey("Sources", t, func() {
+		Convey("In the policy's namespace", func() {
+			s := Sources(db, toPort, payments, []nwv1.NetworkPolicy{allowDB})
+			So(s.Sources.String(), ShouldEqual, "app=api or app, role in (backup,migrate)")
+			So(s.Broad(), ShouldBeEmpty)
+		})
+
+		Convey("Finds broad terms and applies egress policies", func() {
+			s := Sources(db, toPort, web, []nwv1.NetworkPolicy{webEgress})
+			So(s.Sources.String(), ShouldEqual, "app, app!=frontend")
+			So(s.Broad(), ShouldHaveLength, 1)
+			So(s.Broad()[0].Via, ShouldResemble, []string{"egress not isolated", "ingress payments/allow-db"})
+		})
+
+		Convey("A rule without from allows every source", func() {
+			allowAll := nwv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "allow-all", Namespace: "payments"},
+				Spec: nwv1.NetworkPolicySpec{
+					PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+					Ingress:     []nwv1.NetworkPolicyIngressRule{{}},
+				},
+			}
+			open, err := eval.NewPodConnection(db.Pod, payments, []nwv1.NetworkPolicy{allowAll}, "5432")
+			So(err, ShouldBeNil)
+			s := Sources(open, open.GetPorts()[0], web, nil)
+			So(s.Sources.Matches(map[string]string{}), ShouldBeTrue)
+			So(s.IPBlocks, ShouldResemble, []string{"ingress payments/allow-all allows every address"})
+		})
+
+		Convey("Agrees with Eval for concrete pods", func() {
+			labelSets := []map[string]string{
+				{},
+				{"app": "api"},
+				{"app": "frontend"},
+				{"app": "frontend", "role": "backup"},
+				{"app": "worker", "role": "migrate"},
+				{"app": "worker", "role": "other"},
+				{"role": "backup"},
+				{"role": "migrate"},
+			}
+			for _, ns := range []*corev1.Namespace{payments, web} {
+				policies := []nwv1.NetworkPolicy{allowDB}
+				if ns == web {
+					policies = []nwv1.NetworkPolicy{webEgress}
+				}
+				s := Sources(db, toPort, ns, policies)
+				for _, labels := range labelSets {
+					source, err := eval.NewPodConnection(&corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: ns.Name, Labels: labels},
+						Status:     corev1.PodStatus{PodIP: "10.0.0.2"},
+					}, ns, policies, "")
+					So(err, ShouldBeNil)
+					So(s.Sources.Matches(labels), ShouldEqual, eval.Eval(source, db)[0].Allowed)
+				}
+			}
+		})
+	})
+}
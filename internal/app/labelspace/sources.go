@@ -0,0 +1,129 @@
+package labelspace
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// NamespaceSources describes the pods of one namespace that can connect to a destination port, whether or not they
+// exist yet.
+type NamespaceSources struct {
+	Namespace string
+	Port      eval.DestinationPort
+	Sources   DNF
+	// IPBlocks allow pods by address rather than labels, so they are listed instead of described.
+	IPBlocks []string
+}
+
+// Broad finds the terms that admit pods without naming a label value, like every pod with an app label.
+func (s NamespaceSources) Broad() []Term {
+	return util.Filter(s.Sources, Term.IsBroad)
+}
+
+// Sources describes the labels of the pods in namespace that can connect to toPort on dest. namespacePolicies are the
+// policies of namespace, which decide egress for its pods. The description follows the same rules as netpoleval.Eval,
+// so a concrete pod is allowed exactly when its labels match.
+func Sources(dest *eval.PodConnection, toPort eval.DestinationPort, namespace *corev1.Namespace, namespacePolicies []nwv1.NetworkPolicy) NamespaceSources {
+	ingress, ipBlocks := ingressSources(dest, toPort, namespace)
+	egress := egressSources(dest, toPort, namespacePolicies)
+	return NamespaceSources{
+		Namespace: namespace.Name,
+		Port:      toPort,
+		Sources:   ingress.And(egress),
+		IPBlocks:  ipBlocks,
+	}
+}
+
+// ingressSources are the pods in namespace that dest's ingress policies allow.
+func ingressSources(dest *eval.PodConnection, toPort eval.DestinationPort, namespace *corev1.Namespace) (DNF, []string) {
+	selected := false
+	allowed := DNF{}
+	ipBlocks := make([]string, 0)
+	for _, np := range dest.GetPolicies() {
+		if !util.Contains(np.Spec.PolicyTypes, nwv1.PolicyTypeIngress) || !dest.MatchPodSelector(np.Spec.PodSelector) {
+			continue
+		}
+		selected = true
+		via := fmt.Sprintf("ingress %s/%s", np.Namespace, np.Name)
+
+		for _, rule := range np.Spec.Ingress {
+			if !portsContain(rule.Ports, toPort) {
+				continue
+			}
+			if len(rule.From) == 0 {
+				// A rule without from allows every source, in or out of the cluster
+				ipBlocks = append(ipBlocks, fmt.Sprintf("%s allows every address", via))
+				allowed = allowed.Or(Everything(via))
+				continue
+			}
+			for _, peer := range rule.From {
+				if peer.IPBlock != nil {
+					ipBlocks = append(ipBlocks, fmt.Sprintf("%s allows %s", via, peer.IPBlock.CIDR))
+					continue
+				}
+				if !matchNamespace(np.Namespace, peer, namespace) {
+					continue
+				}
+				if peer.PodSelector == nil {
+					allowed = allowed.Or(Everything(via))
+				} else {
+					allowed = allowed.Or(FromSelector(*peer.PodSelector, via))
+				}
+			}
+		}
+	}
+	if !selected {
+		return Everything(fmt.Sprintf("ingress to %s not isolated", dest.GetName())), ipBlocks
+	}
+	return allowed, ipBlocks
+}
+
+// egressSources are the pods that either no egress policy of namespacePolicies selects, or that a selecting policy
+// allows to connect to toPort on dest.
+func egressSources(dest *eval.PodConnection, toPort eval.DestinationPort, namespacePolicies []nwv1.NetworkPolicy) DNF {
+	selectors := DNF{}
+	allowed := DNF{}
+	for _, np := range namespacePolicies {
+		if !util.Contains(np.Spec.PolicyTypes, nwv1.PolicyTypeEgress) {
+			continue
+		}
+		selectors = selectors.Or(FromSelector(np.Spec.PodSelector))
+
+		for _, rule := range np.Spec.Egress {
+			if eval.MatchPeers(np.Namespace, rule.To, dest) && portsContain(rule.Ports, toPort) {
+				allowed = allowed.Or(FromSelector(np.Spec.PodSelector, fmt.Sprintf("egress %s/%s", np.Namespace, np.Name)))
+				break
+			}
+		}
+	}
+
+	notSelected := selectors.Not()
+	for i := range notSelected {
+		notSelected[i].Via = []string{"egress not isolated"}
+	}
+	return notSelected.Or(allowed)
+}
+
+func matchNamespace(policyNamespace string, peer nwv1.NetworkPolicyPeer, namespace *corev1.Namespace) bool {
+	if peer.NamespaceSelector == nil {
+		return namespace.Name == policyNamespace
+	}
+	return eval.MatchLabelSelector(*peer.NamespaceSelector, namespace.Labels)
+}
+
+func portsContain(ports []nwv1.NetworkPolicyPort, toPort eval.DestinationPort) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, p := range ports {
+		if eval.PortContains(p, toPort) {
+			return true
+		}
+	}
+	return false
+}
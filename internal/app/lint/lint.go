@@ -0,0 +1,217 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// WidePortRange is the number of ports a range must cover to be reported.
+const WidePortRange = 1000
+
+type Severity uint8
+
+const (
+	SeverityLow Severity = iota
+	SeverityMedium
+	SeverityHigh
+)
+
+func (s Severity) String() string {
+	return []string{"low", "medium", "high"}[s]
+}
+
+func ParseSeverity(s string) (Severity, error) {
+	switch s {
+	case "low":
+		return SeverityLow, nil
+	case "medium":
+		return SeverityMedium, nil
+	case "high":
+		return SeverityHigh, nil
+	}
+	return SeverityLow, fmt.Errorf("unknown severity %s", s)
+}
+
+// Check identifies the kind of problem a Finding reports.
+type Check string
+
+const (
+	CheckAllPeers      Check = "all-peers"
+	CheckAllPods       Check = "all-pods-all-namespaces"
+	CheckAnyIP         Check = "any-ip"
+	CheckWidePortRange Check = "wide-port-range"
+	CheckAllPorts      Check = "all-ports"
+)
+
+// Description explains why the check matters, for tools that list the checks separately from findings.
+func (c Check) Description() string {
+	switch c {
+	case CheckAllPeers:
+		return "A rule without from or to allows every peer, including those outside the cluster."
+	case CheckAllPods:
+		return "An empty namespaceSelector with an empty or missing podSelector allows every pod in every namespace."
+	case CheckAnyIP:
+		return "An ipBlock of 0.0.0.0/0 or ::/0 without except allows every IP address."
+	case CheckWidePortRange:
+		return fmt.Sprintf("A port range covering %d or more ports.", WidePortRange)
+	case CheckAllPorts:
+		return "A rule or port without a port number allows every port."
+	}
+	return string(c)
+}
+
+// Checks lists every check Lint runs.
+var Checks = []Check{CheckAllPeers, CheckAnyIP, CheckAllPods, CheckWidePortRange, CheckAllPorts}
+
+// Finding is a rule of a NetworkPolicy that grants broad access. Peer and Port index into the rule's peers and ports,
+// or are -1 when the finding is about the rule as a whole.
+type Finding struct {
+	Namespace string
+	Policy    string
+	Direction nwv1.PolicyType
+	Rule      int
+	Peer      int
+	Port      int
+	Check     Check
+	Severity  Severity
+	Message   string
+}
+
+// Location is where in the policy the finding is, for example ingress[0].from[1].
+func (f Finding) Location() string {
+	peers := "from"
+	direction := "ingress"
+	if f.Direction == nwv1.PolicyTypeEgress {
+		peers = "to"
+		direction = "egress"
+	}
+	loc := fmt.Sprintf("%s[%d]", direction, f.Rule)
+	if f.Peer >= 0 {
+		loc += fmt.Sprintf(".%s[%d]", peers, f.Peer)
+	}
+	if f.Port >= 0 {
+		loc += fmt.Sprintf(".ports[%d]", f.Port)
+	}
+	return loc
+}
+
+// Path is the location of the finding as the fields of a NetworkPolicy manifest, for example
+// "spec", "ingress", 0, "from", 1.
+func (f Finding) Path() []any {
+	peers := "from"
+	direction := "ingress"
+	if f.Direction == nwv1.PolicyTypeEgress {
+		peers = "to"
+		direction = "egress"
+	}
+	path := []any{"spec", direction, f.Rule}
+	if f.Peer >= 0 {
+		path = append(path, peers, f.Peer)
+	}
+	if f.Port >= 0 {
+		path = append(path, "ports", f.Port)
+	}
+	return path
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s/%s %s: %s", f.Severity, f.Namespace, f.Policy, f.Location(), f.Message)
+}
+
+// Lint checks every rule of the policies and returns the findings, most severe first.
+func Lint(netpols []nwv1.NetworkPolicy) []Finding {
+	findings := make([]Finding, 0)
+	for _, np := range netpols {
+		for i, rule := range np.Spec.Ingress {
+			findings = append(findings, lintRule(np, nwv1.PolicyTypeIngress, i, rule.From, rule.Ports)...)
+		}
+		for i, rule := range np.Spec.Egress {
+			findings = append(findings, lintRule(np, nwv1.PolicyTypeEgress, i, rule.To, rule.Ports)...)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Policy < b.Policy
+	})
+	return findings
+}
+
+// lintRule checks one ingress or egress rule. Kubernetes ignores rules of a type the policy does not list in
+// policyTypes, but they are still checked because adding the type later would enable them.
+func lintRule(np nwv1.NetworkPolicy, direction nwv1.PolicyType, ruleIndex int, peers []nwv1.NetworkPolicyPeer, ports []nwv1.NetworkPolicyPort) []Finding {
+	findings := make([]Finding, 0)
+	add := func(peer, port int, check Check, severity Severity, message string) {
+		findings = append(findings, Finding{
+			Namespace: np.Namespace,
+			Policy:    np.Name,
+			Direction: direction,
+			Rule:      ruleIndex,
+			Peer:      peer,
+			Port:      port,
+			Check:     check,
+			Severity:  severity,
+			Message:   message,
+		})
+	}
+
+	other := "source"
+	if direction == nwv1.PolicyTypeEgress {
+		other = "destination"
+	}
+
+	if len(peers) == 0 {
+		add(-1, -1, CheckAllPeers, SeverityHigh, fmt.Sprintf("no peers allows every %s, including those outside the cluster", other))
+	}
+	for i, peer := range peers {
+		if peer.IPBlock != nil {
+			if (peer.IPBlock.CIDR == "0.0.0.0/0" || peer.IPBlock.CIDR == "::/0") && len(peer.IPBlock.Except) == 0 {
+				add(i, -1, CheckAnyIP, SeverityHigh, fmt.Sprintf("ipBlock %s without except allows every IP address", peer.IPBlock.CIDR))
+			}
+			continue
+		}
+		if isEmpty(peer.NamespaceSelector) && (peer.PodSelector == nil || isEmpty(peer.PodSelector)) {
+			add(i, -1, CheckAllPods, SeverityMedium, fmt.Sprintf("empty namespaceSelector and podSelector allow every pod in every namespace as a %s", other))
+		}
+	}
+
+	if len(ports) == 0 {
+		add(-1, -1, CheckAllPorts, SeverityLow, "no ports allows every port and protocol")
+	}
+	for i, port := range ports {
+		if port.Port == nil {
+			add(-1, i, CheckAllPorts, SeverityLow, fmt.Sprintf("no port allows every %s port", protocolOf(port)))
+			continue
+		}
+		if port.EndPort != nil && port.Port.Type == intstr.Int {
+			span := *port.EndPort - port.Port.IntVal + 1
+			if span >= WidePortRange {
+				add(-1, i, CheckWidePortRange, SeverityMedium, fmt.Sprintf("port range %d-%d covers %d ports", port.Port.IntVal, *port.EndPort, span))
+			}
+		}
+	}
+	return findings
+}
+
+// isEmpty is true for a selector that is present and matches everything. A nil selector is not empty; in a peer it
+// means the policy's own namespace.
+func isEmpty(selector *metav1.LabelSelector) bool {
+	return selector != nil && len(selector.MatchLabels)+len(selector.MatchExpressions) == 0
+}
+
+func protocolOf(port nwv1.NetworkPolicyPort) string {
+	if port.Protocol == nil {
+		return "TCP"
+	}
+	return string(*port.Protocol)
+}
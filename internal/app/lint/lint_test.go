@@ -0,0 +1,65 @@
+package lint
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestLint(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	port80 := intstr.FromInt(80)
+	port1024 := intstr.FromInt(1024)
+	endPort := int32(65535)
+	newPolicy := func(name string, spec nwv1.NetworkPolicySpec) nwv1.NetworkPolicy {
+		return nwv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "NamespaceOne", Name: name}, Spec: spec}
+	}
+	appOne := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "one"}}
+
+	Convey("A narrow rule has no findings", t, func() {
+		findings := Lint([]nwv1.NetworkPolicy{newPolicy("Narrow", nwv1.NetworkPolicySpec{
+			Ingress: []nwv1.NetworkPolicyIngressRule{{
+				From:  []nwv1.NetworkPolicyPeer{{PodSelector: appOne}, {NamespaceSelector: &metav1.LabelSelector{}, PodSelector: appOne}},
+				Ports: []nwv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}},
+			}},
+		})})
+		So(findings, ShouldBeEmpty)
+	})
+
+	Convey("Broad rules are ranked by severity", t, func() {
+		findings := Lint([]nwv1.NetworkPolicy{
+			newPolicy("AllPorts", nwv1.NetworkPolicySpec{
+				Ingress: []nwv1.NetworkPolicyIngressRule{{From: []nwv1.NetworkPolicyPeer{{PodSelector: appOne}}}},
+			}),
+			newPolicy("Broad", nwv1.NetworkPolicySpec{
+				Egress: []nwv1.NetworkPolicyEgressRule{
+					{
+						To:    []nwv1.NetworkPolicyPeer{{PodSelector: appOne}, {NamespaceSelector: &metav1.LabelSelector{}}},
+						Ports: []nwv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}, {Protocol: &tcp, Port: &port1024, EndPort: &endPort}},
+					},
+					{
+						To:    []nwv1.NetworkPolicyPeer{{IPBlock: &nwv1.IPBlock{CIDR: "0.0.0.0/0"}}, {IPBlock: &nwv1.IPBlock{CIDR: "::/0", Except: []string{"fd00::/8"}}}},
+						Ports: []nwv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}},
+					},
+				},
+				Ingress: []nwv1.NetworkPolicyIngressRule{{Ports: []nwv1.NetworkPolicyPort{{Protocol: &tcp}}}},
+			}),
+		})
+
+		So(findings, ShouldHaveLength, 6)
+		So(findings[0].Check, ShouldEqual, CheckAllPeers)
+		So(findings[0].Location(), ShouldEqual, "ingress[0]")
+		So(findings[1].Check, ShouldEqual, CheckAnyIP)
+		So(findings[1].String(), ShouldEqual, "high NamespaceOne/Broad egress[1].to[0]: ipBlock 0.0.0.0/0 without except allows every IP address")
+		So(findings[2].Check, ShouldEqual, CheckAllPods)
+		So(findings[2].Location(), ShouldEqual, "egress[0].to[1]")
+		So(findings[3].Check, ShouldEqual, CheckWidePortRange)
+		So(findings[3].Location(), ShouldEqual, "egress[0].ports[1]")
+		So(findings[4].String(), ShouldEqual, "low NamespaceOne/AllPorts ingress[0]: no ports allows every port and protocol")
+		So(findings[5].String(), ShouldEqual, "low NamespaceOne/Broad ingress[0].ports[0]: no port allows every TCP port")
+	})
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/cheriot/netpoltool/internal/app/invariant"
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// Matrix is the result of evaluating every pod in a set of namespaces against every other pod.
+type Matrix struct {
+	Pods []*eval.PodConnection
+	// Cells[i][j] is the evaluation of Pods[i] connecting to Pods[j].
+	Cells [][]Evaluation
+	// Skipped are the reasons pods in the namespaces could not be evaluated.
+	Skipped []error
+}
+
+// Matrix evaluates all pairs of pods in namespaces. No namespaces means the default namespace.
+func (a *App) Matrix(ctx context.Context, namespaces []string) (*Matrix, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{a.defaultNamespace}
+	}
+
+	pods, skipped, err := a.queryPodConnections(ctx, namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Matrix{Pods: pods, Skipped: skipped}
+	for _, source := range pods {
+		row := make([]Evaluation, 0, len(pods))
+		for _, dest := range pods {
+			row = append(row, Evaluation{
+				Source:  source,
+				Dest:    dest,
+				Results: eval.Eval(source, dest),
+			})
+		}
+		m.Cells = append(m.Cells, row)
+	}
+	return m, nil
+}
+
+// SkippedPods are the reasons pods in namespaces could not be evaluated, found without evaluating any pairs.
+func (a *App) SkippedPods(ctx context.Context, namespaces []string) ([]error, error) {
+	_, skipped, err := a.queryPodConnections(ctx, namespaces)
+	return skipped, err
+}
+
+// WhoCanReach finds the pods in namespaces that can connect to the destination pod. No namespaces means all
+// namespaces.
+func (a *App) WhoCanReach(ctx context.Context, toNamespace, toPodName, toPort string, namespaces []string) ([]Evaluation, error) {
+	dest, err := a.queryConnectionSide(ctx, toNamespace, toPodName, toPort)
+	if err != nil {
+		return nil, fmt.Errorf("error querying destination: %w", err)
+	}
+
+	if len(namespaces) == 0 {
+		namespaces, err = a.queryNamespaceNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sources, _, err := a.queryPodConnections(ctx, namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	reaches := make([]Evaluation, 0)
+	for _, source := range sources {
+		e := Evaluation{
+			Source:  source,
+			Dest:    dest,
+			Results: eval.Eval(source, dest),
+		}
+		if e.AllowedCount() > 0 {
+			reaches = append(reaches, e)
+		}
+	}
+	return reaches, nil
+}
+
+// CheckInvariants evaluates every pair of pods each invariant selects and returns the connections that break them.
+func (a *App) CheckInvariants(ctx context.Context, invariants []invariant.Invariant) ([]invariant.Violation, error) {
+	namespaces, err := a.queryNamespaceNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, _, err := a.queryPodConnections(ctx, namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := make([]invariant.Violation, 0)
+	for _, inv := range invariants {
+		sources := util.Filter(pods, inv.From.Matches)
+		dests := util.Filter(pods, inv.To.Matches)
+		for _, source := range sources {
+			for _, dest := range dests {
+				if source == dest {
+					continue
+				}
+				violations = append(violations, inv.Check(source, dest, eval.Eval(source, inv.Ports(dest)))...)
+			}
+		}
+	}
+	return violations, nil
+}
+
+// queryNamespaceNames lists the names of all namespaces in the cluster.
+func (a *App) queryNamespaceNames(ctx context.Context) ([]string, error) {
+	nsList, err := a.cluster.QueryNamespaceList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying for namespaces: %w", err)
+	}
+	return util.Map(nsList.Items, func(ns corev1.Namespace) string { return ns.Name }), nil
+}
+
+// queryPodConnections loads every pod in namespaces along with the policies of its namespace. Pods that cannot be
+// evaluated, usually because they have not been assigned an IP yet, are skipped and the reason returned.
+func (a *App) queryPodConnections(ctx context.Context, namespaces []string) ([]*eval.PodConnection, []error, error) {
+	conns := make([]*eval.PodConnection, 0)
+	skipped := make([]error, 0)
+	for _, namespaceName := range namespaces {
+		namespace, err := a.cluster.QueryNamespace(ctx, namespaceName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error querying for namespace %s: %w", namespaceName, err)
+		}
+
+		netpolList, err := a.cluster.QueryNetPolList(ctx, namespaceName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error querying for netpol list %s: %w", namespaceName, err)
+		}
+
+		podList, err := a.cluster.QueryPodList(ctx, namespaceName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error querying for pod list %s: %w", namespaceName, err)
+		}
+
+		for i := range podList.Items {
+			conn, err := eval.NewPodConnection(&podList.Items[i], namespace, netpolList.Items, "")
+			if err != nil {
+				util.Log.Warnf("Skipping pod %s/%s: %s", namespaceName, podList.Items[i].Name, err.Error())
+				skipped = append(skipped, err)
+				continue
+			}
+			conns = append(conns, conn)
+		}
+	}
+	return conns, skipped, nil
+}
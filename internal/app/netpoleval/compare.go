@@ -4,15 +4,21 @@ import (
 	"fmt"
 	"net"
 
+	corev1 "k8s.io/api/core/v1"
 	nwv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/strings/slices"
 )
 
+// PortContains is true when rulePort includes toPort. A named rulePort is resolved against the ports the destination
+// pod declares, so it matches when toPort was declared with that name and the rule's protocol.
 func PortContains(rulePort nwv1.NetworkPolicyPort, toPort DestinationPort) bool {
-	if *rulePort.Protocol != toPort.Protocol {
-		// if nil, default to TCP
+	ruleProtocol := corev1.ProtocolTCP
+	if rulePort.Protocol != nil {
+		ruleProtocol = *rulePort.Protocol
+	}
+	if ruleProtocol != ProtocolOrTCP(toPort.Protocol) {
 		return false
 	}
 
@@ -57,7 +63,8 @@ func MatchLabelSelector(podSelector metav1.LabelSelector, podLabels map[string]s
 				return false
 			}
 		case metav1.LabelSelectorOpNotIn:
-			if !ok || slices.Contains(lrs.Values, podVal) {
+			// Also matches pods without the key
+			if ok && slices.Contains(lrs.Values, podVal) {
 				return false
 			}
 		case metav1.LabelSelectorOpExists:
@@ -73,17 +80,53 @@ func MatchLabelSelector(podSelector metav1.LabelSelector, podLabels map[string]s
 	return true
 }
 
-func MatchIPBlock(ipBlock nwv1.IPBlock, ip net.IP, ipStr string) (bool, error) {
-	for _, exceptIpStr := range ipBlock.Except {
-		if ipStr == exceptIpStr {
+// MatchIPBlock is true only when ipBlock includes every address of ipNet, a single address for pods and IPs or a range
+// for a CIDR outside the cluster, and none of them are in an except.
+func MatchIPBlock(ipBlock nwv1.IPBlock, ipNet *net.IPNet) (bool, error) {
+	_, blockNet, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse ipBlock.CIDR %s", ipBlock.CIDR)
+	}
+	if !containsNet(blockNet, ipNet) {
+		return false, nil
+	}
+
+	for _, except := range ipBlock.Except {
+		exceptNet, err := parseNet(except)
+		if err != nil {
+			return false, fmt.Errorf("unable to parse ipBlock.Except %s", except)
+		}
+		// Aligned ranges overlap only when one contains the first address of the other
+		if exceptNet.Contains(ipNet.IP) || ipNet.Contains(exceptNet.IP) {
 			return false, nil
 		}
 	}
+	return true, nil
+}
 
-	_, ipNet, err := net.ParseCIDR(ipBlock.CIDR)
+// parseNet parses a CIDR, or a single IP as the range of just that address.
+func parseNet(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		return hostNet(ip), nil
+	}
+	_, ipNet, err := net.ParseCIDR(s)
 	if err != nil {
-		return false, fmt.Errorf("unable to parse ipBlock.CIDR %s", ipBlock.CIDR)
+		return nil, err
 	}
+	return ipNet, nil
+}
+
+// hostNet is the range of the single address ip.
+func hostNet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
 
-	return ipNet.Contains(ip), nil
+// containsNet is true when outer includes every address of inner.
+func containsNet(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
 }
@@ -112,6 +112,13 @@ func TestPortContains(t *testing.T) {
 		)
 		So(isMatch, ShouldBeTrue)
 	})
+
+	Convey("Missing protocols default to TCP", t, func() {
+		undeclared := containerPort
+		undeclared.Protocol = ""
+		So(PortContains(nwv1.NetworkPolicyPort{Port: &matchingName}, undeclared), ShouldBeTrue)
+		So(PortContains(nwv1.NetworkPolicyPort{Protocol: &differentProtocol, Port: &matchingName}, undeclared), ShouldBeFalse)
+	})
 }
 
 func TestMatchLabelSelectors(t *testing.T) {
@@ -200,6 +207,10 @@ func TestMatchLabelSelectors(t *testing.T) {
 			So(MatchLabelSelector(withExpression("zone", metav1.LabelSelectorOpNotIn, []string{"web"}), podLabels), ShouldBeFalse)
 		})
 
+		Convey("Match with NotIn when the pod does not have the key.", func() {
+			So(MatchLabelSelector(withExpression("foo", metav1.LabelSelectorOpNotIn, []string{"bar"}), podLabels), ShouldBeTrue)
+		})
+
 		Convey("Unmatch with Exists.", func() {
 			So(MatchLabelSelector(withExpression("foo", metav1.LabelSelectorOpExists, []string{}), podLabels), ShouldBeFalse)
 		})
@@ -256,13 +267,13 @@ func TestMatchIPBlock(t *testing.T) {
 		outsideCidrIp := net.ParseIP(outsideCidrStr)
 
 		Convey("Matches an IP in the ipBlock", func() {
-			isMatch, err := MatchIPBlock(ipBlock, inCidrIP, inCidrStr)
+			isMatch, err := MatchIPBlock(ipBlock, hostNet(inCidrIP))
 			So(err, ShouldBeNil)
 			So(isMatch, ShouldBeTrue)
 		})
 
 		Convey("Does not match an IP outside the ipBlock", func() {
-			isMatch, err := MatchIPBlock(ipBlock, outsideCidrIp, outsideCidrStr)
+			isMatch, err := MatchIPBlock(ipBlock, hostNet(outsideCidrIp))
 			So(err, ShouldBeNil)
 			So(isMatch, ShouldBeFalse)
 		})
@@ -270,9 +281,25 @@ func TestMatchIPBlock(t *testing.T) {
 		Convey("Does not match an IP inside the ipBlock that's in the Except list", func() {
 			ipBlockExcept := ipBlock.DeepCopy()
 			ipBlockExcept.Except = []string{inCidrStr}
-			isMatch, err := MatchIPBlock(*ipBlockExcept, inCidrIP, inCidrStr)
+			isMatch, err := MatchIPBlock(*ipBlockExcept, hostNet(inCidrIP))
 			So(err, ShouldBeNil)
 			So(isMatch, ShouldBeFalse)
 		})
+
+		Convey("Does not match an IP inside a CIDR in the Except list", func() {
+			everything := nwv1.IPBlock{CIDR: "0.0.0.0/0", Except: []string{"10.0.0.0/8"}}
+			isMatch, err := MatchIPBlock(everything, hostNet(net.ParseIP("10.0.1.5")))
+			So(err, ShouldBeNil)
+			So(isMatch, ShouldBeFalse)
+
+			isMatch, err = MatchIPBlock(everything, hostNet(net.ParseIP("11.0.1.5")))
+			So(err, ShouldBeNil)
+			So(isMatch, ShouldBeTrue)
+		})
+
+		Convey("An invalid Except is an error", func() {
+			_, err := MatchIPBlock(nwv1.IPBlock{CIDR: "0.0.0.0/0", Except: []string{"10.0.0.0/33"}}, hostNet(inCidrIP))
+			So(err, ShouldBeError)
+		})
 	})
 }
@@ -30,6 +30,11 @@ type DestinationPort struct {
 	Name        string
 	Num         int32
 	Protocol    corev1.Protocol
+	// Container that declares the port, if any.
+	Container string
+	// Undeclared ports are on a pod but not in its spec. Containers may listen on them anyway, but a rule with a named
+	// port can never match one.
+	Undeclared bool
 }
 
 func NewPodConnection(pod *corev1.Pod, ns *corev1.Namespace, policies []nwv1.NetworkPolicy, portNameOrNum string) (*PodConnection, error) {
@@ -46,11 +51,11 @@ func NewPodConnection(pod *corev1.Pod, ns *corev1.Namespace, policies []nwv1.Net
 
 	var ports []DestinationPort
 	if portNameOrNum != "" {
-		p, err := portFromIdentifier(pod, portNameOrNum)
+		ps, err := portsFromIdentifier(pod, portNameOrNum)
 		if err != nil {
 			return nil, fmt.Errorf("invalid destination: %w", err)
 		}
-		ports = []DestinationPort{p}
+		ports = ps
 	} else {
 		ports = podPorts(pod)
 	}
@@ -107,7 +112,7 @@ func (c *ExternalConnection) MatchPodSelector(metav1.LabelSelector) bool {
 }
 
 func (c *ExternalConnection) MatchIPBlock(ipBlock nwv1.IPBlock) (bool, error) {
-	return MatchIPBlock(ipBlock, c.IP, c.ipStr)
+	return MatchIPBlock(ipBlock, hostNet(c.IP))
 }
 
 func (c *ExternalConnection) IsInNamespace(string) bool {
@@ -167,38 +172,169 @@ func (c *PodConnection) IsInCluster() bool {
 }
 
 func (c *PodConnection) MatchIPBlock(ipBlock nwv1.IPBlock) (bool, error) {
-	return MatchIPBlock(ipBlock, c.ip, c.Pod.Status.PodIP)
+	return MatchIPBlock(ipBlock, hostNet(c.ip))
 }
 
 func (c *PodConnection) GetPorts() []DestinationPort {
 	return c.ports
 }
 
+// ForPort copies the connection with num/protocol as its only destination port. The port keeps the name the pod
+// declares for it, if any, so rules with named ports still match.
+func (c *PodConnection) ForPort(num int32, protocol corev1.Protocol) *PodConnection {
+	port := DestinationPort{IsInCluster: true, Num: num, Protocol: protocol, Undeclared: true}
+	for _, p := range podPorts(c.Pod) {
+		if p.Num == num && ProtocolOrTCP(p.Protocol) == ProtocolOrTCP(protocol) {
+			port = p
+		}
+	}
+	conn := *c
+	conn.ports = []DestinationPort{port}
+	return &conn
+}
+
+// ForPorts copies the connection with the ports identified by a name, a number or a range like 8000-8100. With a
+// protocol, only ports of that protocol are included and numbers the pod does not declare are evaluated as undeclared
+// ports. Without one, only declared ports are.
+func (c *PodConnection) ForPorts(identifier string, protocol corev1.Protocol) (*PodConnection, error) {
+	first, last, isRange, err := ParsePortRange(identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make([]DestinationPort, 0)
+	if !isRange {
+		if num, err := strconv.Atoi(identifier); err == nil && (num < 1 || num > 65535) {
+			return nil, fmt.Errorf("invalid port %s", identifier)
+		}
+		ports, err = portsFromIdentifier(c.Pod, identifier)
+		if err != nil && protocol == "" {
+			return nil, fmt.Errorf("%w. Specify a protocol to evaluate a port the pod does not declare", err)
+		}
+		ports = util.Filter(ports, func(p DestinationPort) bool {
+			return protocol == "" || ProtocolOrTCP(p.Protocol) == protocol
+		})
+		if len(ports) == 0 && protocol != "" {
+			num, err := strconv.Atoi(identifier)
+			if err != nil {
+				return nil, fmt.Errorf("unable to find %s port %s on pod %s", protocol, identifier, c.GetName())
+			}
+			ports = append(ports, c.ForPort(int32(num), protocol).ports...)
+		}
+	} else {
+		if protocol == "" {
+			return nil, fmt.Errorf("a protocol is required to evaluate the port range %s", identifier)
+		}
+		for num := first; num <= last; num++ {
+			ports = append(ports, c.ForPort(num, protocol).ports...)
+		}
+	}
+
+	conn := *c
+	conn.ports = ports
+	return &conn, nil
+}
+
+// ParsePortRange parses a range like 8000-8100. isRange is false for anything else, like a single number or a name,
+// which may also contain a dash.
+func ParsePortRange(s string) (first, last int32, isRange bool, err error) {
+	firstStr, lastStr, found := strings.Cut(s, "-")
+	if !found {
+		return 0, 0, false, nil
+	}
+	f, firstErr := strconv.Atoi(firstStr)
+	l, lastErr := strconv.Atoi(lastStr)
+	if firstErr != nil || lastErr != nil {
+		return 0, 0, false, nil
+	}
+	if f < 1 || l > 65535 || l < f {
+		return 0, 0, false, fmt.Errorf("invalid port range %s", s)
+	}
+	return int32(f), int32(l), true, nil
+}
+
+// AmbiguousPortNames describes each port name the pod declares for more than one number of the same protocol. A rule
+// naming the port matches all of them, as CNIs that resolve named ports per pod do, which is unlikely to be intended.
+func (c *PodConnection) AmbiguousPortNames() []string {
+	type nameProtocol struct {
+		name     string
+		protocol corev1.Protocol
+	}
+	declared := make(map[nameProtocol][]DestinationPort)
+	order := make([]nameProtocol, 0)
+	for _, p := range podPorts(c.Pod) {
+		if p.Name == "" {
+			continue
+		}
+		key := nameProtocol{p.Name, ProtocolOrTCP(p.Protocol)}
+		if _, ok := declared[key]; !ok {
+			order = append(order, key)
+		}
+		declared[key] = append(declared[key], p)
+	}
+
+	ambiguous := make([]string, 0)
+	for _, key := range order {
+		ports := declared[key]
+		if len(ports) < 2 {
+			continue
+		}
+		descriptions := util.Map(ports, func(p DestinationPort) string {
+			return fmt.Sprintf("%d in container %s", p.Num, p.Container)
+		})
+		ambiguous = append(ambiguous, fmt.Sprintf(
+			"port name %s/%s is declared more than once on pod %s: %s",
+			key.name, key.protocol, c.GetName(), strings.Join(descriptions, ", ")))
+	}
+	return ambiguous
+}
+
+// podPorts are the ports declared by the pod's containers and its sidecars, the init containers with restartPolicy
+// Always that run for the life of the pod. Other init containers exit before the pod starts.
 func podPorts(pod *corev1.Pod) []DestinationPort {
 	ports := make([]DestinationPort, 0)
-	for _, c := range pod.Spec.Containers {
+	containers := append([]corev1.Container{}, pod.Spec.Containers...)
+	for _, c := range pod.Spec.InitContainers {
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			containers = append(containers, c)
+		}
+	}
+	for _, c := range containers {
 		for _, p := range c.Ports {
 			ports = append(ports, DestinationPort{
 				IsInCluster: true,
 				Name:        p.Name,
 				Num:         p.ContainerPort,
 				Protocol:    p.Protocol,
+				Container:   c.Name,
 			})
 		}
 	}
 	return ports
 }
 
-func portFromIdentifier(pod *corev1.Pod, nameOrNum string) (DestinationPort, error) {
+// portsFromIdentifier finds every declared port with the name or number. A number may be declared for more than one
+// protocol, like DNS on 53/UDP and 53/TCP, and a name by more than one container.
+func portsFromIdentifier(pod *corev1.Pod, nameOrNum string) ([]DestinationPort, error) {
 	num, err := strconv.Atoi(nameOrNum)
 	isNum := err == nil
 
-	for _, p := range podPorts(pod) {
-		if p.Name == nameOrNum || isNum && p.Num == int32(num) {
-			return p, nil
-		}
+	ports := util.Filter(podPorts(pod), func(p DestinationPort) bool {
+		return p.Name == nameOrNum || isNum && p.Num == int32(num)
+	})
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("unable to find port %s on pod %s %s", nameOrNum, pod.Namespace, pod.Name)
+	}
+	return ports, nil
+}
+
+// ProtocolOrTCP applies the API server's default to objects that did not come from it, like hand written manifests
+// and snapshots.
+func ProtocolOrTCP(p corev1.Protocol) corev1.Protocol {
+	if p == "" {
+		return corev1.ProtocolTCP
 	}
-	return DestinationPort{}, fmt.Errorf("unable to find port %s on pod %s %s", nameOrNum, pod.Namespace, pod.Name)
+	return p
 }
 
 func protocolFromString(protocol string) corev1.Protocol {
@@ -58,4 +58,74 @@ func TestConnectionSide(t *testing.T) {
 			So(err, ShouldBeError)
 		})
 	})
+
+	Convey("Named ports", t, func() {
+		multi := pod.DeepCopy()
+		multi.Spec.Containers = []corev1.Container{
+			{Name: "app", Ports: []corev1.ContainerPort{
+				{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP},
+				{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolTCP},
+				{Name: "http", ContainerPort: 8080},
+			}},
+			{Name: "proxy", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 9090, Protocol: corev1.ProtocolTCP}}},
+		}
+		always := corev1.ContainerRestartPolicyAlways
+		multi.Spec.InitContainers = []corev1.Container{
+			{Name: "sidecar", RestartPolicy: &always, Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9100}}},
+			{Name: "migrate", Ports: []corev1.ContainerPort{{Name: "migrate", ContainerPort: 9200}}},
+		}
+
+		Convey("Finds a port on every protocol and container", func() {
+			p, err := NewPodConnection(multi, ns, []nwv1.NetworkPolicy{}, "dns")
+			So(err, ShouldBeNil)
+			So(p.GetPorts(), ShouldHaveLength, 2)
+
+			p, err = NewPodConnection(multi, ns, []nwv1.NetworkPolicy{}, "http")
+			So(err, ShouldBeNil)
+			So(p.GetPorts(), ShouldHaveLength, 2)
+			So(p.GetPorts()[1].Container, ShouldEqual, "proxy")
+		})
+
+		Convey("Includes the ports of sidecars", func() {
+			p, err := NewPodConnection(multi, ns, []nwv1.NetworkPolicy{}, "metrics")
+			So(err, ShouldBeNil)
+			So(p.GetPorts()[0], ShouldResemble, DestinationPort{IsInCluster: true, Name: "metrics", Num: 9100, Container: "sidecar"})
+		})
+
+		Convey("Excludes the ports of init containers that exit before the pod starts", func() {
+			_, err := NewPodConnection(multi, ns, []nwv1.NetworkPolicy{}, "migrate")
+			So(err, ShouldBeError)
+		})
+
+		Convey("Reports names declared for more than one number of a protocol", func() {
+			p, err := NewPodConnection(multi, ns, []nwv1.NetworkPolicy{}, "")
+			So(err, ShouldBeNil)
+			So(p.AmbiguousPortNames(), ShouldResemble, []string{
+				"port name http/TCP is declared more than once on pod /PodOne: 8080 in container app, 9090 in container proxy",
+			})
+		})
+	})
+}
+
+func TestParsePortRange(t *testing.T) {
+	Convey("ParsePortRange", t, func() {
+		first, last, isRange, err := ParsePortRange("8000-8100")
+		So(err, ShouldBeNil)
+		So(isRange, ShouldBeTrue)
+		So(first, ShouldEqual, 8000)
+		So(last, ShouldEqual, 8100)
+
+		_, _, isRange, err = ParsePortRange("http-metrics")
+		So(err, ShouldBeNil)
+		So(isRange, ShouldBeFalse)
+
+		_, _, isRange, err = ParsePortRange("8080")
+		So(err, ShouldBeNil)
+		So(isRange, ShouldBeFalse)
+
+		_, _, _, err = ParsePortRange("8100-8000")
+		So(err, ShouldBeError)
+		_, _, _, err = ParsePortRange("0-80")
+		So(err, ShouldBeError)
+	})
 }
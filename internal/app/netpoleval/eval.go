@@ -2,9 +2,11 @@ package netpoleval
 
 import (
 	"fmt"
-	"os"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	nwv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/cheriot/netpoltool/internal/util"
 )
@@ -35,16 +37,23 @@ func EvalResultString(er EvalResult) string {
 	return []string{"NoMatch", "Deny", "Allow"}[er]
 }
 
-func Eval(source *PodConnection, dest ConnectionSide) []PortResult {
-	util.Log.Debugf("Eval toPorts %+v", dest.GetPorts())
-
-	nodeName := source.Pod.Spec.NodeName
-	if nodeName != "" && dest.IsOnNode(nodeName) {
-		// "traffic to and from the node where a Pod is running is always allowed, regardless of the IP address of the Pod or the node"
-		// https://kubernetes.io/docs/concepts/services-networking/network-policies/
-		// That's probably not what the user is interested in so continue evaluation.
-		fmt.Fprintf(os.Stderr, "Source and destination are on the same Node, %s, so kubernetes will not evaluate Network Policies and allow access. Evaluation will continue as if this were not the case.", source.Pod.Spec.NodeName)
+// SameNode finds the node source and dest share. "traffic to and from the node where a Pod is running is always
+// allowed, regardless of the IP address of the Pod or the node"
+// https://kubernetes.io/docs/concepts/services-networking/network-policies/
+// Eval does not special case it because that is rarely what the user is interested in, so callers should warn instead.
+func SameNode(source ConnectionSide, dest ConnectionSide) (string, bool) {
+	pod, ok := source.(*PodConnection)
+	if !ok {
+		return "", false
 	}
+	nodeName := pod.Pod.Spec.NodeName
+	return nodeName, nodeName != "" && dest.IsOnNode(nodeName)
+}
+
+// Eval evaluates source connecting to each port of dest. The source is usually a pod, but may be outside the cluster
+// connecting in, in which case only dest's ingress policies apply.
+func Eval(source ConnectionSide, dest ConnectionSide) []PortResult {
+	util.Log.Debugf("Eval toPorts %+v", dest.GetPorts())
 
 	var portResults []PortResult
 	for _, toPort := range dest.GetPorts() {
@@ -85,6 +94,69 @@ func Eval(source *PodConnection, dest ConnectionSide) []PortResult {
 	return portResults
 }
 
+// Explain names the policies that decided one direction of a connection, for example "denied by ns/deny-all".
+func Explain(allowed bool, nprs []NetpolResult) string {
+	outcome := "denied"
+	if allowed {
+		outcome = "allowed"
+	}
+
+	decisive := DecisivePolicies(allowed, nprs)
+	if len(decisive) == 0 {
+		return outcome + " (no matching policies)"
+	}
+	return outcome + " by " + strings.Join(decisive, ", ")
+}
+
+// DecisivePolicies names the policies of nprs whose result agrees with the outcome allowed.
+func DecisivePolicies(allowed bool, nprs []NetpolResult) []string {
+	decisive := make([]string, 0)
+	for _, npr := range nprs {
+		if npr.EvalResult != NoMatch && (npr.EvalResult == Allow) == allowed {
+			decisive = append(decisive, npr.Netpol.Namespace+"/"+npr.Netpol.Name)
+		}
+	}
+	return decisive
+}
+
+// UnmatchableNamedPorts describes the named ports of the policies that denied pr, when pr is a port the destination
+// does not declare. The name may refer to the port the user meant, but a named port only matches declared ports.
+func UnmatchableNamedPorts(pr PortResult) []string {
+	warnings := make([]string, 0)
+	if !pr.ToPort.Undeclared {
+		return warnings
+	}
+
+	warn := func(np nwv1.NetworkPolicy, ports []nwv1.NetworkPolicyPort) {
+		for _, port := range ports {
+			protocol := corev1.ProtocolTCP
+			if port.Protocol != nil {
+				protocol = *port.Protocol
+			}
+			if port.Port != nil && port.Port.Type == intstr.String && protocol == ProtocolOrTCP(pr.ToPort.Protocol) {
+				warnings = append(warnings, fmt.Sprintf(
+					"%s/%s allows port %s by name, which cannot match %d/%s because the pod does not declare it",
+					np.Namespace, np.Name, port.Port.StrVal, pr.ToPort.Num, protocol))
+			}
+		}
+	}
+	for _, npr := range pr.Egress {
+		if npr.EvalResult == Deny {
+			for _, rule := range npr.Netpol.Spec.Egress {
+				warn(npr.Netpol, rule.Ports)
+			}
+		}
+	}
+	for _, npr := range pr.Ingress {
+		if npr.EvalResult == Deny {
+			for _, rule := range npr.Netpol.Spec.Ingress {
+				warn(npr.Netpol, rule.Ports)
+			}
+		}
+	}
+	return warnings
+}
+
 func combineNetpolResults(nrs []NetpolResult) bool {
 	ers := util.Map(nrs, func(nr NetpolResult) EvalResult { return nr.EvalResult })
 
@@ -168,14 +240,40 @@ func evalRule(
 ) bool {
 
 	// If any peers match otherPod, compare ports. If both match return true.
+	if !MatchPeers(policyNamespace, peers, other) {
+		util.Log.Tracef("evalRule did not match peers %+v on %s", peers, other.GetName())
+		return false
+	}
+
+	for _, policyPort := range ports {
+		if PortContains(policyPort, toPort) {
+			util.Log.Debugf("Peer and port match %+v applys to %+v", policyPort, toPort)
+			return true
+		}
+	}
+
+	if len(ports) == 0 {
+		util.Log.Debugf("Peer match and empty port list so all ports allowed.")
+		return true
+	}
+	util.Log.Debugf("Peer match, but port not found in policy %+v", toPort)
+	return false
+}
+
+// MatchPeers is true when any of a rule's peers select other.
+func MatchPeers(policyNamespace string, peers []nwv1.NetworkPolicyPeer, other ConnectionSide) bool {
+	if len(peers) == 0 {
+		// "If this field is empty or missing, this rule matches all sources/destinations"
+		return true
+	}
 	for _, peer := range peers {
 		var peerMatch bool
 		if peer.IPBlock != nil {
 			// "If this field [peer.IPBlock] is set then neither of the other fields can be."
 			ipBlockMatch, err := other.MatchIPBlock(*peer.IPBlock)
 			if err != nil {
-				// Bail because by the time a user knows the podName this shouldn't be possible.
-				util.Log.Panicf("error evaluating policy in namespace %s against pod %s", policyNamespace, other.GetName())
+				// The API server and manifest loading reject invalid CIDRs, so this is only a hand edited snapshot
+				util.Log.Warnf("Ignoring invalid ipBlock of a policy in namespace %s: %s", policyNamespace, err.Error())
 			}
 			util.Log.Tracef("IPBlock compared %t %v %sv", ipBlockMatch, *peer.IPBlock, other.GetName())
 
@@ -206,22 +304,8 @@ func evalRule(
 		}
 
 		if peerMatch {
-			for _, policyPort := range ports {
-				if PortContains(policyPort, toPort) {
-					util.Log.Debugf("Peer and port match %+v applys to %+v", policyPort, toPort)
-					return true
-				}
-			}
-
-			if len(ports) == 0 {
-				util.Log.Debugf("Peer match and empty port list so all ports allowed.")
-				return true
-			}
-			util.Log.Debugf("Peer match, but port not found in policy %+v", toPort)
-			return false
+			return true
 		}
 	}
-
-	util.Log.Tracef("evalRule did not match peers %+v on %s", peers, other.GetName())
 	return false
 }
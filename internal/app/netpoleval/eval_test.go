@@ -405,6 +405,105 @@ func TestEval(t *testing.T) {
 
 	})
 
+	Convey("An empty from or to matches every peer.", t, func() {
+		allowAllIngress := NewPolicyBuilder("AllowAllIngress").
+			SetNamespace("NamespaceOne").
+			SetIngressRules([]nwv1.NetworkPolicyIngressRule{{}}).
+			Build()
+		allowAllEgress := NewPolicyBuilder("AllowAllEgress").
+			SetNamespace("NamespaceOne").
+			SetEgressRules([]nwv1.NetworkPolicyEgressRule{{}}).
+			Build()
+
+		pod, err := NewPodConnection(
+			makePod("PodOne", "NamespaceOne", 0),
+			makeNamespace("NamespaceOne"),
+			[]nwv1.NetworkPolicy{*allowAllIngress, *allowAllEgress},
+			"")
+		So(err, ShouldBeNil)
+		other, err := NewPodConnection(
+			makePod("PodTwo", "NamespaceTwo", 0),
+			makeNamespace("NamespaceTwo"),
+			[]nwv1.NetworkPolicy{},
+			"")
+		So(err, ShouldBeNil)
+		external, err := NewExternalConnection("1.2.3.4", "443", "tcp")
+		So(err, ShouldBeNil)
+
+		So(Eval(other, pod)[0].Allowed, ShouldBeTrue)
+		So(Eval(external, pod)[0].Allowed, ShouldBeTrue)
+		So(Eval(pod, other)[0].Allowed, ShouldBeTrue)
+		So(Eval(pod, external)[0].Allowed, ShouldBeTrue)
+	})
+
+	Convey("Undeclared ports", t, func() {
+		namedPort := intstr.FromString("PortOne")
+		tcp := corev1.ProtocolTCP
+		allowNamed := NewPolicyBuilder("AllowNamed").
+			SetNamespace("NamespaceTwo").
+			SetIngressRules([]nwv1.NetworkPolicyIngressRule{{
+				Ports: []nwv1.NetworkPolicyPort{{Protocol: &tcp, Port: &namedPort}},
+				From:  []nwv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+			}, {
+				Ports: makePolicyPort(corev1.ProtocolTCP, 8000),
+				From:  []nwv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+			}}).
+			Build()
+
+		source, err := NewPodConnection(makePod("PodOne", "NamespaceOne", 0), makeNamespace("NamespaceOne"), []nwv1.NetworkPolicy{}, "")
+		So(err, ShouldBeNil)
+		dest, err := NewPodConnection(makePod("PodTwo", "NamespaceTwo", 3000), makeNamespace("NamespaceTwo"), []nwv1.NetworkPolicy{*allowNamed}, "")
+		So(err, ShouldBeNil)
+
+		Convey("Numeric rules match undeclared ports", func() {
+			undeclared, err := dest.ForPorts("7999-8001", corev1.ProtocolTCP)
+			So(err, ShouldBeNil)
+			portResults := Eval(source, undeclared)
+			So(portResults, ShouldHaveLength, 3)
+			So(portResults[0].Allowed, ShouldBeFalse)
+			So(portResults[1].Allowed, ShouldBeTrue)
+			So(portResults[1].ToPort.Undeclared, ShouldBeTrue)
+			So(portResults[2].Allowed, ShouldBeFalse)
+		})
+
+		Convey("Named rules can't match an undeclared port", func() {
+			undeclared, err := dest.ForPorts("3001", corev1.ProtocolTCP)
+			So(err, ShouldBeNil)
+			portResults := Eval(source, undeclared)
+			So(portResults[0].Allowed, ShouldBeFalse)
+			So(UnmatchableNamedPorts(portResults[0]), ShouldResemble, []string{
+				"NamespaceTwo/AllowNamed allows port PortOne by name, which cannot match 3001/TCP because the pod does not declare it",
+			})
+		})
+
+		Convey("Declared ports keep their names", func() {
+			declared, err := dest.ForPorts("3000", corev1.ProtocolTCP)
+			So(err, ShouldBeNil)
+			portResults := Eval(source, declared)
+			So(portResults[0].ToPort.Name, ShouldEqual, "PortOne")
+			So(portResults[0].Allowed, ShouldBeTrue)
+			So(UnmatchableNamedPorts(portResults[0]), ShouldBeEmpty)
+		})
+
+		Convey("A protocol is required for undeclared ports and ranges", func() {
+			_, err := dest.ForPorts("3001", "")
+			So(err, ShouldBeError)
+			_, err = dest.ForPorts("8000-8100", "")
+			So(err, ShouldBeError)
+		})
+
+		Convey("Undeclared ports must be between 1 and 65535", func() {
+			for _, port := range []string{"0", "-5", "65536", "99999", "4294970296"} {
+				_, err := dest.ForPorts(port, corev1.ProtocolTCP)
+				So(err, ShouldBeError, "invalid port "+port)
+			}
+			_, err := dest.ForPorts("99999", "")
+			So(err, ShouldBeError, "invalid port 99999")
+			_, err = dest.ForPorts("65535", corev1.ProtocolTCP)
+			So(err, ShouldBeNil)
+		})
+	})
+
 	// TODO: Validate that Rules are OR'ed within a single Policy
 }
 
@@ -0,0 +1,117 @@
+package netpoleval
+
+import (
+	"fmt"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CIDRConnection is a range of addresses outside the cluster connecting in. It only makes sense as a source.
+type CIDRConnection struct {
+	cidr  string
+	IPNet *net.IPNet
+}
+
+// NewCIDRConnection accepts a CIDR or a single IP.
+func NewCIDRConnection(cidr string) (*CIDRConnection, error) {
+	ipNet, err := parseNet(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %s", cidr)
+	}
+	return &CIDRConnection{cidr: ipNet.String(), IPNet: ipNet}, nil
+}
+
+func (c *CIDRConnection) GetName() string {
+	return c.cidr
+}
+
+func (c *CIDRConnection) MatchNamespaceSelector(metav1.LabelSelector) bool {
+	return false
+}
+
+func (c *CIDRConnection) MatchPodSelector(metav1.LabelSelector) bool {
+	return false
+}
+
+func (c *CIDRConnection) MatchIPBlock(ipBlock nwv1.IPBlock) (bool, error) {
+	return MatchIPBlock(ipBlock, c.IPNet)
+}
+
+func (c *CIDRConnection) IsInNamespace(string) bool {
+	return false
+}
+
+func (c *CIDRConnection) IsOnNode(string) bool {
+	return false
+}
+
+func (c *CIDRConnection) IsInCluster() bool {
+	return false
+}
+
+func (c *CIDRConnection) GetPolicies() []nwv1.NetworkPolicy {
+	return nil
+}
+
+func (c *CIDRConnection) GetPorts() []DestinationPort {
+	return nil
+}
+
+// NodeConnection is a node as the source of a connection, as seen by pods when kube-proxy SNATs traffic to the
+// node's IP. NetworkPolicies can only select it with an ipBlock.
+type NodeConnection struct {
+	Node *corev1.Node
+	ip   net.IP
+}
+
+func NewNodeConnection(node *corev1.Node) (*NodeConnection, error) {
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeInternalIP {
+			ip := net.ParseIP(address.Address)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP \"%s\" on node %s", address.Address, node.Name)
+			}
+			return &NodeConnection{Node: node, ip: ip}, nil
+		}
+	}
+	return nil, fmt.Errorf("node %s has no InternalIP", node.Name)
+}
+
+func (c *NodeConnection) GetName() string {
+	return fmt.Sprintf("node/%s (%s)", c.Node.Name, c.ip)
+}
+
+func (c *NodeConnection) MatchNamespaceSelector(metav1.LabelSelector) bool {
+	return false
+}
+
+func (c *NodeConnection) MatchPodSelector(metav1.LabelSelector) bool {
+	return false
+}
+
+func (c *NodeConnection) MatchIPBlock(ipBlock nwv1.IPBlock) (bool, error) {
+	return MatchIPBlock(ipBlock, hostNet(c.ip))
+}
+
+func (c *NodeConnection) IsInNamespace(string) bool {
+	return false
+}
+
+func (c *NodeConnection) IsOnNode(name string) bool {
+	return c.Node.Name == name
+}
+
+func (c *NodeConnection) IsInCluster() bool {
+	return false
+}
+
+func (c *NodeConnection) GetPolicies() []nwv1.NetworkPolicy {
+	return nil
+}
+
+func (c *NodeConnection) GetPorts() []DestinationPort {
+	return nil
+}
@@ -0,0 +1,74 @@
+package netpoleval
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCIDRConnection(t *testing.T) {
+	Convey("CIDRConnection", t, func() {
+		c, err := NewCIDRConnection("203.0.113.0/24")
+		So(err, ShouldBeNil)
+
+		Convey("Matches an ipBlock containing the whole range", func() {
+			isMatch, err := c.MatchIPBlock(nwv1.IPBlock{CIDR: "203.0.0.0/16"})
+			So(err, ShouldBeNil)
+			So(isMatch, ShouldBeTrue)
+		})
+
+		Convey("Does not match an ipBlock containing part of the range", func() {
+			isMatch, err := c.MatchIPBlock(nwv1.IPBlock{CIDR: "203.0.113.0/25"})
+			So(err, ShouldBeNil)
+			So(isMatch, ShouldBeFalse)
+		})
+
+		Convey("Does not match when an except overlaps the range", func() {
+			isMatch, err := c.MatchIPBlock(nwv1.IPBlock{CIDR: "0.0.0.0/0", Except: []string{"203.0.113.128/25"}})
+			So(err, ShouldBeNil)
+			So(isMatch, ShouldBeFalse)
+		})
+
+		Convey("Accepts a single IP", func() {
+			ip, err := NewCIDRConnection("203.0.113.7")
+			So(err, ShouldBeNil)
+			So(ip.GetName(), ShouldEqual, "203.0.113.7/32")
+		})
+
+		Convey("Fails for an invalid CIDR", func() {
+			_, err := NewCIDRConnection("203.0.113.0/33")
+			So(err, ShouldBeError)
+		})
+	})
+}
+
+func TestNodeConnection(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "NodeOne"},
+		Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: "node-one"},
+			{Type: corev1.NodeInternalIP, Address: "192.168.0.10"},
+		}},
+	}
+
+	Convey("NodeConnection", t, func() {
+		n, err := NewNodeConnection(node)
+		So(err, ShouldBeNil)
+		So(n.GetName(), ShouldEqual, "node/NodeOne (192.168.0.10)")
+		So(n.IsOnNode("NodeOne"), ShouldBeTrue)
+
+		Convey("Matches an ipBlock by InternalIP", func() {
+			isMatch, err := n.MatchIPBlock(nwv1.IPBlock{CIDR: "192.168.0.0/24"})
+			So(err, ShouldBeNil)
+			So(isMatch, ShouldBeTrue)
+		})
+
+		Convey("Fails without an InternalIP", func() {
+			_, err := NewNodeConnection(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "NodeTwo"}})
+			So(err, ShouldBeError)
+		})
+	})
+}
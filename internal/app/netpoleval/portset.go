@@ -0,0 +1,255 @@
+package netpoleval
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+const (
+	MinPort = 1
+	MaxPort = 65535
+)
+
+// Protocols NetworkPolicy applies to, in the order they are rendered.
+var Protocols = []corev1.Protocol{corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP}
+
+// PortRange is the ports First through Last, inclusive.
+type PortRange struct {
+	First int32
+	Last  int32
+}
+
+// PortSet is a set of ports per protocol. Ranges are sorted and never overlap or touch.
+type PortSet map[corev1.Protocol][]PortRange
+
+// AllPorts is every port of every protocol, what a direction no policy selects allows.
+func AllPorts() PortSet {
+	s := PortSet{}
+	for _, protocol := range Protocols {
+		s.Add(protocol, MinPort, MaxPort)
+	}
+	return s
+}
+
+// Add adds first through last of protocol to the set.
+func (s PortSet) Add(protocol corev1.Protocol, first, last int32) {
+	s[protocol] = normalize(append(s[protocol], PortRange{First: first, Last: last}))
+}
+
+func (s PortSet) Union(o PortSet) PortSet {
+	u := PortSet{}
+	for _, protocol := range Protocols {
+		ranges := append(append([]PortRange{}, s[protocol]...), o[protocol]...)
+		if len(ranges) > 0 {
+			u[protocol] = normalize(ranges)
+		}
+	}
+	return u
+}
+
+func (s PortSet) Intersect(o PortSet) PortSet {
+	intersection := PortSet{}
+	for _, protocol := range Protocols {
+		ranges := make([]PortRange, 0)
+		a, b := s[protocol], o[protocol]
+		for i, j := 0, 0; i < len(a) && j < len(b); {
+			first := util.Max(a[i].First, b[j].First)
+			last := util.Min(a[i].Last, b[j].Last)
+			if first <= last {
+				ranges = append(ranges, PortRange{First: first, Last: last})
+			}
+			if a[i].Last < b[j].Last {
+				i++
+			} else {
+				j++
+			}
+		}
+		if len(ranges) > 0 {
+			intersection[protocol] = ranges
+		}
+	}
+	return intersection
+}
+
+// Subtract is the ports of s that are not in o.
+func (s PortSet) Subtract(o PortSet) PortSet {
+	difference := PortSet{}
+	for _, protocol := range Protocols {
+		ranges := make([]PortRange, 0)
+		for _, r := range s[protocol] {
+			for _, cut := range o[protocol] {
+				if cut.Last < r.First || cut.First > r.Last {
+					continue
+				}
+				if cut.First > r.First {
+					ranges = append(ranges, PortRange{First: r.First, Last: cut.First - 1})
+				}
+				r.First = cut.Last + 1
+				if r.First > r.Last {
+					break
+				}
+			}
+			if r.First <= r.Last {
+				ranges = append(ranges, r)
+			}
+		}
+		if len(ranges) > 0 {
+			difference[protocol] = ranges
+		}
+	}
+	return difference
+}
+
+func (s PortSet) Contains(protocol corev1.Protocol, num int32) bool {
+	for _, r := range s[protocol] {
+		if r.First <= num && num <= r.Last {
+			return true
+		}
+	}
+	return false
+}
+
+func (s PortSet) IsEmpty() bool {
+	for _, ranges := range s {
+		if len(ranges) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String compresses the set to ranges, for example "TCP 80,443,8000-8100; UDP 53".
+func (s PortSet) String() string {
+	protocols := make([]string, 0, len(Protocols))
+	for _, protocol := range Protocols {
+		if len(s[protocol]) == 0 {
+			continue
+		}
+		ranges := util.Map(s[protocol], func(r PortRange) string {
+			if r.First == r.Last {
+				return fmt.Sprintf("%d", r.First)
+			}
+			return fmt.Sprintf("%d-%d", r.First, r.Last)
+		})
+		protocols = append(protocols, fmt.Sprintf("%s %s", protocol, strings.Join(ranges, ",")))
+	}
+	if len(protocols) == 0 {
+		return "none"
+	}
+	return strings.Join(protocols, "; ")
+}
+
+func normalize(ranges []PortRange) []PortRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].First < ranges[j].First })
+	merged := make([]PortRange, 0, len(ranges))
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.First <= merged[n-1].Last+1 {
+			merged[n-1].Last = util.Max(merged[n-1].Last, r.Last)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// PortSetResult is every port source may connect to on dest, by direction.
+type PortSetResult struct {
+	Egress  PortSet
+	Ingress PortSet
+	Allowed PortSet
+}
+
+// EvalPortSet computes the exact set of ports source may connect to on dest across the whole port space, rather than
+// only the ports dest declares. Each direction allows the union of the ports of the rules whose peers match, or every
+// port when no policy of that direction selects the pod.
+func EvalPortSet(source ConnectionSide, dest ConnectionSide) PortSetResult {
+	// Named ports are resolved against the ports the destination pod declares
+	var declared []DestinationPort
+	if pod, ok := dest.(*PodConnection); ok {
+		declared = podPorts(pod.Pod)
+	}
+
+	egress := AllPorts()
+	if source.IsInCluster() {
+		if set, selected := directionPortSet(source, nwv1.PolicyTypeEgress, dest, declared); selected {
+			egress = set
+		}
+	}
+
+	ingress := AllPorts()
+	if dest.IsInCluster() {
+		if set, selected := directionPortSet(dest, nwv1.PolicyTypeIngress, source, declared); selected {
+			ingress = set
+		}
+	}
+
+	return PortSetResult{
+		Egress:  egress,
+		Ingress: ingress,
+		Allowed: egress.Intersect(ingress),
+	}
+}
+
+// directionPortSet is the union of the ports allowed by the policyType rules of side's policies that match other.
+// selected is false when no policy of that type selects side.
+func directionPortSet(side ConnectionSide, policyType nwv1.PolicyType, other ConnectionSide, declared []DestinationPort) (PortSet, bool) {
+	set := PortSet{}
+	selected := false
+	for _, np := range side.GetPolicies() {
+		if !util.Contains(np.Spec.PolicyTypes, policyType) || !side.MatchPodSelector(np.Spec.PodSelector) {
+			continue
+		}
+		selected = true
+
+		if policyType == nwv1.PolicyTypeIngress {
+			for _, rule := range np.Spec.Ingress {
+				if MatchPeers(np.Namespace, rule.From, other) {
+					set = set.Union(rulePortSet(rule.Ports, declared))
+				}
+			}
+		} else {
+			for _, rule := range np.Spec.Egress {
+				if MatchPeers(np.Namespace, rule.To, other) {
+					set = set.Union(rulePortSet(rule.Ports, declared))
+				}
+			}
+		}
+	}
+	return set, selected
+}
+
+func rulePortSet(ports []nwv1.NetworkPolicyPort, declared []DestinationPort) PortSet {
+	if len(ports) == 0 {
+		return AllPorts()
+	}
+
+	set := PortSet{}
+	for _, port := range ports {
+		protocol := corev1.ProtocolTCP
+		if port.Protocol != nil {
+			protocol = *port.Protocol
+		}
+		switch {
+		case port.Port == nil:
+			set.Add(protocol, MinPort, MaxPort)
+		case port.Port.Type == intstr.String:
+			for _, p := range declared {
+				if p.Name == port.Port.StrVal && ProtocolOrTCP(p.Protocol) == protocol {
+					set.Add(protocol, p.Num, p.Num)
+				}
+			}
+		case port.EndPort != nil:
+			set.Add(protocol, port.Port.IntVal, *port.EndPort)
+		default:
+			set.Add(protocol, port.Port.IntVal, port.Port.IntVal)
+		}
+	}
+	return set
+}
@@ -0,0 +1,113 @@
+package netpoleval
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestPortSet(t *testing.T) {
+	Convey("PortSet", t, func() {
+		s := PortSet{}
+		s.Add(corev1.ProtocolTCP, 8000, 8100)
+		s.Add(corev1.ProtocolTCP, 443, 443)
+		s.Add(corev1.ProtocolTCP, 80, 80)
+		s.Add(corev1.ProtocolTCP, 8101, 8200)
+		s.Add(corev1.ProtocolUDP, 53, 53)
+
+		Convey("Merges and compresses ranges", func() {
+			So(s.String(), ShouldEqual, "TCP 80,443,8000-8200; UDP 53")
+		})
+
+		Convey("Intersects per protocol", func() {
+			o := PortSet{}
+			o.Add(corev1.ProtocolTCP, 443, 8050)
+			o.Add(corev1.ProtocolSCTP, 53, 53)
+			So(s.Intersect(o).String(), ShouldEqual, "TCP 443,8000-8050")
+			So(s.Intersect(PortSet{}).IsEmpty(), ShouldBeTrue)
+			So(s.Intersect(PortSet{}).String(), ShouldEqual, "none")
+		})
+
+		Convey("Subtracts per protocol", func() {
+			o := PortSet{}
+			o.Add(corev1.ProtocolTCP, 443, 443)
+			o.Add(corev1.ProtocolTCP, 8050, 8060)
+			o.Add(corev1.ProtocolUDP, 1, 100)
+			So(s.Subtract(o).String(), ShouldEqual, "TCP 80,8000-8049,8061-8200")
+			So(AllPorts().Subtract(s).Contains(corev1.ProtocolTCP, 80), ShouldBeFalse)
+			So(AllPorts().Subtract(s).Contains(corev1.ProtocolTCP, 81), ShouldBeTrue)
+			So(s.Subtract(AllPorts()).IsEmpty(), ShouldBeTrue)
+		})
+
+		Convey("Contains", func() {
+			So(s.Contains(corev1.ProtocolTCP, 8150), ShouldBeTrue)
+			So(s.Contains(corev1.ProtocolTCP, 8201), ShouldBeFalse)
+			So(s.Contains(corev1.ProtocolSCTP, 53), ShouldBeFalse)
+		})
+	})
+}
+
+func TestEvalPortSet(t *testing.T) {
+	Convey("EvalPortSet", t, func() {
+		endPort := int32(8100)
+		rangeStart := intstr.FromInt(8000)
+		named := intstr.FromString("PortOne")
+		tcp := corev1.ProtocolTCP
+		udp := corev1.ProtocolUDP
+		ingress := NewPolicyBuilder("AllowWeb").
+			SetNamespace("NamespaceTwo").
+			SetIngressRules([]nwv1.NetworkPolicyIngressRule{{
+				From: []nwv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+				Ports: []nwv1.NetworkPolicyPort{
+					{Protocol: &tcp, Port: &rangeStart, EndPort: &endPort},
+					{Protocol: &tcp, Port: &named},
+					{Protocol: &udp, Port: &named},
+				},
+			}, {
+				// Does not match the source
+				From:  []nwv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"name": "Other"}}}},
+				Ports: makePolicyPort(corev1.ProtocolTCP, 22),
+			}}).
+			Build()
+		egress := NewPolicyBuilder("AllowDNSAndWeb").
+			SetNamespace("NamespaceOne").
+			SetEgressRules([]nwv1.NetworkPolicyEgressRule{{
+				To:    []nwv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+				Ports: append(makePolicyPort(corev1.ProtocolTCP, 443), makePolicyPort(corev1.ProtocolUDP, 53)...),
+			}, {
+				To:    []nwv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+				Ports: []nwv1.NetworkPolicyPort{{Protocol: &tcp, Port: &rangeStart, EndPort: &endPort}},
+			}}).
+			Build()
+
+		dest, err := NewPodConnection(makePod("PodTwo", "NamespaceTwo", 443), makeNamespace("NamespaceTwo"), []nwv1.NetworkPolicy{*ingress}, "")
+		So(err, ShouldBeNil)
+
+		Convey("A direction without policies allows every port", func() {
+			source, err := NewPodConnection(makePod("PodOne", "NamespaceOne", 0), makeNamespace("NamespaceOne"), nil, "")
+			So(err, ShouldBeNil)
+			result := EvalPortSet(source, dest)
+			So(result.Egress.String(), ShouldEqual, "TCP 1-65535; UDP 1-65535; SCTP 1-65535")
+			So(result.Ingress.String(), ShouldEqual, "TCP 443,8000-8100")
+			So(result.Allowed.String(), ShouldEqual, "TCP 443,8000-8100")
+		})
+
+		Convey("Allowed is the intersection of egress and ingress", func() {
+			source, err := NewPodConnection(makePod("PodOne", "NamespaceOne", 0), makeNamespace("NamespaceOne"), []nwv1.NetworkPolicy{*egress}, "")
+			So(err, ShouldBeNil)
+			result := EvalPortSet(source, dest)
+			So(result.Egress.String(), ShouldEqual, "TCP 443,8000-8100; UDP 53")
+			So(result.Allowed.String(), ShouldEqual, "TCP 443,8000-8100")
+
+			Convey("And agrees with Eval on each port", func() {
+				for _, num := range []int32{443, 7999, 8000, 8100, 8101} {
+					So(Eval(source, dest.ForPort(num, corev1.ProtocolTCP))[0].Allowed, ShouldEqual, result.Allowed.Contains(corev1.ProtocolTCP, num))
+				}
+			})
+		})
+	})
+}
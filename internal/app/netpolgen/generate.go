@@ -0,0 +1,290 @@
+package netpolgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/cheriot/netpoltool/internal/app/flowlog"
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// NamespaceNameLabel is set on every namespace by Kubernetes 1.21+ and is the only reliable way to select a single
+// namespace.
+const NamespaceNameLabel = "kubernetes.io/metadata.name"
+
+// podSpecificLabels differ between pods of the same workload so they are left out of selectors.
+var podSpecificLabels = []string{
+	"pod-template-hash",
+	"controller-revision-hash",
+	"pod-template-generation",
+	"statefulset.kubernetes.io/pod-name",
+	"controller-uid",
+}
+
+// workload is the set of pods a generated policy selects: the pods of a namespace sharing the same labels.
+type workload struct {
+	namespace string
+	name      string
+	labels    map[string]string
+}
+
+func (w workload) key() string {
+	return w.namespace + "/" + labelsString(w.labels)
+}
+
+// peer is the other side of a rule, either a workload or an IP outside the cluster.
+type peer struct {
+	workload *workload
+	ip       string
+}
+
+func (p peer) key() string {
+	if p.workload != nil {
+		return p.workload.key()
+	}
+	return p.ip
+}
+
+type port struct {
+	num      int32
+	protocol corev1.Protocol
+}
+
+// rules collects the observed peers and ports of one direction of one workload.
+type rules struct {
+	workload workload
+	peers    map[string]peer
+	ports    map[string]map[port]bool
+}
+
+func (r *rules) add(p peer, pt port) {
+	if r.peers == nil {
+		r.peers = make(map[string]peer)
+		r.ports = make(map[string]map[port]bool)
+	}
+	r.peers[p.key()] = p
+	if r.ports[p.key()] == nil {
+		r.ports[p.key()] = make(map[port]bool)
+	}
+	r.ports[p.key()][pt] = true
+}
+
+// Generate creates least-privilege policies that allow exactly the observed, non-dropped flows for the pods of
+// namespaces. No namespaces means every namespace with observed flows. Flows that can't be expressed are returned as
+// warnings.
+func Generate(flows []flowlog.ResolvedFlow, namespaces []string) ([]nwv1.NetworkPolicy, []string) {
+	warnings := make([]string, 0)
+	inScope := func(w workload) bool {
+		return len(namespaces) == 0 || util.Contains(namespaces, w.namespace)
+	}
+
+	ingress := make(map[string]*rules)
+	egress := make(map[string]*rules)
+	addRule := func(m map[string]*rules, w workload, p peer, pt port) {
+		if m[w.key()] == nil {
+			m[w.key()] = &rules{workload: w}
+		}
+		m[w.key()].add(p, pt)
+	}
+
+	for _, f := range flows {
+		if f.Verdict == flowlog.VerdictDropped {
+			continue
+		}
+
+		source, sourceOk := toPeer(f.Flow.Source, f.Source)
+		dest, destOk := toPeer(f.Flow.Dest, f.Dest)
+		if !sourceOk || !destOk {
+			warnings = append(warnings, fmt.Sprintf("line %d: skipping flow %s -> %s, unable to find the pod", f.Line, f.Flow.Source, f.Flow.Dest))
+			continue
+		}
+		pt := port{num: f.Port, protocol: f.Protocol}
+
+		if dest.workload != nil && inScope(*dest.workload) {
+			addRule(ingress, *dest.workload, source, pt)
+		}
+		if source.workload != nil && inScope(*source.workload) {
+			addRule(egress, *source.workload, dest, pt)
+		}
+	}
+
+	policies := make([]nwv1.NetworkPolicy, 0)
+	names := make(map[string]bool)
+	for _, r := range sortedRules(ingress) {
+		np, ws := newPolicy(r, nwv1.PolicyTypeIngress, observedName(r.workload, nwv1.PolicyTypeIngress, names))
+		policies = append(policies, np)
+		warnings = append(warnings, ws...)
+	}
+	for _, r := range sortedRules(egress) {
+		np, ws := newPolicy(r, nwv1.PolicyTypeEgress, observedName(r.workload, nwv1.PolicyTypeEgress, names))
+		policies = append(policies, np)
+		warnings = append(warnings, ws...)
+	}
+
+	sort.SliceStable(policies, func(i, j int) bool {
+		if policies[i].Namespace != policies[j].Namespace {
+			return policies[i].Namespace < policies[j].Namespace
+		}
+		return policies[i].Name < policies[j].Name
+	})
+	return policies, warnings
+}
+
+func toPeer(e flowlog.Endpoint, pod *eval.PodConnection) (peer, bool) {
+	if pod != nil {
+		w := workloadOf(pod)
+		return peer{workload: &w}, true
+	}
+	if e.IsExternal(pod) {
+		return peer{ip: e.IP}, true
+	}
+	return peer{}, false
+}
+
+// observedName names the generated policy of w, numbering it when names already has one in the namespace.
+func observedName(w workload, policyType nwv1.PolicyType, names map[string]bool) string {
+	name := fmt.Sprintf("%s-observed-%s", w.name, strings.ToLower(string(policyType)))
+	for i := 2; names[w.namespace+"/"+name]; i++ {
+		name = fmt.Sprintf("%s-%d-observed-%s", w.name, i, strings.ToLower(string(policyType)))
+	}
+	names[w.namespace+"/"+name] = true
+	return name
+}
+
+func newPolicy(r *rules, policyType nwv1.PolicyType, name string) (nwv1.NetworkPolicy, []string) {
+	warnings := make([]string, 0)
+	if len(r.workload.labels) == 0 {
+		warnings = append(warnings, fmt.Sprintf("pod %s/%s has no labels so %s is generated for every pod in the namespace", r.workload.namespace, r.workload.name, policyType))
+	}
+
+	np := nwv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.workload.namespace,
+			Labels:    map[string]string{"app.kubernetes.io/created-by": "netpoltool"},
+		},
+		Spec: nwv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: r.workload.labels},
+			PolicyTypes: []nwv1.PolicyType{policyType},
+		},
+	}
+
+	peerKeys := make([]string, 0, len(r.peers))
+	for k := range r.peers {
+		peerKeys = append(peerKeys, k)
+	}
+	sort.Strings(peerKeys)
+
+	for _, k := range peerKeys {
+		p := r.peers[k]
+		npPeer := newPeer(r.workload.namespace, p)
+		if p.workload != nil && len(p.workload.labels) == 0 {
+			warnings = append(warnings, fmt.Sprintf("pod %s/%s has no labels so %s/%s allows every pod in its namespace", p.workload.namespace, p.workload.name, np.Namespace, np.Name))
+		}
+		ports := newPorts(r.ports[k])
+		if policyType == nwv1.PolicyTypeIngress {
+			np.Spec.Ingress = append(np.Spec.Ingress, nwv1.NetworkPolicyIngressRule{From: []nwv1.NetworkPolicyPeer{npPeer}, Ports: ports})
+		} else {
+			np.Spec.Egress = append(np.Spec.Egress, nwv1.NetworkPolicyEgressRule{To: []nwv1.NetworkPolicyPeer{npPeer}, Ports: ports})
+		}
+	}
+	return np, warnings
+}
+
+func newPeer(policyNamespace string, p peer) nwv1.NetworkPolicyPeer {
+	if p.workload == nil {
+		return nwv1.NetworkPolicyPeer{IPBlock: &nwv1.IPBlock{CIDR: hostCIDR(p.ip)}}
+	}
+	npPeer := nwv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{MatchLabels: p.workload.labels},
+	}
+	if p.workload.namespace != policyNamespace {
+		npPeer.NamespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{NamespaceNameLabel: p.workload.namespace},
+		}
+	}
+	return npPeer
+}
+
+func newPorts(set map[port]bool) []nwv1.NetworkPolicyPort {
+	ports := make([]port, 0, len(set))
+	for p := range set {
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].protocol != ports[j].protocol {
+			return ports[i].protocol < ports[j].protocol
+		}
+		return ports[i].num < ports[j].num
+	})
+
+	return util.Map(ports, func(p port) nwv1.NetworkPolicyPort {
+		protocol := p.protocol
+		num := intstr.FromInt(int(p.num))
+		return nwv1.NetworkPolicyPort{Protocol: &protocol, Port: &num}
+	})
+}
+
+func workloadOf(pod *eval.PodConnection) workload {
+	labels := make(map[string]string, len(pod.Pod.Labels))
+	for k, v := range pod.Pod.Labels {
+		if !util.Contains(podSpecificLabels, k) {
+			labels[k] = v
+		}
+	}
+	return workload{
+		namespace: pod.Pod.Namespace,
+		name:      WorkloadName(pod.Pod),
+		labels:    labels,
+	}
+}
+
+// WorkloadName is the name of the Deployment, StatefulSet, DaemonSet or Job that owns the pod, or the pod's name.
+func WorkloadName(pod *corev1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if hash, ok := pod.Labels["pod-template-hash"]; ok && owner.Kind == "ReplicaSet" {
+			return strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+		return owner.Name
+	}
+	return pod.Name
+}
+
+func hostCIDR(ip string) string {
+	if strings.Contains(ip, ":") {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
+
+func sortedRules(m map[string]*rules) []*rules {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return util.Map(keys, func(k string) *rules { return m[k] })
+}
+
+func labelsString(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(util.Map(keys, func(k string) string { return k + "=" + labels[k] }), ",")
+}
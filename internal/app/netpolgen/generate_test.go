@@ -0,0 +1,109 @@
+package netpolgen
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/cheriot/netpoltool/internal/app/flowlog"
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+func TestGenerate(t *testing.T) {
+	controller := true
+	newPod := func(namespace, name, ip string, labels map[string]string, owner string) *eval.PodConnection {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+			Status:     corev1.PodStatus{PodIP: ip},
+		}
+		if owner != "" {
+			pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: owner, Controller: &controller}}
+		}
+		conn, err := eval.NewPodConnection(pod, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, nil, "")
+		if err != nil {
+			panic(err.Error())
+		}
+		return conn
+	}
+
+	web1 := newPod("ns-one", "web-5d9c-a", "10.0.0.1", map[string]string{"app": "web", "pod-template-hash": "5d9c"}, "web-5d9c")
+	web2 := newPod("ns-one", "web-5d9c-b", "10.0.0.2", map[string]string{"app": "web", "pod-template-hash": "5d9c"}, "web-5d9c")
+	db := newPod("ns-two", "db-0", "10.0.1.1", map[string]string{"app": "db", "statefulset.kubernetes.io/pod-name": "db-0"}, "")
+
+	flow := func(source, dest *eval.PodConnection, sourceIP, destIP string, port int32, protocol corev1.Protocol, verdict flowlog.Verdict) flowlog.ResolvedFlow {
+		return flowlog.ResolvedFlow{
+			Flow: flowlog.Flow{
+				Source:   flowlog.Endpoint{IP: sourceIP},
+				Dest:     flowlog.Endpoint{IP: destIP},
+				Port:     port,
+				Protocol: protocol,
+				Verdict:  verdict,
+			},
+			Source: source,
+			Dest:   dest,
+		}
+	}
+
+	flows := []flowlog.ResolvedFlow{
+		flow(web1, db, "", "", 5432, corev1.ProtocolTCP, flowlog.VerdictForwarded),
+		flow(web2, db, "", "", 5432, corev1.ProtocolTCP, flowlog.VerdictForwarded),
+		flow(web1, nil, "", "1.1.1.1", 443, corev1.ProtocolTCP, flowlog.VerdictUnknown),
+		flow(web2, db, "", "", 22, corev1.ProtocolTCP, flowlog.VerdictDropped),
+	}
+
+	Convey("Pods of a workload share one policy per direction and dropped flows are left out", t, func() {
+		policies, warnings := Generate(flows, nil)
+		So(warnings, ShouldBeEmpty)
+		So(policies, ShouldHaveLength, 2)
+
+		egress := policies[0]
+		So(egress.Namespace, ShouldEqual, "ns-one")
+		So(egress.Name, ShouldEqual, "web-observed-egress")
+		So(egress.Spec.PodSelector.MatchLabels, ShouldResemble, map[string]string{"app": "web"})
+		So(egress.Spec.PolicyTypes, ShouldResemble, []nwv1.PolicyType{nwv1.PolicyTypeEgress})
+		So(egress.Spec.Egress, ShouldHaveLength, 2)
+		So(egress.Spec.Egress[0].To[0].IPBlock.CIDR, ShouldEqual, "1.1.1.1/32")
+		So(egress.Spec.Egress[1].To[0].NamespaceSelector.MatchLabels, ShouldResemble, map[string]string{NamespaceNameLabel: "ns-two"})
+		So(egress.Spec.Egress[1].To[0].PodSelector.MatchLabels, ShouldResemble, map[string]string{"app": "db"})
+		port := intstr.FromInt(5432)
+		So(*egress.Spec.Egress[1].Ports[0].Port, ShouldResemble, port)
+
+		ingress := policies[1]
+		So(ingress.Namespace, ShouldEqual, "ns-two")
+		So(ingress.Name, ShouldEqual, "db-0-observed-ingress")
+		So(ingress.Spec.Ingress, ShouldHaveLength, 1)
+		So(ingress.Spec.Ingress[0].Ports, ShouldHaveLength, 1)
+		So(ingress.Spec.Ingress[0].From[0].PodSelector.MatchLabels, ShouldResemble, map[string]string{"app": "web"})
+	})
+
+	Convey("Namespaces limit which pods get policies", t, func() {
+		policies, _ := Generate(flows, []string{"ns-two"})
+		So(policies, ShouldHaveLength, 1)
+		So(policies[0].Name, ShouldEqual, "db-0-observed-ingress")
+	})
+
+	Convey("Unresolved pods and label-less pods are warnings", t, func() {
+		bare := newPod("ns-one", "bare", "10.0.0.3", nil, "")
+		missing := flowlog.ResolvedFlow{Flow: flowlog.Flow{
+			Source: flowlog.Endpoint{Namespace: "ns-one", Pod: "deleted"},
+			Dest:   flowlog.Endpoint{IP: "10.0.1.1"},
+			Port:   80,
+			Line:   7,
+		}, Dest: db}
+
+		policies, warnings := Generate([]flowlog.ResolvedFlow{missing, flow(bare, db, "", "", 80, corev1.ProtocolTCP, flowlog.VerdictForwarded)}, nil)
+		So(policies, ShouldHaveLength, 2)
+		So(warnings, ShouldHaveLength, 3)
+		So(warnings[0], ShouldEqual, "line 7: skipping flow ns-one/deleted -> 10.0.1.1, unable to find the pod")
+	})
+}
+
+func TestWorkloadName(t *testing.T) {
+	Convey("Pods without a controller are their own workload", t, func() {
+		So(WorkloadName(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-one"}}), ShouldEqual, "pod-one")
+	})
+}
@@ -0,0 +1,144 @@
+package netpolgen
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+// Suggestion is a NetworkPolicy, new or modified, that allows a denied connection when applied.
+type Suggestion struct {
+	Description string
+	Policy      nwv1.NetworkPolicy
+	// Warnings are the same caveats generate prints, like a pod without labels making the policy broader.
+	Warnings []string
+}
+
+// Suggest lists changes that would allow source to connect to dest on the port of result and nothing else. Each
+// denied direction can be fixed by adding a rule to any one of the policies that denied it, or by a new policy that
+// selects only the pods of the workload. Both directions must be fixed when both are denied.
+func Suggest(source *eval.PodConnection, dest eval.ConnectionSide, result eval.PortResult) []Suggestion {
+	suggestions := make([]Suggestion, 0)
+	if result.Allowed {
+		return suggestions
+	}
+
+	sourceWorkload := workloadOf(source)
+	pt := port{num: result.ToPort.Num, protocol: result.ToPort.Protocol}
+	if pt.protocol == "" {
+		pt.protocol = corev1.ProtocolTCP
+	}
+
+	if !result.EgressAllowed {
+		destPeer, ok := peerOf(dest)
+		if ok {
+			egressRule := nwv1.NetworkPolicyEgressRule{
+				To:    []nwv1.NetworkPolicyPeer{newPeer(sourceWorkload.namespace, destPeer)},
+				Ports: newPorts(map[port]bool{pt: true}),
+			}
+			for _, npr := range denying(result.Egress) {
+				np := applyable(npr.Netpol)
+				np.Spec.Egress = append(np.Spec.Egress, egressRule)
+				suggestions = append(suggestions, Suggestion{
+					Description: fmt.Sprintf("Add an egress rule to %s/%s. It also applies to every other pod the policy selects.", np.Namespace, np.Name),
+					Policy:      np,
+				})
+			}
+			suggestions = append(suggestions, newSuggestion(sourceWorkload, nwv1.PolicyTypeEgress, destPeer, pt))
+		}
+	}
+
+	if !result.IngressAllowed {
+		if destPod, ok := dest.(*eval.PodConnection); ok {
+			destWorkload := workloadOf(destPod)
+			sourcePeer := peer{workload: &sourceWorkload}
+			ingressRule := nwv1.NetworkPolicyIngressRule{
+				From:  []nwv1.NetworkPolicyPeer{newPeer(destWorkload.namespace, sourcePeer)},
+				Ports: newPorts(map[port]bool{pt: true}),
+			}
+			for _, npr := range denying(result.Ingress) {
+				np := applyable(npr.Netpol)
+				np.Spec.Ingress = append(np.Spec.Ingress, ingressRule)
+				suggestions = append(suggestions, Suggestion{
+					Description: fmt.Sprintf("Add an ingress rule to %s/%s. It also applies to every other pod the policy selects.", np.Namespace, np.Name),
+					Policy:      np,
+				})
+			}
+			suggestions = append(suggestions, newSuggestion(destWorkload, nwv1.PolicyTypeIngress, sourcePeer, pt))
+		}
+	}
+	return suggestions
+}
+
+func newSuggestion(w workload, policyType nwv1.PolicyType, p peer, pt port) Suggestion {
+	r := &rules{workload: w}
+	r.add(p, pt)
+	name := fmt.Sprintf("allow-%s-%s-%s-%d", w.name, strings.ToLower(string(policyType)), peerName(p), pt.num)
+	np, warnings := newPolicy(r, policyType, name)
+	return Suggestion{
+		Description: fmt.Sprintf("Create %s/%s that selects only %s pods.", np.Namespace, np.Name, w.name),
+		Policy:      np,
+		Warnings:    warnings,
+	}
+}
+
+func peerOf(side eval.ConnectionSide) (peer, bool) {
+	switch s := side.(type) {
+	case *eval.PodConnection:
+		w := workloadOf(s)
+		return peer{workload: &w}, true
+	case *eval.ExternalConnection:
+		return peer{ip: s.IP.String()}, true
+	}
+	return peer{}, false
+}
+
+func peerName(p peer) string {
+	if p.workload != nil {
+		return p.workload.name
+	}
+	return strings.NewReplacer(".", "-", ":", "-").Replace(p.ip)
+}
+
+func denying(nprs []eval.NetpolResult) []eval.NetpolResult {
+	denied := make([]eval.NetpolResult, 0)
+	for _, npr := range nprs {
+		if npr.EvalResult == eval.Deny {
+			denied = append(denied, npr)
+		}
+	}
+	return denied
+}
+
+// applyable copies np without the server managed fields so it can be passed to kubectl apply.
+func applyable(np nwv1.NetworkPolicy) nwv1.NetworkPolicy {
+	annotations := make(map[string]string)
+	for k, v := range np.Annotations {
+		if k != "kubectl.kubernetes.io/last-applied-configuration" {
+			annotations[k] = v
+		}
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+
+	spec := *np.Spec.DeepCopy()
+	return nwv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        np.Name,
+			Namespace:   np.Namespace,
+			Labels:      np.Labels,
+			Annotations: annotations,
+		},
+		Spec: spec,
+	}
+}
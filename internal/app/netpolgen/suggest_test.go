@@ -0,0 +1,118 @@
+package netpolgen
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+func TestSuggest(t *testing.T) {
+	newNamespace := func(name string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{NamespaceNameLabel: name}}}
+	}
+	nsOne := newNamespace("ns-one")
+	nsTwo := newNamespace("ns-two")
+
+	denyAll := func(namespace string) nwv1.NetworkPolicy {
+		return nwv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "deny-all", ResourceVersion: "42"},
+			Spec: nwv1.NetworkPolicySpec{
+				PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress, nwv1.PolicyTypeEgress},
+			},
+		}
+	}
+	newPod := func(ns *corev1.Namespace, name, ip, app string, policies []nwv1.NetworkPolicy) *eval.PodConnection {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns.Name, Name: name, Labels: map[string]string{"app": app}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+			}}},
+			Status: corev1.PodStatus{PodIP: ip},
+		}
+		conn, err := eval.NewPodConnection(pod, ns, policies, "http")
+		if err != nil {
+			panic(err.Error())
+		}
+		return conn
+	}
+
+	// evalWith re-evaluates after applying the suggestion to whichever side's namespace it belongs to.
+	evalWith := func(source, dest *eval.PodConnection, np nwv1.NetworkPolicy) eval.PortResult {
+		replace := func(conn *eval.PodConnection) *eval.PodConnection {
+			if conn.Namespace.Name != np.Namespace {
+				return conn
+			}
+			c := *conn
+			c.Policies = []nwv1.NetworkPolicy{np}
+			for _, existing := range conn.Policies {
+				if existing.Name != np.Name {
+					c.Policies = append(c.Policies, existing)
+				}
+			}
+			return &c
+		}
+		return eval.Eval(replace(source), replace(dest))[0]
+	}
+
+	Convey("A connection denied in both directions across namespaces", t, func() {
+		source := newPod(nsOne, "client", "10.0.0.1", "client", []nwv1.NetworkPolicy{denyAll("ns-one")})
+		dest := newPod(nsTwo, "server", "10.0.1.1", "server", []nwv1.NetworkPolicy{denyAll("ns-two")})
+		result := eval.Eval(source, dest)[0]
+		So(result.Allowed, ShouldBeFalse)
+
+		suggestions := Suggest(source, dest, result)
+		So(suggestions, ShouldHaveLength, 4)
+		So(suggestions[0].Description, ShouldEqual, "Add an egress rule to ns-one/deny-all. It also applies to every other pod the policy selects.")
+		So(suggestions[0].Policy.ResourceVersion, ShouldBeEmpty)
+		So(suggestions[1].Policy.Name, ShouldEqual, "allow-client-egress-server-8080")
+		So(suggestions[3].Policy.Name, ShouldEqual, "allow-server-ingress-client-8080")
+
+		for _, s := range suggestions[:2] {
+			r := evalWith(source, dest, s.Policy)
+			So(r.EgressAllowed, ShouldBeTrue)
+			So(r.IngressAllowed, ShouldBeFalse)
+		}
+		for _, s := range suggestions[2:] {
+			r := evalWith(source, dest, s.Policy)
+			So(r.IngressAllowed, ShouldBeTrue)
+		}
+
+		Convey("The rule does not allow other pods", func() {
+			other := newPod(nsOne, "other", "10.0.0.2", "other", nil)
+			fixedDest := newPod(nsTwo, "server", "10.0.1.1", "server", []nwv1.NetworkPolicy{suggestions[2].Policy})
+			So(eval.Eval(other, fixedDest)[0].IngressAllowed, ShouldBeFalse)
+			So(eval.Eval(source, fixedDest)[0].IngressAllowed, ShouldBeTrue)
+		})
+	})
+
+	Convey("An allowed connection needs no changes", t, func() {
+		source := newPod(nsOne, "client", "10.0.0.1", "client", nil)
+		dest := newPod(nsTwo, "server", "10.0.1.1", "server", nil)
+		So(Suggest(source, dest, eval.Eval(source, dest)[0]), ShouldBeEmpty)
+	})
+
+	Convey("A new policy for a pod without labels is suggested with a warning", t, func() {
+		source := newPod(nsOne, "client", "10.0.0.1", "client", []nwv1.NetworkPolicy{denyAll("ns-one")})
+		source.Pod.Labels = nil
+		dest := newPod(nsTwo, "server", "10.0.1.1", "server", nil)
+		suggestions := Suggest(source, dest, eval.Eval(source, dest)[0])
+		So(suggestions, ShouldHaveLength, 2)
+		So(suggestions[0].Warnings, ShouldBeEmpty)
+		So(suggestions[1].Warnings, ShouldResemble, []string{"pod ns-one/client has no labels so Egress is generated for every pod in the namespace"})
+	})
+
+	Convey("An external destination gets an ipBlock", t, func() {
+		source := newPod(nsOne, "client", "10.0.0.1", "client", []nwv1.NetworkPolicy{denyAll("ns-one")})
+		dest, err := eval.NewExternalConnection("1.2.3.4", "443", "tcp")
+		So(err, ShouldBeNil)
+		suggestions := Suggest(source, dest, eval.Eval(source, dest)[0])
+		So(suggestions, ShouldHaveLength, 2)
+		So(suggestions[1].Policy.Name, ShouldEqual, "allow-client-egress-1-2-3-4-443")
+		So(suggestions[1].Policy.Spec.Egress[0].To[0].IPBlock.CIDR, ShouldEqual, "1.2.3.4/32")
+	})
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cheriot/netpoltool/internal/app/probe"
+)
+
+// Executor runs probes in the cluster being evaluated. A snapshot has no pods to exec into.
+func (a *App) Executor() (probe.Executor, error) {
+	executor, ok := a.cluster.(probe.Executor)
+	if !ok {
+		return nil, fmt.Errorf("probing needs a live cluster, not a snapshot")
+	}
+	return executor, nil
+}
+
+// ProbeAccess probes each port of each evaluation q describes.
+func (a *App) ProbeAccess(ctx context.Context, p *probe.Prober, q EvalQuery) ([]probe.Result, error) {
+	es, err := a.Evaluate(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]probe.Target, 0, len(es))
+	for _, e := range es {
+		targets = append(targets, probe.Target{Source: e.Source, Dest: e.Dest, Results: e.Results})
+	}
+	return p.ProbeAll(ctx, targets), nil
+}
+
+// ProbeMatrix probes every pair of different pods in namespaces on each port the destination declares. No namespaces
+// means the default namespace.
+func (a *App) ProbeMatrix(ctx context.Context, p *probe.Prober, namespaces []string) ([]probe.Result, []error, error) {
+	m, err := a.Matrix(ctx, namespaces)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets := make([]probe.Target, 0)
+	for i, row := range m.Cells {
+		for j, e := range row {
+			if i == j {
+				continue
+			}
+			targets = append(targets, probe.Target{Source: e.Source, Dest: e.Dest, Results: e.Results})
+		}
+	}
+	return p.ProbeAll(ctx, targets), m.Skipped, nil
+}
@@ -0,0 +1,177 @@
+// Package probe validates NetworkPolicy evaluation against the cluster by connecting from the source pod to each
+// port of the destination and comparing what happened with what evaluation predicted.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+// Executor runs a command in a container of a running pod and returns its exit code and combined stdout and stderr.
+// The error is only for a command that could not be run at all.
+type Executor interface {
+	Exec(ctx context.Context, namespace, podName, container string, command []string) (int, string, error)
+}
+
+type Outcome uint8
+
+const (
+	// Connected completed the TCP handshake.
+	Connected Outcome = iota
+	// Refused reached the destination but was reset, usually because nothing listens on the port. Some CNIs also
+	// reject denied connections this way.
+	Refused
+	// Blocked timed out or was reported unreachable, as a denied connection is.
+	Blocked
+	// NotProbed could not be attempted. Result.Reason says why.
+	NotProbed
+)
+
+func (o Outcome) String() string {
+	return []string{"connected", "refused", "blocked", "not probed"}[o]
+}
+
+// Result is one port of the destination, as evaluated and as probed.
+type Result struct {
+	Source *eval.PodConnection
+	Dest   eval.ConnectionSide
+	// Predicted is what evaluation expects of the port.
+	Predicted eval.PortResult
+	Outcome   Outcome
+	Reason    string
+}
+
+// Mismatch is a probe that contradicts evaluation. Refused is not one since it cannot tell a closed port from a
+// rejected connection.
+func (r Result) Mismatch() bool {
+	switch r.Outcome {
+	case Connected:
+		return !r.Predicted.Allowed
+	case Blocked:
+		return r.Predicted.Allowed
+	}
+	return false
+}
+
+// Prober connects with nc, which must be in the container, for example the testdata/images/clitools image.
+type Prober struct {
+	Executor Executor
+	// Container of the source pod to connect from. Empty means the first container.
+	Container string
+	// Timeout of each connection, rounded up to a second.
+	Timeout time.Duration
+	// Parallel is how many targets ProbeAll probes at once. Less than one means one at a time.
+	Parallel int
+}
+
+// Target is a source and destination with the evaluated ports to probe.
+type Target struct {
+	Source  *eval.PodConnection
+	Dest    eval.ConnectionSide
+	Results []eval.PortResult
+}
+
+// ProbeAll probes each target, up to Parallel at a time, and returns the results in the order of targets. The ports
+// of a target are probed one at a time so a pod is not flooded with connections.
+func (p *Prober) ProbeAll(ctx context.Context, targets []Target) []Result {
+	parallel := p.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	probed := make([][]Result, len(targets))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, t Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			probed[i] = p.Probe(ctx, t.Source, t.Dest, t.Results)
+		}(i, t)
+	}
+	wg.Wait()
+
+	results := make([]Result, 0)
+	for _, r := range probed {
+		results = append(results, r...)
+	}
+	return results
+}
+
+// Probe connects from source to dest on the port of each result. Only TCP is probed: nc cannot tell a UDP packet
+// that was dropped from one that was never answered.
+func (p *Prober) Probe(ctx context.Context, source *eval.PodConnection, dest eval.ConnectionSide, results []eval.PortResult) []Result {
+	probes := make([]Result, 0, len(results))
+	address, addressErr := destAddress(dest)
+	for _, pr := range results {
+		r := Result{Source: source, Dest: dest, Predicted: pr}
+		switch {
+		case addressErr != nil:
+			r.Outcome, r.Reason = NotProbed, addressErr.Error()
+		case pr.ToPort.Protocol != corev1.ProtocolTCP:
+			r.Outcome, r.Reason = NotProbed, fmt.Sprintf("only TCP is probed, not %s", pr.ToPort.Protocol)
+		default:
+			r.Outcome, r.Reason = p.connect(ctx, source, address, pr.ToPort.Num)
+		}
+		probes = append(probes, r)
+	}
+	return probes
+}
+
+func (p *Prober) connect(ctx context.Context, source *eval.PodConnection, address string, port int32) (Outcome, string) {
+	container := p.Container
+	if container == "" && len(source.Pod.Spec.Containers) > 0 {
+		container = source.Pod.Spec.Containers[0].Name
+	}
+
+	code, output, err := p.Executor.Exec(ctx, source.Pod.Namespace, source.Pod.Name, container, Command(address, port, p.Timeout))
+	if err != nil {
+		return NotProbed, err.Error()
+	}
+	return ParseOutcome(code, output)
+}
+
+// Command checks that a TCP connection can be opened, without sending anything, using netcat-openbsd.
+func Command(address string, port int32, timeout time.Duration) []string {
+	seconds := int(math.Max(1, math.Ceil(timeout.Seconds())))
+	return []string{"nc", "-z", "-v", "-w", fmt.Sprint(seconds), address, fmt.Sprint(port)}
+}
+
+// ParseOutcome reads the exit code and output of Command.
+func ParseOutcome(code int, output string) (Outcome, string) {
+	lower := strings.ToLower(output)
+	switch {
+	case code == 0:
+		return Connected, ""
+	case code == 126 || code == 127:
+		// The shell's codes for a command that cannot be run
+		return NotProbed, fmt.Sprintf("nc is not available: %s", strings.TrimSpace(output))
+	case strings.Contains(lower, "refused"):
+		return Refused, ""
+	case strings.Contains(lower, "timed out"), strings.Contains(lower, "timeout"),
+		strings.Contains(lower, "unreachable"), strings.Contains(lower, "no route"), strings.Contains(lower, "in progress"):
+		return Blocked, ""
+	}
+	return NotProbed, fmt.Sprintf("nc exited %d: %s", code, strings.TrimSpace(output))
+}
+
+// destAddress is the IP to connect to. Nodes and the API server are evaluated as external IPs.
+func destAddress(dest eval.ConnectionSide) (string, error) {
+	switch d := dest.(type) {
+	case *eval.PodConnection:
+		return d.Pod.Status.PodIP, nil
+	case *eval.ExternalConnection:
+		return d.IP.String(), nil
+	}
+	return "", fmt.Errorf("cannot connect to %s", dest.GetName())
+}
@@ -0,0 +1,155 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+// fakeExecutor answers each command with the output of the address:port it connects to.
+type fakeExecutor struct {
+	outputs  map[string]string
+	commands [][]string
+	pods     []string
+
+	mu      sync.Mutex
+	running int
+	// maxRunning is the most commands that ran at once.
+	maxRunning int
+	delay      time.Duration
+}
+
+func (f *fakeExecutor) Exec(ctx context.Context, namespace, podName, container string, command []string) (int, string, error) {
+	f.mu.Lock()
+	f.running++
+	if f.running > f.maxRunning {
+		f.maxRunning = f.running
+	}
+	f.mu.Unlock()
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running--
+	f.commands = append(f.commands, command)
+	f.pods = append(f.pods, namespace+"/"+podName+"/"+container)
+	target := strings.Join(command[len(command)-2:], ":")
+	output, ok := f.outputs[target]
+	if !ok {
+		return 0, "", fmt.Errorf("unable to exec for %s", target)
+	}
+	if strings.HasSuffix(output, "succeeded!") {
+		return 0, output, nil
+	}
+	return 1, output, nil
+}
+
+func TestProbe(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "NamespaceOne"}}
+	newPod := func(name, ip string, ports ...corev1.ContainerPort) *eval.PodConnection {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "NamespaceOne", Labels: map[string]string{"app": name}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Ports: ports}, {Name: "sidecar"}}},
+			Status:     corev1.PodStatus{PodIP: ip},
+		}
+		conn, err := eval.NewPodConnection(pod, namespace, []nwv1.NetworkPolicy{}, "")
+		if err != nil {
+			panic(err)
+		}
+		return conn
+	}
+	source := newPod("Client", "10.0.0.1")
+	dest := newPod("Server", "10.0.0.2",
+		corev1.ContainerPort{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP},
+		corev1.ContainerPort{Name: "admin", ContainerPort: 9090, Protocol: corev1.ProtocolTCP},
+		corev1.ContainerPort{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP})
+
+	Convey("Each TCP port is probed from the source's first container", t, func() {
+		executor := &fakeExecutor{outputs: map[string]string{
+			"10.0.0.2:8080": "Connection to 10.0.0.2 8080 port [tcp/*] succeeded!",
+			"10.0.0.2:9090": "nc: connect to 10.0.0.2 port 9090 (tcp) timed out: Operation now in progress",
+		}}
+		p := &Prober{Executor: executor, Timeout: 1500 * time.Millisecond}
+		// No policies, so evaluation allows everything
+		results := p.Probe(context.TODO(), source, dest, eval.Eval(source, dest))
+
+		So(results, ShouldHaveLength, 3)
+		So(executor.commands, ShouldHaveLength, 2)
+		So(executor.commands[0], ShouldResemble, []string{"nc", "-z", "-v", "-w", "2", "10.0.0.2", "8080"})
+		So(executor.pods[0], ShouldEqual, "NamespaceOne/Client/main")
+
+		So(results[0].Outcome, ShouldEqual, Connected)
+		So(results[0].Mismatch(), ShouldBeFalse)
+		So(results[1].Outcome, ShouldEqual, Blocked)
+		So(results[1].Mismatch(), ShouldBeTrue)
+		So(results[2].Outcome, ShouldEqual, NotProbed)
+		So(results[2].Reason, ShouldContainSubstring, "only TCP")
+		So(results[2].Mismatch(), ShouldBeFalse)
+
+		Convey("The container can be chosen", func() {
+			p.Container = "sidecar"
+			p.Probe(context.TODO(), source, dest, eval.Eval(source, dest))
+			So(executor.pods[2], ShouldEqual, "NamespaceOne/Client/sidecar")
+		})
+
+		Convey("A failed exec is not probed", func() {
+			executor.outputs = map[string]string{}
+			results := p.Probe(context.TODO(), source, dest, eval.Eval(source, dest))
+			So(results[0].Outcome, ShouldEqual, NotProbed)
+			So(results[0].Reason, ShouldContainSubstring, "unable to exec")
+		})
+	})
+
+	Convey("Targets are probed in parallel and returned in order", t, func() {
+		executor := &fakeExecutor{
+			outputs: map[string]string{
+				"10.0.0.2:8080": "Connection to 10.0.0.2 8080 port [tcp/*] succeeded!",
+				"10.0.0.2:9090": "Connection to 10.0.0.2 9090 port [tcp/*] succeeded!",
+			},
+			delay: 20 * time.Millisecond,
+		}
+		p := &Prober{Executor: executor, Parallel: 2}
+		targets := make([]Target, 0)
+		for i := 0; i < 4; i++ {
+			targets = append(targets, Target{Source: source, Dest: dest, Results: eval.Eval(source, dest)[i%2 : i%2+1]})
+		}
+
+		results := p.ProbeAll(context.TODO(), targets)
+		So(results, ShouldHaveLength, 4)
+		So(executor.maxRunning, ShouldEqual, 2)
+		for i, r := range results {
+			So(r.Predicted.ToPort.Num, ShouldEqual, []int32{8080, 9090}[i%2])
+			So(r.Outcome, ShouldEqual, Connected)
+		}
+	})
+
+	Convey("A connection evaluation denies is a mismatch when it connects", t, func() {
+		pr := eval.PortResult{Allowed: false}
+		So(Result{Predicted: pr, Outcome: Connected}.Mismatch(), ShouldBeTrue)
+		So(Result{Predicted: pr, Outcome: Blocked}.Mismatch(), ShouldBeFalse)
+		So(Result{Predicted: pr, Outcome: Refused}.Mismatch(), ShouldBeFalse)
+	})
+
+	Convey("nc output is read as an outcome", t, func() {
+		outcome, _ := ParseOutcome(1, "nc: connect to 10.0.0.2 port 80 (tcp) failed: Connection refused")
+		So(outcome, ShouldEqual, Refused)
+		outcome, _ = ParseOutcome(1, "nc: connect to 10.0.0.2 port 80 (tcp) failed: No route to host")
+		So(outcome, ShouldEqual, Blocked)
+		outcome, reason := ParseOutcome(127, "exec: \"nc\": executable file not found in $PATH")
+		So(outcome, ShouldEqual, NotProbed)
+		So(reason, ShouldContainSubstring, "nc is not available")
+		outcome, reason = ParseOutcome(2, "nc: invalid option")
+		So(outcome, ShouldEqual, NotProbed)
+		So(reason, ShouldContainSubstring, "exited 2")
+	})
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cheriot/netpoltool/internal/app/probe"
+	"github.com/cheriot/netpoltool/internal/k8s"
+)
+
+// connectingExecutor connects to everything, as if the CNI enforced no policy.
+type connectingExecutor struct {
+	pods []string
+}
+
+func (c *connectingExecutor) Exec(ctx context.Context, namespace, podName, container string, command []string) (int, string, error) {
+	c.pods = append(c.pods, namespace+"/"+podName)
+	return 0, "succeeded!", nil
+}
+
+func TestProbeMatrix(t *testing.T) {
+	newPod := func(name, ip string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "NamespaceOne", Labels: map[string]string{"app": name}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+			}}},
+			Status: corev1.PodStatus{PodIP: ip},
+		}
+	}
+	snapshot := &k8s.Snapshot{
+		Version:    k8s.SnapshotVersion,
+		Namespaces: []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "NamespaceOne"}}},
+		Pods:       []corev1.Pod{newPod("Api", "10.0.0.1"), newPod("Db", "10.0.0.2")},
+		NetworkPolicies: []nwv1.NetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "DenyDb", Namespace: "NamespaceOne"},
+			Spec: nwv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "Db"}},
+				PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+			},
+		}},
+	}
+	a := NewClusterApp(snapshot, "NamespaceOne")
+
+	Convey("A snapshot cannot be probed", t, func() {
+		_, err := a.Executor()
+		So(err, ShouldBeError)
+	})
+
+	Convey("Every pair of different pods is probed", t, func() {
+		executor := &connectingExecutor{}
+		results, skipped, err := a.ProbeMatrix(context.TODO(), &probe.Prober{Executor: executor}, nil)
+		So(err, ShouldBeNil)
+		So(skipped, ShouldBeEmpty)
+		So(results, ShouldHaveLength, 2)
+		So(executor.pods, ShouldResemble, []string{"NamespaceOne/Api", "NamespaceOne/Db"})
+
+		So(results[0].Dest.GetName(), ShouldEqual, "NamespaceOne/Db")
+		So(results[0].Predicted.Allowed, ShouldBeFalse)
+		So(results[0].Mismatch(), ShouldBeTrue)
+		So(results[1].Dest.GetName(), ShouldEqual, "NamespaceOne/Api")
+		So(results[1].Mismatch(), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,77 @@
+// Package resolver looks up the IPs of hostnames given as destinations.
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// NetResolver uses the resolver of the machine netpoltool runs on. Names that only resolve inside the cluster, like
+// service.namespace.svc.cluster.local, will not resolve unless netpoltool runs in the cluster.
+type NetResolver struct{}
+
+func (NetResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	ips, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %w", host, err)
+	}
+	return ips, nil
+}
+
+// HostsResolver answers from a static table in the format of /etc/hosts, for snapshots and tests where the real
+// resolver is not available or would not give the answer the cluster sees.
+type HostsResolver struct {
+	hosts map[string][]string
+}
+
+func LoadHostsFile(path string) (*HostsResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening hosts file: %w", err)
+	}
+	defer f.Close()
+	return ReadHosts(f)
+}
+
+// ReadHosts parses lines of an IP followed by one or more hostnames. # starts a comment.
+func ReadHosts(r io.Reader) (*HostsResolver, error) {
+	h := &HostsResolver{hosts: make(map[string][]string)}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if net.ParseIP(fields[0]) == nil || len(fields) < 2 {
+			return nil, fmt.Errorf("hosts file line %d: expected an IP followed by hostnames", line)
+		}
+		for _, host := range fields[1:] {
+			host = strings.ToLower(host)
+			h.hosts[host] = append(h.hosts[host], fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading hosts file: %w", err)
+	}
+	return h, nil
+}
+
+func (h *HostsResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	ips, ok := h.hosts[strings.ToLower(strings.TrimSuffix(host, "."))]
+	if !ok {
+		return nil, fmt.Errorf("error resolving %s: not in hosts file", host)
+	}
+	return ips, nil
+}
@@ -0,0 +1,32 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHostsResolver(t *testing.T) {
+	Convey("Hosts files map names to every IP listed for them", t, func() {
+		h, err := ReadHosts(strings.NewReader("# comment\n1.2.3.4 example.com www.example.com\n\n2001:db8::1 Example.com # v6\n"))
+		So(err, ShouldBeNil)
+
+		ips, err := h.LookupHost(context.TODO(), "example.com.")
+		So(err, ShouldBeNil)
+		So(ips, ShouldResemble, []string{"1.2.3.4", "2001:db8::1"})
+
+		ips, err = h.LookupHost(context.TODO(), "www.example.com")
+		So(err, ShouldBeNil)
+		So(ips, ShouldResemble, []string{"1.2.3.4"})
+
+		_, err = h.LookupHost(context.TODO(), "missing.example.com")
+		So(err, ShouldBeError, "error resolving missing.example.com: not in hosts file")
+	})
+
+	Convey("Lines without an IP are errors", t, func() {
+		_, err := ReadHosts(strings.NewReader("1.2.3.4 ok\nexample.com 1.2.3.4\n"))
+		So(err, ShouldBeError, "hosts file line 2: expected an IP followed by hostnames")
+	})
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cheriot/netpoltool/internal/app/labelspace"
+)
+
+// Sources describes, for each namespace, the labels of the pods that can connect to the destination pod, including
+// pods that do not exist yet. Each declared port matching toPort is described separately. No namespaces means all
+// namespaces.
+func (a *App) Sources(ctx context.Context, toNamespace, toPodName, toPort string, namespaces []string) ([]labelspace.NamespaceSources, error) {
+	dest, err := a.queryConnectionSide(ctx, toNamespace, toPodName, toPort)
+	if err != nil {
+		return nil, fmt.Errorf("error querying destination: %w", err)
+	}
+
+	if len(namespaces) == 0 {
+		namespaces, err = a.queryNamespaceNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sources := make([]labelspace.NamespaceSources, 0)
+	for _, port := range dest.GetPorts() {
+		for _, namespaceName := range namespaces {
+			namespace, err := a.cluster.QueryNamespace(ctx, namespaceName)
+			if err != nil {
+				return nil, fmt.Errorf("error querying for namespace %s: %w", namespaceName, err)
+			}
+			netpolList, err := a.cluster.QueryNetPolList(ctx, namespaceName)
+			if err != nil {
+				return nil, fmt.Errorf("error querying for netpol list %s: %w", namespaceName, err)
+			}
+			sources = append(sources, labelspace.Sources(dest, port, namespace, netpolList.Items))
+		}
+	}
+	return sources, nil
+}
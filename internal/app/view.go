@@ -7,12 +7,18 @@ import (
 	"strings"
 	"text/tabwriter"
 
+	corev1 "k8s.io/api/core/v1"
 	nwv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/cli-runtime/pkg/printers"
 
 	"github.com/fatih/color"
 
+	"github.com/cheriot/netpoltool/internal/app/coverage"
+	"github.com/cheriot/netpoltool/internal/app/labelspace"
 	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/app/netpolgen"
+	"github.com/cheriot/netpoltool/internal/app/probe"
 	"github.com/cheriot/netpoltool/internal/util"
 )
 
@@ -54,28 +60,23 @@ func GetVerbosity(flags int) Verbosity {
 	return DetailNotMatching
 }
 
-func RenderCheckAccess(v ConsoleView, portResults []eval.PortResult, source, dest eval.ConnectionSide) error {
-	color.New(color.FgRed).SprintfFunc()
+func RenderCheckAccess(v ConsoleView, portResults []eval.PortResult, source, dest eval.ConnectionSide) {
 	if len(portResults) == 0 {
-		fmt.Printf("No ports found on %s.\n", dest.GetName())
+		fmt.Fprintf(v.Writer, "No ports found on %s.\n", dest.GetName())
 	}
 
 	// api 3000 Allow
 	//     Egress from ns-npt-0 pod-name-asdf Allow
 	//     Ingress to ns-npt-1 pod-name-fdas Allow
 
-	accessibleCount := 0
 	for _, portResult := range portResults {
-		if portResult.Allowed {
-			accessibleCount++
-		}
-
 		fmt.Fprintf(
 			v.Writer,
-			"%s %s %d %s\n",
+			"%s %s %d%s %s\n",
 			renderAllowSymbol(portResult.Allowed),
 			portResult.ToPort.Name,
 			portResult.ToPort.Num,
+			renderContainer(portResult.ToPort),
 			renderAllow(portResult.Allowed))
 
 		if v.Verbosity > Default {
@@ -90,11 +91,97 @@ func RenderCheckAccess(v ConsoleView, portResults []eval.PortResult, source, des
 		}
 	}
 
-	if accessibleCount == 0 {
-		// print message and trigger a non-zero exit code
-		return fmt.Errorf("no ports accessible")
+	if pod, ok := dest.(*eval.PodConnection); ok {
+		for _, warning := range pod.AmbiguousPortNames() {
+			fmt.Fprintf(v.Writer, "Warning: %s\n", warning)
+		}
+	}
+	warned := make(map[string]bool)
+	for _, portResult := range portResults {
+		for _, warning := range eval.UnmatchableNamedPorts(portResult) {
+			if !warned[warning] {
+				warned[warning] = true
+				fmt.Fprintf(v.Writer, "Warning: %s\n", warning)
+			}
+		}
+	}
+
+	if nodeName, ok := eval.SameNode(source, dest); ok {
+		fmt.Fprintf(v.Writer, "Warning: source and destination are on the same node, %s, so Kubernetes allows the connection regardless of NetworkPolicy. The results above ignore this.\n", nodeName)
+	}
+}
+
+func RenderPortSet(v ConsoleView, result eval.PortSetResult, source, dest eval.ConnectionSide) {
+	fmt.Fprintf(v.Writer, "%s %s\n", renderAllowSymbol(!result.Allowed.IsEmpty()), result.Allowed)
+	if v.Verbosity > Default {
+		fmt.Fprintf(v.Writer, "      Egress from pod %s: %s\n", source.GetName(), result.Egress)
+		fmt.Fprintf(v.Writer, "      Ingress to pod %s: %s\n", dest.GetName(), result.Ingress)
+	}
+}
+
+// RenderDNSCheck warns when the source cannot reach cluster DNS. Results are only shown when DNS is allowed if the
+// user asked for the check.
+func RenderDNSCheck(v ConsoleView, dns *DNSCheck, destAllowed bool, requested bool) {
+	if dns == nil {
+		if requested {
+			fmt.Fprintf(v.Writer, "\nDNS: no pods selected by Service %s or labeled %s=%s found in %s\n", dnsService, dnsLabel, dnsValue, dnsNamespace)
+		}
+		return
+	}
+
+	udp := dns.Allowed(corev1.ProtocolUDP)
+	tcp := dns.Allowed(corev1.ProtocolTCP)
+	if udp && tcp && !requested {
+		return
+	}
+
+	fmt.Fprintln(v.Writer)
+	for _, protocol := range []corev1.Protocol{corev1.ProtocolUDP, corev1.ProtocolTCP} {
+		allowed := dns.Allowed(protocol)
+		fmt.Fprintf(v.Writer, "%s DNS %d/%s %s\n", renderAllowSymbol(allowed), dnsPort, protocol, renderAllow(allowed))
+		if v.Verbosity == Default {
+			continue
+		}
+		for _, e := range dns.Evaluations {
+			for _, pr := range e.Results {
+				if pr.ToPort.Protocol != protocol {
+					continue
+				}
+				fmt.Fprintf(v.Writer, "      %s to pod %s\n", renderAllowSymbol(pr.Allowed), e.Dest.GetName())
+				fmt.Fprintf(v.Writer, "            %s Egress %s\n", renderAllowSymbol(pr.EgressAllowed), eval.Explain(pr.EgressAllowed, pr.Egress))
+				fmt.Fprintf(v.Writer, "            %s Ingress %s\n", renderAllowSymbol(pr.IngressAllowed), eval.Explain(pr.IngressAllowed, pr.Ingress))
+			}
+		}
+	}
+
+	if !udp {
+		warning := "WARNING: DNS is blocked. The pod will fail to resolve service names"
+		if destAllowed {
+			warning += " even though the destination is allowed"
+		}
+		fmt.Fprintln(v.Writer, red("%s. Allow egress to %s pods labeled %s on UDP and TCP %d.", warning, dnsNamespace, dns.Selector, dnsPort))
+	} else if !tcp {
+		fmt.Fprintln(v.Writer, red("WARNING: DNS over TCP is blocked. Responses too large for UDP will fail."))
+	}
+}
+
+// RenderSuggestions prints each suggested change as YAML that can be passed to kubectl apply.
+func RenderSuggestions(v ConsoleView, suggestions []netpolgen.Suggestion) {
+	if len(suggestions) == 0 {
+		return
+	}
+	fmt.Fprintf(v.Writer, "\nAny one of these changes allows the connection in each denied direction:\n")
+	for i, s := range suggestions {
+		fmt.Fprintf(v.Writer, "\n%d. %s\n", i+1, s.Description)
+		for _, w := range s.Warnings {
+			fmt.Fprintf(v.Writer, "Warning: %s\n", w)
+		}
+		// A new printer for each so they are not separated by ---
+		printer := printers.YAMLPrinter{}
+		if err := printer.PrintObj(&s.Policy, v.Writer); err != nil {
+			util.Log.Errorf("error rendering suggestion: %s", err.Error())
+		}
 	}
-	return nil
 }
 
 func renderNetpolResults(v ConsoleView, prefix string, nprs []eval.NetpolResult) {
@@ -117,6 +204,16 @@ func renderNetpolResults(v ConsoleView, prefix string, nprs []eval.NetpolResult)
 	}
 }
 
+func renderContainer(p eval.DestinationPort) string {
+	if p.Undeclared {
+		return fmt.Sprintf("/%s (undeclared)", p.Protocol)
+	}
+	if p.Container == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", p.Container)
+}
+
 func renderAllowSymbol(isAllowed bool) string {
 	if isAllowed {
 		return green("✓")
@@ -215,3 +312,124 @@ func renderIntOrStr(ios *intstr.IntOrString) string {
 	}
 	return ios.StrVal
 }
+
+// RenderCoverage prints a row per namespace followed by the pods that no policy isolates.
+func RenderCoverage(v ConsoleView, coverages []coverage.NamespaceCoverage) {
+	writer := tabwriter.NewWriter(v.Writer, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(writer, "NAMESPACE\tPODS\tDENY-INGRESS\tDENY-EGRESS\tOPEN-INGRESS\tOPEN-EGRESS\tSCORE\n")
+	for _, c := range coverages {
+		fmt.Fprintf(
+			writer,
+			"%s\t%d\t%s\t%s\t%d\t%d\t%d%%\n",
+			c.Namespace,
+			len(c.Pods),
+			renderAllowSymbol(c.HasDefaultDenyIngress()),
+			renderAllowSymbol(c.HasDefaultDenyEgress()),
+			len(c.PodsWithoutIngress),
+			len(c.PodsWithoutEgress),
+			c.Score())
+	}
+	writer.Flush()
+
+	if v.Verbosity == Default {
+		return
+	}
+	fmt.Fprintln(v.Writer)
+	for _, c := range coverages {
+		if len(c.PodsWithoutIngress) > 0 {
+			fmt.Fprintf(v.Writer, "%s pods without an ingress policy: %s\n", c.Namespace, strings.Join(c.PodsWithoutIngress, ", "))
+		}
+		if len(c.PodsWithoutEgress) > 0 {
+			fmt.Fprintf(v.Writer, "%s pods without an egress policy: %s\n", c.Namespace, strings.Join(c.PodsWithoutEgress, ", "))
+		}
+	}
+}
+
+// RenderSources prints the label selectors of the pods in each namespace that can reach dest, one line per term.
+func RenderSources(v ConsoleView, dest string, sources []labelspace.NamespaceSources) {
+	for i, s := range sources {
+		if i == 0 || s.Port != sources[i-1].Port {
+			if i > 0 {
+				fmt.Fprintln(v.Writer)
+			}
+			fmt.Fprintf(v.Writer, "%s %s %d/%s\n", dest, s.Port.Name, s.Port.Num, s.Port.Protocol)
+			// ipBlocks do not depend on the namespace of the source
+			for _, ipBlock := range s.IPBlocks {
+				fmt.Fprintf(v.Writer, "  %s, and any pod with an IP in it\n", ipBlock)
+			}
+		}
+
+		if len(s.Sources) == 0 {
+			fmt.Fprintf(v.Writer, "  %s %s: nothing\n", renderAllowSymbol(false), s.Namespace)
+		}
+		for _, term := range s.Sources {
+			fmt.Fprintf(v.Writer, "  %s %s: %s\n", renderAllowSymbol(true), s.Namespace, term)
+			if v.Verbosity > Default {
+				fmt.Fprintf(v.Writer, "        via %s\n", strings.Join(term.Via, ", "))
+			}
+		}
+		for _, term := range s.Broad() {
+			fmt.Fprintf(v.Writer, "  Warning: %s allows %s in %s without requiring any label value\n", strings.Join(term.Via, ", "), term, s.Namespace)
+		}
+	}
+}
+
+// RenderEquivalence prints each pair of pods whose allowed ports differ, with the ports only one set of policies allows.
+func RenderEquivalence(v ConsoleView, e *Equivalence) error {
+	for _, c := range e.Counterexamples {
+		fmt.Fprintf(v.Writer, "%s %s -> %s\n", renderAllowSymbol(false), c.Source.GetName(), c.Dest.GetName())
+		if !c.Lost.IsEmpty() {
+			fmt.Fprintf(v.Writer, "      only before allows %s\n", c.Lost)
+		}
+		if !c.Gained.IsEmpty() {
+			fmt.Fprintf(v.Writer, "      only after allows %s\n", c.Gained)
+		}
+	}
+	if v.Verbosity > Default {
+		for _, err := range e.Skipped {
+			fmt.Fprintf(v.Writer, "Skipped: %s\n", err.Error())
+		}
+	}
+
+	if !e.Equivalent() {
+		return fmt.Errorf("%d of %d pairs of pods differ", len(e.Counterexamples), e.Pairs)
+	}
+	fmt.Fprintf(v.Writer, "%s Equivalent: all %d pairs of pods allow the same ports\n", renderAllowSymbol(true), e.Pairs)
+	return nil
+}
+
+// RenderProbes lists the probes that contradict evaluation or could not tell, and with -v the ones that agree too.
+func RenderProbes(v ConsoleView, results []probe.Result) error {
+	mismatches, inconclusive := 0, 0
+	for _, r := range results {
+		line := fmt.Sprintf("%s -> %s %s %d/%s %s, evaluated %s", r.Source.GetName(), r.Dest.GetName(), r.Predicted.ToPort.Name, r.Predicted.ToPort.Num, r.Predicted.ToPort.Protocol, r.Outcome, renderAllow(r.Predicted.Allowed))
+		switch {
+		case r.Mismatch():
+			mismatches++
+			fmt.Fprintf(v.Writer, "%s %s\n", red("✗"), line)
+			fmt.Fprintf(v.Writer, "      Egress %s.\n", eval.Explain(r.Predicted.EgressAllowed, r.Predicted.Egress))
+			fmt.Fprintf(v.Writer, "      Ingress %s.\n", eval.Explain(r.Predicted.IngressAllowed, r.Predicted.Ingress))
+			if nodeName, ok := eval.SameNode(r.Source, r.Dest); ok {
+				fmt.Fprintf(v.Writer, "      Both are on node %s, which Kubernetes allows regardless of NetworkPolicy.\n", nodeName)
+			}
+		case r.Outcome == probe.NotProbed:
+			inconclusive++
+			fmt.Fprintf(v.Writer, "? %s: %s\n", line, r.Reason)
+		case r.Outcome == probe.Refused:
+			inconclusive++
+			fmt.Fprintf(v.Writer, "? %s: nothing listening, or the CNI rejects instead of dropping\n", line)
+		case v.Verbosity > Default:
+			fmt.Fprintf(v.Writer, "%s %s\n", green("✓"), line)
+		}
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d probes disagree with NetworkPolicy evaluation", mismatches, len(results))
+	}
+	if inconclusive > 0 {
+		fmt.Fprintf(v.Writer, "%s %d of %d probes agree with NetworkPolicy evaluation and the rest could not tell\n", renderAllowSymbol(true), len(results)-inconclusive, len(results))
+		return nil
+	}
+	fmt.Fprintf(v.Writer, "%s All %d probes agree with NetworkPolicy evaluation\n", renderAllowSymbol(true), len(results))
+	return nil
+}
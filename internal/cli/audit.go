@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cheriot/netpoltool/internal/sarif"
+)
+
+type AuditCommandOptions struct {
+	FlowLogOptions
+	Output string `long:"output" short:"o" default:"text" choice:"text" choice:"sarif" description:"Output format. sarif always exits zero so the results can be uploaded."`
+}
+
+func (c *AuditCommandOptions) Execute(args []string) error {
+	flows, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	findings, skipped, err := a.AuditFlows(context.TODO(), flows)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range skipped {
+		fmt.Fprintf(os.Stderr, "Skipping %s\n", s)
+	}
+	if c.Output == "sarif" {
+		return sarif.FromAudit(findings, c.Flows).Write(os.Stdout)
+	}
+
+	disagreeing := 0
+	for _, f := range findings {
+		fmt.Println(f.Message())
+		disagreeing += f.Count
+	}
+	if len(findings) > 0 {
+		return &ExitError{Code: ExitDenied, Err: fmt.Errorf("%d of %d flows disagree with NetworkPolicy evaluation", disagreeing, len(flows))}
+	}
+	fmt.Printf("All %d evaluated flows agree with NetworkPolicy evaluation.\n", len(flows)-len(skipped))
+	return nil
+}
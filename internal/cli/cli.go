@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"strings"
+
+	flags "github.com/jessevdk/go-flags"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+var globalOptions ApplicationOptions
+
+// ApplicationOptions mirror kubectl's global flags so netpoltool and the kubectl plugin connect the same way
+// kubectl does.
+type ApplicationOptions struct {
+	LogLevel         string   `long:"log-level" hidden:"true" description:"Log level (trace, debug, info, warning, error, fatal, panic)."`
+	KubeConfig       string   `long:"kubeconfig" description:"Path to the kubeconfig file. Default to $KUBECONFIG or ~/.kube/config."`
+	Context          string   `long:"context" description:"The name of the kubeconfig context to use."`
+	Cluster          string   `long:"cluster" description:"The name of the kubeconfig cluster to use."`
+	User             string   `long:"user" description:"The name of the kubeconfig user to use."`
+	Impersonate      string   `long:"as" description:"Username to impersonate for the operation."`
+	ImpersonateGroup []string `long:"as-group" description:"Group to impersonate for the operation. May be repeated."`
+	RequestTimeout   string   `long:"request-timeout" default:"0" description:"The length of time to wait before giving up on a single server request (1s, 2m, 3h). Zero means don't timeout."`
+	Snapshot         string   `long:"snapshot" description:"Evaluate against a file written by the snapshot command instead of a live cluster."`
+	Verbose          []bool   `short:"v" long:"verbose" description:"Show more detail on NetworkPolicy evaluation."`
+}
+
+func (o *ApplicationOptions) configFlags() *genericclioptions.ConfigFlags {
+	configFlags := genericclioptions.NewConfigFlags(false)
+	configFlags.KubeConfig = &o.KubeConfig
+	configFlags.Context = &o.Context
+	configFlags.ClusterName = &o.Cluster
+	configFlags.AuthInfoName = &o.User
+	configFlags.Impersonate = &o.Impersonate
+	configFlags.ImpersonateGroup = &o.ImpersonateGroup
+	configFlags.Timeout = &o.RequestTimeout
+	return configFlags
+}
+
+// Main parses the command line and runs a command. name is how the user invokes the binary, "netpoltool" or
+// "kubectl netpol", and is used in help output.
+func Main(name string) {
+	globalOptions = ApplicationOptions{}
+	// Errors are printed below, once each is given an exit code
+	parser := flags.NewNamedParser(name, flags.HelpFlag|flags.PassDoubleDash)
+	parser.AddGroup("Application Options", "", &globalOptions)
+
+	evalCmdDesc := "Given source and destination pods, evaluate if Network Policies allow the source pod to access any ports on the destination pod."
+	_, err := parser.AddCommand("eval", evalCmdDesc, evalCmdDesc, &EvalCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	snapshotCmdDesc := "Write the namespaces, pods and NetworkPolicies needed for evaluation to stdout as JSON. Env, args, annotations and volumes are stripped. Use the output with --snapshot."
+	_, err = parser.AddCommand("snapshot", snapshotCmdDesc, snapshotCmdDesc, &SnapshotCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	serveCmdDesc := "Serve an HTTP/JSON API for eval, explain, matrix and who-can-reach queries. Use --in-cluster when deployed in the cluster being evaluated."
+	_, err = parser.AddCommand("serve", serveCmdDesc, serveCmdDesc, &ServeCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	webhookCmdDesc := "Serve a validating admission webhook that rejects NetworkPolicy changes that break connectivity invariants."
+	_, err = parser.AddCommand("webhook", webhookCmdDesc, webhookCmdDesc, &WebhookCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	verifyCmdDesc := "Check that connectivity invariants hold for the current NetworkPolicies."
+	_, err = parser.AddCommand("verify", verifyCmdDesc, verifyCmdDesc, &VerifyCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	generateCmdDesc := "Generate least-privilege NetworkPolicies that allow the connections in a Hubble, Calico or CSV flow log."
+	_, err = parser.AddCommand("generate", generateCmdDesc, generateCmdDesc, &GenerateCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	auditCmdDesc := "Evaluate each connection in a flow log and report flows that policy denies but were forwarded, and flows that policy allows but were dropped."
+	_, err = parser.AddCommand("audit", auditCmdDesc, auditCmdDesc, &AuditCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	coverageCmdDesc := "Report, for each namespace, default-deny policies, pods no policy isolates and an isolation score."
+	_, err = parser.AddCommand("coverage", coverageCmdDesc, coverageCmdDesc, &CoverageCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	lintCmdDesc := "Find NetworkPolicy rules that grant broad access, most severe first."
+	_, err = parser.AddCommand("lint", lintCmdDesc, lintCmdDesc, &LintCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	ingressCmdDesc := "Given a NodePort or LoadBalancer Service and a CIDR outside the cluster, evaluate if Network Policies allow the CIDR to reach each backend, accounting for externalTrafficPolicy."
+	_, err = parser.AddCommand("ingress", ingressCmdDesc, ingressCmdDesc, &IngressCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	sourcesCmdDesc := "Describe, as label selectors, the pods in each namespace that can connect to a destination pod, including pods that do not exist yet."
+	_, err = parser.AddCommand("sources", sourcesCmdDesc, sourcesCmdDesc, &SourcesCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	equivCmdDesc := "Compare two sets of NetworkPolicy manifests and report the pairs of pods whose allowed ports differ."
+	_, err = parser.AddCommand("equiv", equivCmdDesc, equivCmdDesc, &EquivCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	uiCmdDesc := "Explore connectivity in a terminal UI: pick namespaces and pods, browse the matrix, explain a cell and toggle policies to see the effect."
+	_, err = parser.AddCommand("ui", uiCmdDesc, uiCmdDesc, &UICommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	reportCmdDesc := "Write a self-contained HTML report of a set of namespaces: the connectivity matrix, a graph, the policies selecting each pod and lint findings."
+	_, err = parser.AddCommand("report", reportCmdDesc, reportCmdDesc, &ReportCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	probeCmdDesc := "Connect from the source pod with nc, for one connection or every pair of pods in a namespace, and report where the result disagrees with NetworkPolicy evaluation."
+	_, err = parser.AddCommand("probe", probeCmdDesc, probeCmdDesc, &ProbeCommandOptions{})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	parser.CommandHandler = func(commander flags.Commander, args []string) error {
+		util.Log.Tracef("AppOptions %+v", globalOptions)
+
+		if globalOptions.LogLevel != "" {
+			err = util.SetLogLevel(globalOptions.LogLevel)
+			if err != nil {
+				return &ExitError{Code: ExitInput, Err: err}
+			}
+		}
+
+		return commander.Execute(args)
+	}
+
+	// Go exits 2 on a panic, which scripts would read as ExitPartial
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "Fatal error: %v\n%s", r, debug.Stack())
+			os.Exit(ExitInput)
+		}
+	}()
+
+	_, err = parser.Parse()
+	if err != nil {
+		// err from either the parser or the executed command
+		var flagsErr *flags.Error
+		var exitErr *ExitError
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			fmt.Fprintln(os.Stdout, err)
+		} else if !errors.As(err, &exitErr) || exitErr.Err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(exitCode(err))
+	}
+}
+
+func newApp() (*app.App, error) {
+	var a *app.App
+	var err error
+	if globalOptions.Snapshot != "" {
+		a, err = app.NewSnapshotApp(globalOptions.Snapshot)
+		if err != nil {
+			return nil, &ExitError{Code: ExitInput, Err: fmt.Errorf("Fatal error: %s", err.Error())}
+		}
+		return a, nil
+	}
+	a, err = app.NewApp(globalOptions.configFlags())
+	if err != nil {
+		return nil, &ExitError{Code: ExitCluster, Err: fmt.Errorf("Fatal error: %s", err.Error())}
+	}
+	return a, nil
+}
+
+// newServerApp is newApp for long running commands that may be deployed in the cluster they evaluate.
+func newServerApp(inCluster bool) (*app.App, error) {
+	if !inCluster {
+		return newApp()
+	}
+	a, err := app.NewInClusterApp()
+	if err != nil {
+		return nil, &ExitError{Code: ExitCluster, Err: fmt.Errorf("Fatal error: %s", err.Error())}
+	}
+	return a, nil
+}
+
+func requireOne(obj any, fieldNames ...string) error {
+
+	t := reflect.TypeOf(obj)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	r := reflect.ValueOf(obj)
+	c := reflect.Indirect(r)
+
+	nonEmpty := []string{}
+	empty := []string{}
+	for _, name := range fieldNames {
+		tField, ok := t.FieldByName(name)
+		if !ok {
+			return fmt.Errorf("unable to find the type of field %s on %+v %+v", name, obj, t)
+		}
+		longName := tField.Tag.Get("long")
+
+		vField := c.FieldByName(name)
+		if vField.IsZero() {
+			empty = append(empty, longName)
+		} else {
+			nonEmpty = append(nonEmpty, longName)
+		}
+	}
+
+	argList := func(names []string) string {
+		withDashes := util.Map(names, func(n string) string { return "--" + n })
+		return "[" + strings.Join(withDashes, ", ") + "]"
+	}
+	if len(nonEmpty) == 0 {
+		return fmt.Errorf("Exactly one of %s is required, but none provided.", argList(empty))
+	}
+	if len(nonEmpty) > 1 {
+		return fmt.Errorf("Exactly one of %s is required, but all of %s provided.", argList(append(empty, nonEmpty...)), argList(nonEmpty))
+	}
+	return nil
+}
+
+func requireExactlyOne(fieldNames string, inputs ...string) error {
+	nonEmpty := util.Filter(inputs, func(input string) bool { return input != "" })
+	if len(nonEmpty) == 0 {
+		return fmt.Errorf("Please specify one of %s", fieldNames)
+	}
+	if len(nonEmpty) > 1 {
+		return fmt.Errorf("Only one of %s may be specified, but found %s", fieldNames, strings.Join(inputs, ", "))
+	}
+	return nil
+}
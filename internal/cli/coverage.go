@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/cheriot/netpoltool/internal/app"
+)
+
+type CoverageCommandOptions struct {
+	Namespaces []string `long:"namespace" short:"n" description:"(Optional) Namespace to report on. May be repeated. Default to all namespaces."`
+}
+
+func (c *CoverageCommandOptions) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	coverages, err := a.Coverage(context.TODO(), c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	v := app.NewConsoleView(len(globalOptions.Verbose))
+	defer v.Flush()
+	app.RenderCoverage(v, coverages)
+	return nil
+}
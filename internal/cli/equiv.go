@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	nwv1 "k8s.io/api/networking/v1"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/k8s"
+	"github.com/cheriot/netpoltool/internal/markdown"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+type EquivCommandOptions struct {
+	Before     []string `long:"before" required:"true" description:"YAML or JSON file, or directory of files, of the NetworkPolicies to compare from. May be repeated."`
+	After      []string `long:"after" required:"true" description:"YAML or JSON file, or directory of files, of the NetworkPolicies to compare to. May be repeated."`
+	Namespaces []string `long:"namespace" short:"n" description:"(Optional) Namespace of the pods to compare. May be repeated. Default to all namespaces."`
+	Output     string   `long:"output" short:"o" default:"text" choice:"text" choice:"markdown" description:"Output format. markdown is a summary sized for a pull request comment."`
+}
+
+func (c *EquivCommandOptions) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	before, err := loadPolicies(c.Before, a.DefaultNamespace())
+	if err != nil {
+		return err
+	}
+	after, err := loadPolicies(c.After, a.DefaultNamespace())
+	if err != nil {
+		return err
+	}
+
+	e, err := a.Equivalence(context.TODO(), before, after, c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	if c.Output == "markdown" {
+		if err := markdown.WriteEquivalence(os.Stdout, e); err != nil {
+			return err
+		}
+		if !e.Equivalent() {
+			return &ExitError{Code: ExitDenied, Err: fmt.Errorf("%d of %d pairs of pods differ", len(e.Counterexamples), e.Pairs)}
+		}
+		return nil
+	}
+
+	v := app.NewConsoleView(len(globalOptions.Verbose))
+	defer v.Flush()
+	if err := app.RenderEquivalence(v, e); err != nil {
+		return &ExitError{Code: ExitDenied, Err: err}
+	}
+	return nil
+}
+
+func loadPolicies(paths []string, defaultNamespace string) ([]nwv1.NetworkPolicy, error) {
+	manifests, err := k8s.LoadManifests(paths, defaultNamespace)
+	if err != nil {
+		return nil, err
+	}
+	return util.Map(manifests, func(m k8s.Manifest) nwv1.NetworkPolicy { return m.NetworkPolicy }), nil
+}
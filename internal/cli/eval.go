@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/app/resolver"
+)
+
+type EvalCommandOptions struct {
+	Namespace    string `long:"namespace" short:"n" description:"Namespace of the pod creating the connection. Default to the namespace of the current kubeconfig context."`
+	PodName      string `long:"pod" required:"true" description:"Name of the pod creating the connection."`
+	ToNamespace  string `long:"to-namespace" description:"Namespace of the pod receiving the connection. Default to --namespace."`
+	ToPodName    string `long:"to-pod" description:"Name of the pod receiving the connection."`
+	ToExternalIP string `long:"to-ext-ip" description:"IP address or hostname of a host *outside* the kubernetes cluster the connection originates in, or the ClusterIP of a Service to evaluate its backends."`
+	ToProtocol   string `long:"to-protocol" choice:"udp" choice:"tcp" choice:"sctp" description:"Protocol of the connection (udp, tcp, or sctp). Defaults to tcp for --to-ext-ip and --to-node. Required with --to-pod to evaluate a port the pod does not declare or a range."`
+	ToNode       string `long:"to-node" description:"Name of a node, evaluated at each of its InternalIPs."`
+	ToAPIServer  bool   `long:"to-apiserver" description:"Evaluate the addresses of the API server from the default/kubernetes Endpoints."`
+	ToPort       string `long:"to-port" description:"(Optional) Number or name of the port to connect to. With --to-pod, may be a range like 8000-8100."`
+	HostsFile    string `long:"hosts-file" description:"(Optional) Resolve --to-ext-ip hostnames from this file in /etc/hosts format instead of DNS."`
+	AllPorts     bool   `long:"all-ports" description:"Show every port of every protocol the pod may connect to on --to-pod, as ranges, instead of evaluating the declared ports."`
+	CheckDNS     bool   `long:"check-dns" description:"Also evaluate the pod's connections to cluster DNS. Done automatically when an egress policy selects the pod."`
+	Quiet        bool   `long:"quiet" short:"q" description:"Print nothing but errors. Use the exit code for the result."`
+	Expect       string `long:"expect" choice:"allow" choice:"deny" description:"(Optional) Exit 0 when every port is allowed (allow) or none are (deny), and 1 otherwise."`
+}
+
+func (c *EvalCommandOptions) Execute(args []string) error {
+
+	err := requireOne(c, "ToPodName", "ToExternalIP", "ToNode", "ToAPIServer")
+	if err != nil {
+		return err
+	}
+
+	if c.ToNode != "" {
+		if c.ToProtocol == "" {
+			c.ToProtocol = "tcp"
+		}
+		if c.ToPort == "" {
+			return fmt.Errorf("--to-port is required when using --to-node")
+		}
+	}
+	if (c.ToNode != "" || c.ToAPIServer) && c.ToNamespace != "" {
+		return fmt.Errorf("--to-namespace is only used with --to-pod")
+	}
+
+	if c.AllPorts && (c.ToPodName == "" || c.ToPort != "") {
+		return fmt.Errorf("--all-ports requires --to-pod and cannot be used with --to-port")
+	}
+
+	if c.ToExternalIP != "" {
+		if c.ToProtocol == "" {
+			if !c.Quiet {
+				fmt.Fprintln(os.Stderr, "No protocol specified so defaulting to TCP. Use --to-protocol to change.")
+			}
+			c.ToProtocol = "tcp"
+		}
+		if c.ToPort == "" {
+			return fmt.Errorf("--to-port is required when using --to-ext-ip")
+		}
+		if c.ToNamespace != "" {
+			return fmt.Errorf("Cannot use --to-namespace and --to-ext-ip at the same time. Only use --to-ext-ip for IPs outside the current cluster.")
+		}
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	if c.HostsFile != "" {
+		hosts, err := resolver.LoadHostsFile(c.HostsFile)
+		if err != nil {
+			return err
+		}
+		a.SetResolver(hosts)
+	}
+
+	if c.Namespace == "" {
+		c.Namespace = a.DefaultNamespace()
+	}
+	if c.ToPodName != "" && c.ToNamespace == "" {
+		c.ToNamespace = c.Namespace
+	}
+
+	v := app.NewConsoleView(len(globalOptions.Verbose))
+	if c.Quiet {
+		v.Writer = bufio.NewWriter(io.Discard)
+	}
+	defer v.Flush()
+	var verdict app.Verdict
+	if c.AllPorts {
+		verdict, err = a.CheckAllPorts(v, app.EvalQuery{
+			Namespace:   c.Namespace,
+			PodName:     c.PodName,
+			ToNamespace: c.ToNamespace,
+			ToPodName:   c.ToPodName,
+		})
+	} else {
+		verdict, err = a.CheckAccess(v, app.EvalQuery{
+			Namespace:    c.Namespace,
+			PodName:      c.PodName,
+			ToNamespace:  c.ToNamespace,
+			ToPodName:    c.ToPodName,
+			ToPort:       c.ToPort,
+			ToExternalIP: c.ToExternalIP,
+			ToProtocol:   c.ToProtocol,
+			ToNode:       c.ToNode,
+			ToAPIServer:  c.ToAPIServer,
+			CheckDNS:     c.CheckDNS,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	return verdictError(verdict, c.Expect, c.Quiet)
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	flags "github.com/jessevdk/go-flags"
+
+	"github.com/cheriot/netpoltool/internal/app"
+)
+
+// Exit codes are stable so scripts can tell a denied connection from a typo or an unreachable cluster.
+const (
+	ExitAllowed = 0
+	// ExitDenied is also used when a check like lint, verify, audit, equiv or probe fails or --expect is not met.
+	ExitDenied  = 1
+	ExitPartial = 2
+	// ExitInput is an invalid command line, manifest or snapshot, or a pod or namespace that does not exist.
+	ExitInput = 3
+	// ExitCluster is a kubeconfig that cannot be used or an API server that cannot be reached or refuses a request.
+	ExitCluster = 4
+)
+
+// ExitError exits with Code. A nil Err exits without printing anything.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// verdictError exits with the code of verdict or, with expect, ExitAllowed when verdict is what was expected and
+// ExitDenied when it is not. Expecting allow means every port evaluated is allowed and deny means none are. Quiet
+// exits without a message.
+func verdictError(verdict app.Verdict, expect string, quiet bool) error {
+	var err *ExitError
+	switch {
+	case expect == "allow" && verdict != app.VerdictAllowed, expect == "deny" && verdict != app.VerdictDenied:
+		err = &ExitError{Code: ExitDenied, Err: fmt.Errorf("expected %s but the connection is %s", expect, verdict)}
+	case expect != "":
+		return nil
+	case verdict == app.VerdictPartiallyAllowed:
+		err = &ExitError{Code: ExitPartial, Err: fmt.Errorf("some ports accessible")}
+	case verdict == app.VerdictDenied:
+		err = &ExitError{Code: ExitDenied, Err: fmt.Errorf("no ports accessible")}
+	default:
+		return nil
+	}
+	if quiet {
+		err.Err = nil
+	}
+	return err
+}
+
+// exitCode classifies errors that were not given a code where they happened. Errors from the API server, other than a
+// missing or invalid object, and errors reaching it are cluster errors. Anything else is the user's input.
+func exitCode(err error) int {
+	if err == nil {
+		return ExitAllowed
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	var flagsErr *flags.Error
+	if errors.As(err, &flagsErr) {
+		if flagsErr.Type == flags.ErrHelp {
+			return ExitAllowed
+		}
+		return ExitInput
+	}
+
+	if app.ClassifyError(err) == app.ErrorInput {
+		return ExitInput
+	}
+	return ExitCluster
+}
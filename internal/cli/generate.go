@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/cli-runtime/pkg/printers"
+
+	"github.com/cheriot/netpoltool/internal/app/flowlog"
+	"github.com/cheriot/netpoltool/internal/app/netpolgen"
+)
+
+// FlowLogOptions are shared by the commands that read flow logs.
+type FlowLogOptions struct {
+	Flows  string `long:"flows" required:"true" description:"Flow log from Hubble, Calico or a CSV file."`
+	Format string `long:"format" default:"hubble" choice:"hubble" choice:"calico" choice:"csv" description:"Format of the flow log. csv is source,destination,port,protocol[,verdict] where source and destination are namespace/pod or an IP."`
+}
+
+func (o FlowLogOptions) load() ([]flowlog.Flow, error) {
+	return flowlog.Load(o.Flows, flowlog.Format(o.Format))
+}
+
+type GenerateCommandOptions struct {
+	FlowLogOptions
+	Namespaces []string `long:"namespace" short:"n" description:"(Optional) Only generate NetworkPolicies for pods in this namespace. May be repeated. Default to every namespace in the flow log."`
+	OutputDir  string   `long:"output-dir" description:"(Optional) Write one file per NetworkPolicy to this directory instead of stdout."`
+}
+
+func (c *GenerateCommandOptions) Execute(args []string) error {
+	flows, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	resolved, err := a.ResolveFlows(context.TODO(), flows)
+	if err != nil {
+		return err
+	}
+
+	policies, warnings := netpolgen.Generate(resolved, c.Namespaces)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+
+	printer := printers.YAMLPrinter{}
+	for i := range policies {
+		np := &policies[i]
+		if c.OutputDir == "" {
+			if err := printer.PrintObj(np, os.Stdout); err != nil {
+				return err
+			}
+			continue
+		}
+
+		path := filepath.Join(c.OutputDir, fmt.Sprintf("%s-%s.yaml", np.Namespace, np.Name))
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("error creating %s: %w", path, err)
+		}
+		err = printer.PrintObj(np, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Generated %d NetworkPolicies from %d flows\n", len(policies), len(flows))
+	return nil
+}
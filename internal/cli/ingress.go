@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/cheriot/netpoltool/internal/app"
+)
+
+type IngressCommandOptions struct {
+	Namespace string `long:"namespace" short:"n" description:"Namespace of the Service. Default to the namespace of the current kubeconfig context."`
+	Service   string `long:"service" required:"true" description:"Name of a NodePort or LoadBalancer Service."`
+	Port      string `long:"port" description:"(Optional) Name or number of the service port. Default to every port."`
+	FromCIDR  string `long:"from-cidr" required:"true" description:"CIDR or IP of the clients outside the cluster."`
+	Quiet     bool   `long:"quiet" short:"q" description:"Print nothing but errors. Use the exit code for the result."`
+	Expect    string `long:"expect" choice:"allow" choice:"deny" description:"(Optional) Exit 0 when every port of every backend is allowed (allow) or none are (deny), and 1 otherwise."`
+}
+
+func (c *IngressCommandOptions) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	if c.Namespace == "" {
+		c.Namespace = a.DefaultNamespace()
+	}
+
+	v := app.NewConsoleView(len(globalOptions.Verbose))
+	if c.Quiet {
+		v.Writer = bufio.NewWriter(io.Discard)
+	}
+	defer v.Flush()
+	verdict, err := a.CheckIngress(v, app.IngressQuery{
+		Namespace: c.Namespace,
+		Service:   c.Service,
+		Port:      c.Port,
+		FromCIDR:  c.FromCIDR,
+	})
+	if err != nil {
+		return err
+	}
+	return verdictError(verdict, c.Expect, c.Quiet)
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	nwv1 "k8s.io/api/networking/v1"
+
+	"github.com/cheriot/netpoltool/internal/app/lint"
+	"github.com/cheriot/netpoltool/internal/k8s"
+	"github.com/cheriot/netpoltool/internal/sarif"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+type LintCommandOptions struct {
+	Namespaces  []string `long:"namespace" short:"n" description:"(Optional) Namespace of the NetworkPolicies to check. May be repeated. Default to all namespaces."`
+	Files       []string `long:"file" short:"f" description:"(Optional) Check the NetworkPolicies in this YAML or JSON file, or directory of files, instead of the cluster. May be repeated."`
+	MinSeverity string   `long:"min-severity" default:"low" choice:"low" choice:"medium" choice:"high" description:"Only report findings at least this severe."`
+	Output      string   `long:"output" short:"o" default:"text" choice:"text" choice:"sarif" description:"Output format. sarif always exits zero so the results can be uploaded."`
+}
+
+func (c *LintCommandOptions) Execute(args []string) error {
+	minSeverity, err := lint.ParseSeverity(c.MinSeverity)
+	if err != nil {
+		return err
+	}
+
+	var findings []lint.Finding
+	var locate sarif.Locator
+	if len(c.Files) > 0 {
+		manifests, err := k8s.LoadManifests(c.Files, "default")
+		if err != nil {
+			return err
+		}
+		findings, locate = lintManifests(manifests)
+	} else {
+		a, err := newApp()
+		if err != nil {
+			return err
+		}
+		findings, err = a.Lint(context.TODO(), c.Namespaces)
+		if err != nil {
+			return err
+		}
+	}
+	findings = util.Filter(findings, func(f lint.Finding) bool { return f.Severity >= minSeverity })
+
+	if c.Output == "sarif" {
+		return sarif.FromLint(findings, locate).Write(os.Stdout)
+	}
+
+	for _, f := range findings {
+		if locate != nil {
+			if path, line, ok := locate(f); ok {
+				fmt.Printf("%s:%d: ", path, line)
+			}
+		}
+		fmt.Println(f.String())
+	}
+	if len(findings) > 0 {
+		return &ExitError{Code: ExitDenied, Err: fmt.Errorf("%d findings", len(findings))}
+	}
+	fmt.Println("No findings.")
+	return nil
+}
+
+// lintManifests checks the policies in manifests and returns a Locator for the file and line of each finding.
+func lintManifests(manifests []k8s.Manifest) ([]lint.Finding, sarif.Locator) {
+	byName := make(map[string]k8s.Manifest, len(manifests))
+	for _, m := range manifests {
+		byName[m.NetworkPolicy.Namespace+"/"+m.NetworkPolicy.Name] = m
+	}
+	locate := func(f lint.Finding) (string, int, bool) {
+		m, ok := byName[f.Namespace+"/"+f.Policy]
+		if !ok {
+			return "", 0, false
+		}
+		return m.Path, m.Line(f.Path()...), true
+	}
+
+	return lint.Lint(util.Map(manifests, func(m k8s.Manifest) nwv1.NetworkPolicy { return m.NetworkPolicy })), locate
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/app/probe"
+)
+
+type ProbeCommandOptions struct {
+	Namespaces   []string      `long:"namespace" short:"n" description:"Namespace of the pod creating the connection. With --matrix, a namespace to probe and may be repeated. Default to the namespace of the current kubeconfig context."`
+	PodName      string        `long:"pod" description:"Name of the pod creating the connection."`
+	ToNamespace  string        `long:"to-namespace" description:"Namespace of the pod receiving the connection. Default to --namespace."`
+	ToPodName    string        `long:"to-pod" description:"Name of the pod receiving the connection."`
+	ToExternalIP string        `long:"to-ext-ip" description:"IP address or hostname outside the cluster, or the ClusterIP of a Service to probe its backends."`
+	ToProtocol   string        `long:"to-protocol" choice:"udp" choice:"tcp" choice:"sctp" description:"Protocol of the connection. Only TCP is probed. Defaults to tcp for --to-ext-ip."`
+	ToPort       string        `long:"to-port" description:"(Optional) Number or name of the port to connect to."`
+	Matrix       bool          `long:"matrix" description:"Probe every pair of pods in --namespace instead of a single connection."`
+	Container    string        `long:"container" short:"c" description:"(Optional) Container of the source pod to run nc in. Default to the pod's first container."`
+	Timeout      time.Duration `long:"timeout" default:"2s" description:"How long to wait for each connection before counting it as blocked."`
+	Parallel     int           `long:"parallel" default:"8" description:"How many pairs of pods to probe at once. The ports of a pair are probed one at a time."`
+}
+
+func (c *ProbeCommandOptions) Execute(args []string) error {
+	if c.Parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+	if c.Matrix {
+		if c.PodName != "" || c.ToPodName != "" || c.ToExternalIP != "" || c.ToPort != "" {
+			return fmt.Errorf("--matrix probes every pair of pods in --namespace and cannot be used with --pod, --to-pod, --to-ext-ip or --to-port")
+		}
+	} else {
+		if c.PodName == "" {
+			return fmt.Errorf("--pod is required unless using --matrix")
+		}
+		if err := requireOne(c, "ToPodName", "ToExternalIP"); err != nil {
+			return err
+		}
+		if len(c.Namespaces) > 1 {
+			return fmt.Errorf("--namespace may only be repeated with --matrix")
+		}
+		if c.ToExternalIP != "" {
+			if c.ToProtocol == "" {
+				c.ToProtocol = "tcp"
+			}
+			if c.ToPort == "" {
+				return fmt.Errorf("--to-port is required when using --to-ext-ip")
+			}
+		}
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+	executor, err := a.Executor()
+	if err != nil {
+		return err
+	}
+	p := &probe.Prober{Executor: executor, Container: c.Container, Timeout: c.Timeout, Parallel: c.Parallel}
+
+	ctx := context.TODO()
+	var results []probe.Result
+	if c.Matrix {
+		var skipped []error
+		results, skipped, err = a.ProbeMatrix(ctx, p, c.Namespaces)
+		if err != nil {
+			return err
+		}
+		for _, s := range skipped {
+			fmt.Fprintf(os.Stderr, "Skipping %s\n", s)
+		}
+	} else {
+		namespace := a.DefaultNamespace()
+		if len(c.Namespaces) == 1 {
+			namespace = c.Namespaces[0]
+		}
+		if c.ToPodName != "" && c.ToNamespace == "" {
+			c.ToNamespace = namespace
+		}
+		results, err = a.ProbeAccess(ctx, p, app.EvalQuery{
+			Namespace:    namespace,
+			PodName:      c.PodName,
+			ToNamespace:  c.ToNamespace,
+			ToPodName:    c.ToPodName,
+			ToPort:       c.ToPort,
+			ToExternalIP: c.ToExternalIP,
+			ToProtocol:   c.ToProtocol,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	v := app.NewConsoleView(len(globalOptions.Verbose))
+	defer v.Flush()
+	if err := app.RenderProbes(v, results); err != nil {
+		// A failed check, as with lint and verify
+		return &ExitError{Code: ExitDenied, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cheriot/netpoltool/internal/report"
+)
+
+type ReportCommandOptions struct {
+	Namespaces []string `long:"namespace" short:"n" description:"(Optional) Namespace to report on. May be repeated. Default to the namespace of the current kubeconfig context."`
+	OutFile    string   `long:"out-file" description:"(Optional) File to write the HTML report to. Default to stdout."`
+}
+
+func (c *ReportCommandOptions) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	namespaces := c.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{a.DefaultNamespace()}
+	}
+
+	ctx := context.TODO()
+	matrix, err := a.Matrix(ctx, namespaces)
+	if err != nil {
+		return err
+	}
+	findings, err := a.Lint(ctx, namespaces)
+	if err != nil {
+		return err
+	}
+
+	r := report.New(namespaces, matrix, findings, time.Now())
+	if c.OutFile == "" {
+		return r.Write(os.Stdout)
+	}
+
+	f, err := os.Create(c.OutFile)
+	if err != nil {
+		return fmt.Errorf("error creating report: %w", err)
+	}
+	if err := r.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	// The last of the report may only reach the disk on close
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cheriot/netpoltool/internal/server"
+)
+
+type ServeCommandOptions struct {
+	Listen          string        `long:"listen" default:":8080" description:"Address to serve the HTTP API on."`
+	InCluster       bool          `long:"in-cluster" description:"Connect with the service account of the pod netpoltool is running in instead of a kubeconfig."`
+	MetricsInterval time.Duration `long:"metrics-interval" default:"1m" description:"How often to recompute the Prometheus metrics served on /metrics. Zero computes them once at startup."`
+}
+
+func (c *ServeCommandOptions) Execute(args []string) error {
+	a, err := newServerApp(c.InCluster)
+	if err != nil {
+		return err
+	}
+	// Every request queries the cluster, so answer them from watches rather than listing pods and policies each time
+	a, err = a.Cached(context.Background())
+	if err != nil {
+		return &ExitError{Code: ExitCluster, Err: err}
+	}
+
+	s := server.New(a)
+	go s.Exporter.Run(context.Background(), c.MetricsInterval)
+
+	srv := &http.Server{
+		Addr:              c.Listen,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Fprintf(os.Stderr, "Serving on %s\n", c.Listen)
+	return srv.ListenAndServe()
+}
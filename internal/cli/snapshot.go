@@ -0,0 +1,18 @@
+package cli
+
+import (
+	"os"
+)
+
+type SnapshotCommandOptions struct {
+	Namespaces []string `long:"namespace" short:"n" description:"(Optional) Only capture pods and NetworkPolicies in this namespace. May be repeated. Default to all namespaces."`
+}
+
+func (c *SnapshotCommandOptions) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	return a.WriteSnapshot(os.Stdout, c.Namespaces)
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/cheriot/netpoltool/internal/app"
+)
+
+type SourcesCommandOptions struct {
+	Namespaces  []string `long:"namespace" short:"n" description:"(Optional) Namespace of the pods to describe. May be repeated. Default to all namespaces."`
+	ToNamespace string   `long:"to-namespace" description:"Namespace of the pod receiving the connection. Default to the namespace of the current kubeconfig context."`
+	ToPodName   string   `long:"to-pod" required:"true" description:"Name of the pod receiving the connection."`
+	ToPort      string   `long:"to-port" description:"(Optional) Number or name of the port to connect to. Default to every declared port."`
+}
+
+func (c *SourcesCommandOptions) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	if c.ToNamespace == "" {
+		c.ToNamespace = a.DefaultNamespace()
+	}
+
+	sources, err := a.Sources(context.TODO(), c.ToNamespace, c.ToPodName, c.ToPort, c.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	v := app.NewConsoleView(len(globalOptions.Verbose))
+	defer v.Flush()
+	app.RenderSources(v, c.ToNamespace+"/"+c.ToPodName, sources)
+	return nil
+}
@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/cheriot/netpoltool/internal/k8s"
+	"github.com/cheriot/netpoltool/internal/tui"
+)
+
+type UICommandOptions struct {
+	Namespaces []string `long:"namespace" short:"n" description:"(Optional) Namespace to select at start. May be repeated. Default to the namespace of the current kubeconfig context."`
+}
+
+func (c *UICommandOptions) Execute(args []string) error {
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	load := func(ctx context.Context) (*k8s.Snapshot, error) {
+		return a.TakeSnapshot(ctx, nil, false)
+	}
+	m, err := tui.NewModel(context.TODO(), load, a.DefaultNamespace(), c.Namespaces)
+	if err != nil {
+		return err
+	}
+	return tui.Run(context.TODO(), m)
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cheriot/netpoltool/internal/app/invariant"
+	"github.com/cheriot/netpoltool/internal/markdown"
+	"github.com/cheriot/netpoltool/internal/webhook"
+)
+
+type WebhookCommandOptions struct {
+	Invariants  string `long:"invariants" required:"true" description:"YAML file of connectivity invariants that NetworkPolicy changes must not break."`
+	Listen      string `long:"listen" default:":8443" description:"Address to serve the webhook on."`
+	TLSCertFile string `long:"tls-cert-file" required:"true" description:"PEM certificate the API server will verify."`
+	TLSKeyFile  string `long:"tls-key-file" required:"true" description:"PEM private key for --tls-cert-file."`
+	InCluster   bool   `long:"in-cluster" description:"Connect with the service account of the pod netpoltool is running in instead of a kubeconfig."`
+}
+
+func (c *WebhookCommandOptions) Execute(args []string) error {
+	invariants, err := invariant.Load(c.Invariants)
+	if err != nil {
+		return err
+	}
+
+	a, err := newServerApp(c.InCluster)
+	if err != nil {
+		return err
+	}
+	// Each review snapshots the cluster, so answer it from watches rather than listing everything per request
+	a, err = a.Cached(context.Background())
+	if err != nil {
+		return &ExitError{Code: ExitCluster, Err: err}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/validate", webhook.NewHandler(a, invariants))
+	srv := &http.Server{
+		Addr:              c.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Fprintf(os.Stderr, "Serving webhook on %s/validate with %d invariants\n", c.Listen, len(invariants))
+	return srv.ListenAndServeTLS(c.TLSCertFile, c.TLSKeyFile)
+}
+
+type VerifyCommandOptions struct {
+	Invariants string `long:"invariants" required:"true" description:"YAML file of connectivity invariants to check."`
+	Output     string `long:"output" short:"o" default:"text" choice:"text" choice:"markdown" description:"Output format. markdown is a summary sized for a pull request comment."`
+}
+
+func (c *VerifyCommandOptions) Execute(args []string) error {
+	invariants, err := invariant.Load(c.Invariants)
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp()
+	if err != nil {
+		return err
+	}
+
+	violations, err := a.CheckInvariants(context.TODO(), invariants)
+	if err != nil {
+		return err
+	}
+
+	if c.Output == "markdown" {
+		if err := markdown.WriteViolations(os.Stdout, len(invariants), violations); err != nil {
+			return err
+		}
+	} else {
+		for _, v := range violations {
+			fmt.Println(v.Message())
+		}
+	}
+	if len(violations) > 0 {
+		return &ExitError{Code: ExitDenied, Err: fmt.Errorf("%d invariant violations", len(violations))}
+	}
+	if c.Output != "markdown" {
+		fmt.Printf("All %d invariants hold.\n", len(invariants))
+	}
+	return nil
+}
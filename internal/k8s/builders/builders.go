@@ -30,6 +30,25 @@ func NewNamespaceBuilder(name string) *NamespaceBuilder {
 	}
 }
 
+func (b *NamespaceBuilder) AddLabel(key, value string) *NamespaceBuilder {
+	if b.Namespace.Labels == nil {
+		b.Namespace.Labels = map[string]string{}
+	}
+	b.Namespace.Labels[key] = value
+	return b
+}
+
+// Pods are the pods built in the namespace so far.
+func (b *NamespaceBuilder) Pods() []corev1.Pod {
+	pods := make([]corev1.Pod, 0)
+	for _, o := range b.Objects {
+		if pb, ok := o.(*PodBuilder); ok {
+			pods = append(pods, pb.Pod)
+		}
+	}
+	return pods
+}
+
 type PodBuilder struct {
 	corev1.Pod
 }
@@ -83,6 +102,20 @@ func (b *PodBuilder) AddPort(name string, num int32) *PodBuilder {
 	return b
 }
 
+func (b *PodBuilder) AddLabel(key, value string) *PodBuilder {
+	if b.Pod.Labels == nil {
+		b.Pod.Labels = map[string]string{}
+	}
+	b.Pod.Labels[key] = value
+	return b
+}
+
+// SetPodIP makes the pod look scheduled and running at ip.
+func (b *PodBuilder) SetPodIP(ip string) *PodBuilder {
+	b.Pod.Status.PodIP = ip
+	return b
+}
+
 type NetPolBuilder struct {
 	nwv1.NetworkPolicy
 }
@@ -0,0 +1,137 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	nwlisters "k8s.io/client-go/listers/networking/v1"
+)
+
+// CachedCluster answers queries from informer caches that watch a live cluster, so a long running server does not
+// list the whole cluster on every request. Results may trail the cluster by the time a watch event takes to arrive.
+type CachedCluster struct {
+	namespaces corelisters.NamespaceLister
+	pods       corelisters.PodLister
+	services   corelisters.ServiceLister
+	endpoints  corelisters.EndpointsLister
+	nodes      corelisters.NodeLister
+	netpols    nwlisters.NetworkPolicyLister
+}
+
+// NewCachedCluster starts informers on the session's cluster and waits for their first list. They stop when ctx is
+// done.
+func (s *K8sSession) NewCachedCluster(ctx context.Context) (*CachedCluster, error) {
+	return newCachedCluster(ctx, s.clientset)
+}
+
+func newCachedCluster(ctx context.Context, client kubernetes.Interface) (*CachedCluster, error) {
+	factory := informers.NewSharedInformerFactory(client, 0)
+	c := &CachedCluster{
+		namespaces: factory.Core().V1().Namespaces().Lister(),
+		pods:       factory.Core().V1().Pods().Lister(),
+		services:   factory.Core().V1().Services().Lister(),
+		endpoints:  factory.Core().V1().Endpoints().Lister(),
+		nodes:      factory.Core().V1().Nodes().Lister(),
+		netpols:    factory.Networking().V1().NetworkPolicies().Lister(),
+	}
+
+	factory.Start(ctx.Done())
+	for informer, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return nil, fmt.Errorf("error syncing the cache of %s", informer)
+		}
+	}
+	return c, nil
+}
+
+func (c *CachedCluster) QueryPod(ctx context.Context, namespace string, podName string) (*corev1.Pod, error) {
+	return c.pods.Pods(namespace).Get(podName)
+}
+
+// QueryPodList lists the pods in namespace. An empty namespace lists pods in all namespaces.
+func (c *CachedCluster) QueryPodList(ctx context.Context, namespace string) (*corev1.PodList, error) {
+	pods, err := c.pods.Pods(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	podList := &corev1.PodList{}
+	for _, pod := range pods {
+		podList.Items = append(podList.Items, *pod)
+	}
+	return podList, nil
+}
+
+func (c *CachedCluster) QueryNetPolList(ctx context.Context, namespace string) (*nwv1.NetworkPolicyList, error) {
+	netpols, err := c.netpols.NetworkPolicies(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	netpolList := &nwv1.NetworkPolicyList{}
+	for _, np := range netpols {
+		netpolList.Items = append(netpolList.Items, *np)
+	}
+	return netpolList, nil
+}
+
+func (c *CachedCluster) QueryNamespace(ctx context.Context, namespace string) (*corev1.Namespace, error) {
+	return c.namespaces.Get(namespace)
+}
+
+func (c *CachedCluster) QueryNamespaceList(ctx context.Context) (*corev1.NamespaceList, error) {
+	namespaces, err := c.namespaces.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	nsList := &corev1.NamespaceList{}
+	for _, ns := range namespaces {
+		nsList.Items = append(nsList.Items, *ns)
+	}
+	return nsList, nil
+}
+
+// QueryServiceList lists the services in namespace. An empty namespace lists services in all namespaces.
+func (c *CachedCluster) QueryServiceList(ctx context.Context, namespace string) (*corev1.ServiceList, error) {
+	services, err := c.services.Services(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	serviceList := &corev1.ServiceList{}
+	for _, svc := range services {
+		serviceList.Items = append(serviceList.Items, *svc)
+	}
+	return serviceList, nil
+}
+
+func (c *CachedCluster) QueryEndpoints(ctx context.Context, namespace string, name string) (*corev1.Endpoints, error) {
+	return c.endpoints.Endpoints(namespace).Get(name)
+}
+
+func (c *CachedCluster) QueryEndpointsList(ctx context.Context, namespace string) (*corev1.EndpointsList, error) {
+	endpoints, err := c.endpoints.Endpoints(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	endpointsList := &corev1.EndpointsList{}
+	for _, e := range endpoints {
+		endpointsList.Items = append(endpointsList.Items, *e)
+	}
+	return endpointsList, nil
+}
+
+func (c *CachedCluster) QueryNodeList(ctx context.Context) (*corev1.NodeList, error) {
+	nodes, err := c.nodes.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	nodeList := &corev1.NodeList{}
+	for _, node := range nodes {
+		nodeList.Items = append(nodeList.Items, *node)
+	}
+	return nodeList, nil
+}
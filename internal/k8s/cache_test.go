@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCachedCluster(t *testing.T) {
+	Convey("Queries are answered from the informer caches", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		client := fake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "NamespaceOne"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "NamespaceTwo"}},
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "PodOne", Namespace: "NamespaceOne"}},
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "PodTwo", Namespace: "NamespaceTwo"}},
+			&nwv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "PolicyOne", Namespace: "NamespaceOne"}},
+			&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "ServiceOne", Namespace: "NamespaceOne"}},
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "NodeOne"}},
+		)
+
+		c, err := newCachedCluster(ctx, client)
+		So(err, ShouldBeNil)
+
+		pod, err := c.QueryPod(ctx, "NamespaceOne", "PodOne")
+		So(err, ShouldBeNil)
+		So(pod.Name, ShouldEqual, "PodOne")
+
+		_, err = c.QueryPod(ctx, "NamespaceOne", "PodTwo")
+		So(apierrors.IsNotFound(err), ShouldBeTrue)
+
+		pods, err := c.QueryPodList(ctx, "")
+		So(err, ShouldBeNil)
+		So(pods.Items, ShouldHaveLength, 2)
+
+		namespaces, err := c.QueryNamespaceList(ctx)
+		So(err, ShouldBeNil)
+		So(namespaces.Items, ShouldHaveLength, 2)
+
+		netpols, err := c.QueryNetPolList(ctx, "NamespaceTwo")
+		So(err, ShouldBeNil)
+		So(netpols.Items, ShouldBeEmpty)
+
+		endpoints, err := c.QueryEndpointsList(ctx, "NamespaceOne")
+		So(err, ShouldBeNil)
+		So(endpoints.Items, ShouldHaveLength, 1)
+
+		nodes, err := c.QueryNodeList(ctx)
+		So(err, ShouldBeNil)
+		So(nodes.Items[0].Name, ShouldEqual, "NodeOne")
+	})
+}
@@ -0,0 +1,46 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/utils/exec"
+)
+
+// Exec runs command in a container of a running pod, as kubectl exec does, and returns its exit code and combined
+// output. A command that runs and exits non-zero is not an error.
+func (s *K8sSession) Exec(ctx context.Context, namespace, podName, container string, command []string) (int, string, error) {
+	req := s.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(s.config, "POST", req.URL())
+	if err != nil {
+		return 0, "", fmt.Errorf("error exec'ing in %s/%s: %w", namespace, podName, err)
+	}
+
+	// Interleave stdout and stderr as a terminal would
+	var output bytes.Buffer
+	err = executor.Stream(remotecommand.StreamOptions{Stdout: &output, Stderr: &output})
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), output.String(), nil
+	}
+	if err != nil {
+		return 0, output.String(), fmt.Errorf("error exec'ing in %s/%s: %w", namespace, podName, err)
+	}
+	return 0, output.String(), nil
+}
@@ -0,0 +1,213 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	nwv1 "k8s.io/api/networking/v1"
+)
+
+// Manifest is a NetworkPolicy read from a file, along with where in the file it was found so problems can be
+// reported against the source.
+type Manifest struct {
+	Path          string
+	NetworkPolicy nwv1.NetworkPolicy
+	node          *yaml.Node
+}
+
+// Line finds the line of the field at path within the manifest, for example "spec", "ingress", 0, "from", 1. If the
+// whole path does not exist, the line of the deepest field that does is returned.
+func (m Manifest) Line(path ...any) int {
+	node := m.node
+	line := node.Line
+	for _, p := range path {
+		var next *yaml.Node
+		switch key := p.(type) {
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					if node.Content[i].Value == key {
+						next = node.Content[i+1]
+						line = node.Content[i].Line
+					}
+				}
+			}
+		case int:
+			if node.Kind == yaml.SequenceNode && key < len(node.Content) {
+				next = node.Content[key]
+				line = next.Line
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return line
+}
+
+// LoadManifests reads the NetworkPolicies in files and directories of YAML or JSON. Other kinds of resources are
+// ignored. Policies without a namespace are put in defaultNamespace and policies without policyTypes are defaulted, as
+// kubectl apply would.
+func LoadManifests(paths []string, defaultNamespace string) ([]Manifest, error) {
+	manifests := make([]Manifest, 0)
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if path != root && ext != ".yaml" && ext != ".yml" && ext != ".json" {
+				return nil
+			}
+
+			bs, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("error reading manifest: %w", err)
+			}
+			ms, err := ReadManifests(path, bytes.NewReader(bs), defaultNamespace)
+			if err != nil {
+				return err
+			}
+			manifests = append(manifests, ms...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return manifests, nil
+}
+
+// ReadManifests reads the NetworkPolicies in a stream of YAML documents. path is only used to identify them.
+func ReadManifests(path string, r io.Reader, defaultNamespace string) ([]Manifest, error) {
+	manifests := make([]Manifest, 0)
+	decoder := yaml.NewDecoder(r)
+	for {
+		doc := &yaml.Node{}
+		err := decoder.Decode(doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		ms, err := readManifestNode(path, doc.Content[0], defaultNamespace)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, ms...)
+	}
+	return manifests, nil
+}
+
+func readManifestNode(path string, node *yaml.Node, defaultNamespace string) ([]Manifest, error) {
+	typeMeta := struct {
+		Kind  string      `yaml:"kind"`
+		Items []yaml.Node `yaml:"items"`
+	}{}
+	if err := node.Decode(&typeMeta); err != nil {
+		return nil, fmt.Errorf("error parsing %s line %d: %w", path, node.Line, err)
+	}
+
+	switch typeMeta.Kind {
+	case "List", "NetworkPolicyList":
+		manifests := make([]Manifest, 0)
+		for i := range typeMeta.Items {
+			ms, err := readManifestNode(path, &typeMeta.Items[i], defaultNamespace)
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, ms...)
+		}
+		return manifests, nil
+	case "NetworkPolicy":
+	default:
+		return nil, nil
+	}
+
+	// Convert through JSON so the API types' json tags apply
+	var obj any
+	if err := node.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("error parsing %s line %d: %w", path, node.Line, err)
+	}
+	bs, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s line %d: %w", path, node.Line, err)
+	}
+	np := nwv1.NetworkPolicy{}
+	if err := json.Unmarshal(bs, &np); err != nil {
+		return nil, fmt.Errorf("error parsing NetworkPolicy in %s line %d: %w", path, node.Line, err)
+	}
+	if np.Namespace == "" {
+		np.Namespace = defaultNamespace
+	}
+	defaultPolicyTypes(&np)
+
+	m := Manifest{Path: path, NetworkPolicy: np, node: node}
+	if err := m.validateIPBlocks(); err != nil {
+		return nil, err
+	}
+	return []Manifest{m}, nil
+}
+
+// validateIPBlocks checks the CIDRs the API server would, so a typo is reported against the file instead of being
+// evaluated.
+func (m Manifest) validateIPBlocks() error {
+	check := func(direction string, peers []nwv1.NetworkPolicyPeer, rule int) error {
+		for i, peer := range peers {
+			if peer.IPBlock == nil {
+				continue
+			}
+			field := "from"
+			if direction == "egress" {
+				field = "to"
+			}
+			for _, cidr := range append([]string{peer.IPBlock.CIDR}, peer.IPBlock.Except...) {
+				if _, _, err := net.ParseCIDR(cidr); err != nil {
+					return fmt.Errorf("invalid ipBlock CIDR %q in %s line %d", cidr, m.Path, m.Line("spec", direction, rule, field, i, "ipBlock"))
+				}
+			}
+		}
+		return nil
+	}
+	for i, rule := range m.NetworkPolicy.Spec.Ingress {
+		if err := check("ingress", rule.From, i); err != nil {
+			return err
+		}
+	}
+	for i, rule := range m.NetworkPolicy.Spec.Egress {
+		if err := check("egress", rule.To, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultPolicyTypes sets policyTypes when it is omitted, as the API server does: Ingress always, and Egress when
+// the policy has egress rules. Evaluation only applies a policy in the directions it lists.
+func defaultPolicyTypes(np *nwv1.NetworkPolicy) {
+	if len(np.Spec.PolicyTypes) > 0 {
+		return
+	}
+	np.Spec.PolicyTypes = []nwv1.PolicyType{nwv1.PolicyTypeIngress}
+	if len(np.Spec.Egress) > 0 {
+		np.Spec.PolicyTypes = append(np.Spec.PolicyTypes, nwv1.PolicyTypeEgress)
+	}
+}
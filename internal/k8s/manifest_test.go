@@ -0,0 +1,99 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	nwv1 "k8s.io/api/networking/v1"
+)
+
+const manifestYAML = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: allow-web
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+  ingress:
+  - from:
+    - podSelector: {}
+    - ipBlock:
+        cidr: 0.0.0.0/0
+    ports:
+    - port: 80
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: networking.k8s.io/v1
+  kind: NetworkPolicy
+  metadata:
+    name: deny-all
+    namespace: NamespaceTwo
+  spec:
+    podSelector: {}
+    egress:
+    - to:
+      - podSelector: {}
+`
+
+func TestReadManifests(t *testing.T) {
+	Convey("NetworkPolicies are read from multiple documents and lists", t, func() {
+		manifests, err := ReadManifests("policies.yaml", strings.NewReader(manifestYAML), "NamespaceOne")
+		So(err, ShouldBeNil)
+		So(manifests, ShouldHaveLength, 2)
+
+		So(manifests[0].Path, ShouldEqual, "policies.yaml")
+		So(manifests[0].NetworkPolicy.Namespace, ShouldEqual, "NamespaceOne")
+		So(manifests[0].NetworkPolicy.Name, ShouldEqual, "allow-web")
+		So(manifests[0].NetworkPolicy.Spec.PodSelector.MatchLabels, ShouldResemble, map[string]string{"app": "web"})
+		So(manifests[0].NetworkPolicy.Spec.Ingress[0].From, ShouldHaveLength, 2)
+
+		So(manifests[1].NetworkPolicy.Namespace, ShouldEqual, "NamespaceTwo")
+		So(manifests[1].NetworkPolicy.Name, ShouldEqual, "deny-all")
+	})
+
+	Convey("Omitted policyTypes are defaulted as the API server does", t, func() {
+		manifests, err := ReadManifests("policies.yaml", strings.NewReader(manifestYAML), "NamespaceOne")
+		So(err, ShouldBeNil)
+		So(manifests[0].NetworkPolicy.Spec.PolicyTypes, ShouldResemble, []nwv1.PolicyType{nwv1.PolicyTypeIngress})
+		So(manifests[1].NetworkPolicy.Spec.PolicyTypes, ShouldResemble, []nwv1.PolicyType{nwv1.PolicyTypeIngress, nwv1.PolicyTypeEgress})
+
+		explicit := strings.Replace(manifestYAML, "    podSelector: {}\n    egress:", "    podSelector: {}\n    policyTypes: [Egress]\n    egress:", 1)
+		manifests, err = ReadManifests("policies.yaml", strings.NewReader(explicit), "NamespaceOne")
+		So(err, ShouldBeNil)
+		So(manifests[1].NetworkPolicy.Spec.PolicyTypes, ShouldResemble, []nwv1.PolicyType{nwv1.PolicyTypeEgress})
+	})
+
+	Convey("Lines of fields within a manifest", t, func() {
+		manifests, err := ReadManifests("policies.yaml", strings.NewReader(manifestYAML), "NamespaceOne")
+		So(err, ShouldBeNil)
+		m := manifests[0]
+
+		So(m.Line(), ShouldEqual, 6)
+		So(m.Line("spec", "ingress", 0), ShouldEqual, 15)
+		So(m.Line("spec", "ingress", 0, "from", 1), ShouldEqual, 17)
+		So(m.Line("spec", "ingress", 0, "ports", 0), ShouldEqual, 20)
+		// Missing fields fall back to the deepest field that exists
+		So(m.Line("spec", "egress", 0), ShouldEqual, 10)
+		So(m.Line("spec", "ingress", 3), ShouldEqual, 14)
+	})
+
+	Convey("An invalid ipBlock is an error naming the line", t, func() {
+		invalid := strings.Replace(manifestYAML, "cidr: 0.0.0.0/0", "cidr: 0.0.0.0/33", 1)
+		_, err := ReadManifests("policies.yaml", strings.NewReader(invalid), "NamespaceOne")
+		So(err, ShouldBeError, `invalid ipBlock CIDR "0.0.0.0/33" in policies.yaml line 17`)
+	})
+
+	Convey("Invalid YAML is an error naming the file", t, func() {
+		_, err := ReadManifests("policies.yaml", strings.NewReader("kind: [NetworkPolicy"), "NamespaceOne")
+		So(err.Error(), ShouldStartWith, "error parsing policies.yaml")
+	})
+}
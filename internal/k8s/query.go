@@ -3,38 +3,75 @@ package k8s
 import (
 	"context"
 	"fmt"
-
-	"path/filepath"
+	"os"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	nwv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	restclient "k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 )
 
+// Cluster is the read-only view of cluster state that evaluation needs. It is implemented by a live
+// K8sSession and by a Snapshot loaded from disk.
+type Cluster interface {
+	QueryPod(ctx context.Context, namespace string, podName string) (*corev1.Pod, error)
+	QueryPodList(ctx context.Context, namespace string) (*corev1.PodList, error)
+	QueryNetPolList(ctx context.Context, namespace string) (*nwv1.NetworkPolicyList, error)
+	QueryNamespace(ctx context.Context, namespace string) (*corev1.Namespace, error)
+	QueryNamespaceList(ctx context.Context) (*corev1.NamespaceList, error)
+	QueryServiceList(ctx context.Context, namespace string) (*corev1.ServiceList, error)
+	QueryEndpoints(ctx context.Context, namespace string, name string) (*corev1.Endpoints, error)
+	QueryEndpointsList(ctx context.Context, namespace string) (*corev1.EndpointsList, error)
+	QueryNodeList(ctx context.Context) (*corev1.NodeList, error)
+}
+
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
 type K8sSession struct {
 	// fyi, Config has max QPS and Burst settings
 	config *restclient.Config
 	// Share the same http connections for all clients.
 	clientset *kubernetes.Clientset
+	// Namespace of the current context, or the --namespace override.
+	namespace string
 }
 
-func NewSession(kubeconfig string) (*K8sSession, error) {
-	if kubeconfig == "" {
-		if home := homedir.HomeDir(); home != "" {
-			kubeconfig = filepath.Join(home, ".kube", "config")
-		}
+// NewSession connects using the same kubeconfig resolution as kubectl: --kubeconfig, $KUBECONFIG, then
+// ~/.kube/config, with --context, --cluster, --user, --as and friends applied on top.
+func NewSession(configFlags *genericclioptions.ConfigFlags) (*K8sSession, error) {
+	config, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	namespace, _, err := configFlags.ToRawKubeConfigLoader().Namespace()
 	if err != nil {
 		return nil, err
 	}
 
+	return newSession(config, namespace)
+}
+
+// NewInClusterSession connects with the service account of the pod netpoltool is running in.
+func NewInClusterSession() (*K8sSession, error) {
+	config, err := restclient.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := metav1.NamespaceDefault
+	if bs, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+		namespace = strings.TrimSpace(string(bs))
+	}
+
+	return newSession(config, namespace)
+}
+
+func newSession(config *restclient.Config, namespace string) (*K8sSession, error) {
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
@@ -43,9 +80,15 @@ func NewSession(kubeconfig string) (*K8sSession, error) {
 	return &K8sSession{
 		config:    config,
 		clientset: clientset,
+		namespace: namespace,
 	}, nil
 }
 
+// DefaultNamespace is the namespace kubectl would use when none is specified.
+func (s *K8sSession) DefaultNamespace() string {
+	return s.namespace
+}
+
 func (s *K8sSession) QueryPod(ctx context.Context, namespace string, podName string) (*corev1.Pod, error) {
 	pod, err := s.clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 	if err != nil {
@@ -62,7 +105,7 @@ func (s *K8sSession) QueryPod(ctx context.Context, namespace string, podName str
 func (s *K8sSession) QueryNetPolList(ctx context.Context, namespace string) (*nwv1.NetworkPolicyList, error) {
 	netpolList, err := s.clientset.NetworkingV1().NetworkPolicies(namespace).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("error querying for NetworkPolicies %s", err.Error())
+		return nil, fmt.Errorf("error querying for NetworkPolicies %w", err)
 	}
 	return netpolList, nil
 }
@@ -70,3 +113,53 @@ func (s *K8sSession) QueryNetPolList(ctx context.Context, namespace string) (*nw
 func (s *K8sSession) QueryNamespace(ctx context.Context, namespace string) (*corev1.Namespace, error) {
 	return s.clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
 }
+
+func (s *K8sSession) QueryNamespaceList(ctx context.Context) (*corev1.NamespaceList, error) {
+	nsList, err := s.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error querying for Namespaces %w", err)
+	}
+	return nsList, nil
+}
+
+// QueryPodList lists the pods in namespace. An empty namespace lists pods in all namespaces.
+func (s *K8sSession) QueryPodList(ctx context.Context, namespace string) (*corev1.PodList, error) {
+	podList, err := s.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error querying for Pods %w", err)
+	}
+	return podList, nil
+}
+
+// QueryServiceList lists the services in namespace. An empty namespace lists services in all namespaces.
+func (s *K8sSession) QueryServiceList(ctx context.Context, namespace string) (*corev1.ServiceList, error) {
+	serviceList, err := s.clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error querying for Services %w", err)
+	}
+	return serviceList, nil
+}
+
+func (s *K8sSession) QueryEndpoints(ctx context.Context, namespace string, name string) (*corev1.Endpoints, error) {
+	endpoints, err := s.clientset.CoreV1().Endpoints(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error querying for Endpoints %w", err)
+	}
+	return endpoints, nil
+}
+
+func (s *K8sSession) QueryEndpointsList(ctx context.Context, namespace string) (*corev1.EndpointsList, error) {
+	endpointsList, err := s.clientset.CoreV1().Endpoints(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error querying for Endpoints %w", err)
+	}
+	return endpointsList, nil
+}
+
+func (s *K8sSession) QueryNodeList(ctx context.Context) (*corev1.NodeList, error) {
+	nodeList, err := s.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error querying for Nodes %w", err)
+	}
+	return nodeList, nil
+}
@@ -0,0 +1,383 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const SnapshotVersion = 1
+
+// Snapshot is a point in time copy of the cluster state used by NetworkPolicy evaluation. Only the fields that
+// evaluation reads are kept so a snapshot is safe to attach to a ticket: no env, args, annotations or volumes.
+type Snapshot struct {
+	Version         int                  `json:"version"`
+	Namespaces      []corev1.Namespace   `json:"namespaces"`
+	Pods            []corev1.Pod         `json:"pods"`
+	NetworkPolicies []nwv1.NetworkPolicy `json:"networkPolicies"`
+	// Services and Endpoints translate ClusterIPs to pods. Snapshots taken by older versions have none.
+	Services  []corev1.Service   `json:"services,omitempty"`
+	Endpoints []corev1.Endpoints `json:"endpoints,omitempty"`
+	// Nodes are the source of NodePort and LoadBalancer traffic that kube-proxy SNATs.
+	Nodes []corev1.Node `json:"nodes,omitempty"`
+}
+
+// TakeSnapshot copies the state of cluster. All namespaces are captured because their labels are needed to evaluate
+// namespaceSelectors, but pods and policies are limited to the given namespaces. No namespaces means all of them.
+// Without permission to list namespaces, only the given ones are captured. Nodes are only needed to evaluate
+// connections with nodes and the API server, so they are skipped unless requested and left out when forbidden.
+func TakeSnapshot(ctx context.Context, cluster Cluster, namespaces []string, nodes bool) (*Snapshot, error) {
+	nsList, err := queryNamespaces(ctx, cluster, namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(namespaces) == 0 {
+		for _, ns := range nsList.Items {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+
+	snapshot := &Snapshot{Version: SnapshotVersion}
+	for _, ns := range nsList.Items {
+		snapshot.Namespaces = append(snapshot.Namespaces, sanitizeNamespace(ns))
+	}
+
+	if nodes {
+		nodeList, err := cluster.QueryNodeList(ctx)
+		if err != nil && !apierrors.IsForbidden(err) {
+			return nil, err
+		}
+		if err == nil {
+			for _, node := range nodeList.Items {
+				snapshot.Nodes = append(snapshot.Nodes, sanitizeNode(node))
+			}
+		}
+	}
+
+	for _, namespace := range namespaces {
+		podList, err := cluster.QueryPodList(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range podList.Items {
+			snapshot.Pods = append(snapshot.Pods, sanitizePod(pod))
+		}
+
+		netpolList, err := cluster.QueryNetPolList(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, np := range netpolList.Items {
+			snapshot.NetworkPolicies = append(snapshot.NetworkPolicies, sanitizeNetPol(np))
+		}
+
+		serviceList, err := cluster.QueryServiceList(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, svc := range serviceList.Items {
+			snapshot.Services = append(snapshot.Services, sanitizeService(svc))
+		}
+
+		// One list instead of a get per Service. Services without selectors may not have Endpoints.
+		endpointsList, err := cluster.QueryEndpointsList(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, endpoints := range endpointsList.Items {
+			snapshot.Endpoints = append(snapshot.Endpoints, sanitizeEndpoints(endpoints))
+		}
+	}
+
+	return snapshot, nil
+}
+
+// queryNamespaces lists every namespace, or gets each of the given namespaces when listing is forbidden as it is with
+// namespace-scoped RBAC.
+func queryNamespaces(ctx context.Context, cluster Cluster, namespaces []string) (*corev1.NamespaceList, error) {
+	nsList, err := cluster.QueryNamespaceList(ctx)
+	if err == nil || !apierrors.IsForbidden(err) || len(namespaces) == 0 {
+		return nsList, err
+	}
+
+	nsList = &corev1.NamespaceList{}
+	for _, name := range namespaces {
+		ns, err := cluster.QueryNamespace(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		nsList.Items = append(nsList.Items, *ns)
+	}
+	return nsList, nil
+}
+
+func LoadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening snapshot: %w", err)
+	}
+	defer f.Close()
+	return ReadSnapshot(f)
+}
+
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(snapshot); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot: %w", err)
+	}
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d, expected %d", snapshot.Version, SnapshotVersion)
+	}
+	return snapshot, nil
+}
+
+func (s *Snapshot) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+func (s *Snapshot) QueryPod(ctx context.Context, namespace string, podName string) (*corev1.Pod, error) {
+	for i := range s.Pods {
+		if s.Pods[i].Namespace == namespace && s.Pods[i].Name == podName {
+			return &s.Pods[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unable to find pod %s in %s in the snapshot", podName, namespace)
+}
+
+func (s *Snapshot) QueryPodList(ctx context.Context, namespace string) (*corev1.PodList, error) {
+	podList := &corev1.PodList{}
+	for _, pod := range s.Pods {
+		if namespace == "" || pod.Namespace == namespace {
+			podList.Items = append(podList.Items, pod)
+		}
+	}
+	return podList, nil
+}
+
+func (s *Snapshot) QueryNetPolList(ctx context.Context, namespace string) (*nwv1.NetworkPolicyList, error) {
+	netpolList := &nwv1.NetworkPolicyList{}
+	for _, np := range s.NetworkPolicies {
+		if np.Namespace == namespace {
+			netpolList.Items = append(netpolList.Items, np)
+		}
+	}
+	return netpolList, nil
+}
+
+func (s *Snapshot) QueryNamespace(ctx context.Context, namespace string) (*corev1.Namespace, error) {
+	for i := range s.Namespaces {
+		if s.Namespaces[i].Name == namespace {
+			return &s.Namespaces[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unable to find namespace %s in the snapshot", namespace)
+}
+
+func (s *Snapshot) QueryNamespaceList(ctx context.Context) (*corev1.NamespaceList, error) {
+	return &corev1.NamespaceList{Items: s.Namespaces}, nil
+}
+
+// QueryServiceList lists the services in namespace. An empty namespace lists services in all namespaces.
+func (s *Snapshot) QueryServiceList(ctx context.Context, namespace string) (*corev1.ServiceList, error) {
+	serviceList := &corev1.ServiceList{}
+	for _, svc := range s.Services {
+		if namespace == "" || svc.Namespace == namespace {
+			serviceList.Items = append(serviceList.Items, svc)
+		}
+	}
+	return serviceList, nil
+}
+
+func (s *Snapshot) QueryEndpoints(ctx context.Context, namespace string, name string) (*corev1.Endpoints, error) {
+	for i := range s.Endpoints {
+		if s.Endpoints[i].Namespace == namespace && s.Endpoints[i].Name == name {
+			return &s.Endpoints[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unable to find endpoints %s in %s in the snapshot", name, namespace)
+}
+
+func (s *Snapshot) QueryEndpointsList(ctx context.Context, namespace string) (*corev1.EndpointsList, error) {
+	endpointsList := &corev1.EndpointsList{}
+	for _, endpoints := range s.Endpoints {
+		if endpoints.Namespace == namespace {
+			endpointsList.Items = append(endpointsList.Items, endpoints)
+		}
+	}
+	return endpointsList, nil
+}
+
+func (s *Snapshot) QueryNodeList(ctx context.Context) (*corev1.NodeList, error) {
+	return &corev1.NodeList{Items: s.Nodes}, nil
+}
+
+// WithNetworkPolicy copies the snapshot with np added, or replacing the policy of the same namespace and name.
+func (s *Snapshot) WithNetworkPolicy(np nwv1.NetworkPolicy) *Snapshot {
+	c := s.WithoutNetworkPolicy(np.Namespace, np.Name)
+	c.NetworkPolicies = append(c.NetworkPolicies, sanitizeNetPol(np))
+	return c
+}
+
+// WithoutNetworkPolicy copies the snapshot without the policy named name in namespace.
+func (s *Snapshot) WithoutNetworkPolicy(namespace, name string) *Snapshot {
+	c := *s
+	c.NetworkPolicies = make([]nwv1.NetworkPolicy, 0, len(s.NetworkPolicies)+1)
+	for _, np := range s.NetworkPolicies {
+		if np.Namespace != namespace || np.Name != name {
+			c.NetworkPolicies = append(c.NetworkPolicies, np)
+		}
+	}
+	return &c
+}
+
+// WithNamespacePolicies copies the snapshot with the policies of namespaces replaced by policies.
+func (s *Snapshot) WithNamespacePolicies(namespaces []string, policies []nwv1.NetworkPolicy) *Snapshot {
+	replaced := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		replaced[ns] = true
+	}
+
+	c := *s
+	c.NetworkPolicies = make([]nwv1.NetworkPolicy, 0, len(s.NetworkPolicies)+len(policies))
+	for _, np := range s.NetworkPolicies {
+		if !replaced[np.Namespace] {
+			c.NetworkPolicies = append(c.NetworkPolicies, np)
+		}
+	}
+	for _, np := range policies {
+		c.NetworkPolicies = append(c.NetworkPolicies, sanitizeNetPol(np))
+	}
+	return &c
+}
+
+func sanitizeNamespace(ns corev1.Namespace) corev1.Namespace {
+	return corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   ns.Name,
+			Labels: ns.Labels,
+		},
+	}
+}
+
+func sanitizeNode(node corev1.Node) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   node.Name,
+			Labels: node.Labels,
+		},
+		Status: corev1.NodeStatus{
+			Addresses: node.Status.Addresses,
+		},
+	}
+}
+
+// sanitizePod copies only what evaluation needs. Build the pod up from nothing instead of deleting fields so that
+// fields added to the API later cannot leak into a snapshot.
+func sanitizePod(pod corev1.Pod) corev1.Pod {
+	sanitizeContainers := func(containers []corev1.Container) []corev1.Container {
+		sanitized := make([]corev1.Container, 0, len(containers))
+		for _, c := range containers {
+			sanitized = append(sanitized, corev1.Container{
+				Name:  c.Name,
+				Ports: c.Ports,
+				// Distinguishes sidecars from init containers that exit before the pod starts
+				RestartPolicy: c.RestartPolicy,
+			})
+		}
+		return sanitized
+	}
+
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			Namespace:       pod.Namespace,
+			Labels:          pod.Labels,
+			OwnerReferences: pod.OwnerReferences,
+		},
+		Spec: corev1.PodSpec{
+			NodeName:       pod.Spec.NodeName,
+			HostNetwork:    pod.Spec.HostNetwork,
+			InitContainers: sanitizeContainers(pod.Spec.InitContainers),
+			Containers:     sanitizeContainers(pod.Spec.Containers),
+		},
+		Status: corev1.PodStatus{
+			Phase:  pod.Status.Phase,
+			HostIP: pod.Status.HostIP,
+			PodIP:  pod.Status.PodIP,
+			PodIPs: pod.Status.PodIPs,
+		},
+	}
+}
+
+func sanitizeService(svc corev1.Service) corev1.Service {
+	return corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      svc.Name,
+			Namespace: svc.Namespace,
+			Labels:    svc.Labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:                  svc.Spec.Type,
+			Selector:              svc.Spec.Selector,
+			Ports:                 svc.Spec.Ports,
+			ClusterIP:             svc.Spec.ClusterIP,
+			ClusterIPs:            svc.Spec.ClusterIPs,
+			ExternalIPs:           svc.Spec.ExternalIPs,
+			ExternalTrafficPolicy: svc.Spec.ExternalTrafficPolicy,
+		},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: svc.Status.LoadBalancer,
+		},
+	}
+}
+
+func sanitizeEndpoints(endpoints corev1.Endpoints) corev1.Endpoints {
+	sanitizeAddresses := func(addresses []corev1.EndpointAddress) []corev1.EndpointAddress {
+		sanitized := make([]corev1.EndpointAddress, 0, len(addresses))
+		for _, a := range addresses {
+			address := corev1.EndpointAddress{IP: a.IP, NodeName: a.NodeName}
+			if a.TargetRef != nil {
+				address.TargetRef = &corev1.ObjectReference{Kind: a.TargetRef.Kind, Namespace: a.TargetRef.Namespace, Name: a.TargetRef.Name}
+			}
+			sanitized = append(sanitized, address)
+		}
+		return sanitized
+	}
+
+	subsets := make([]corev1.EndpointSubset, 0, len(endpoints.Subsets))
+	for _, subset := range endpoints.Subsets {
+		subsets = append(subsets, corev1.EndpointSubset{
+			Addresses:         sanitizeAddresses(subset.Addresses),
+			NotReadyAddresses: sanitizeAddresses(subset.NotReadyAddresses),
+			Ports:             subset.Ports,
+		})
+	}
+	return corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      endpoints.Name,
+			Namespace: endpoints.Namespace,
+		},
+		Subsets: subsets,
+	}
+}
+
+func sanitizeNetPol(np nwv1.NetworkPolicy) nwv1.NetworkPolicy {
+	return nwv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      np.Name,
+			Namespace: np.Namespace,
+			Labels:    np.Labels,
+		},
+		Spec: np.Spec,
+	}
+}
@@ -0,0 +1,185 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// restrictedCluster is forbidden from listing the resources in forbidden, as a user with namespace-scoped RBAC is.
+type restrictedCluster struct {
+	*Snapshot
+	forbidden []string
+}
+
+func (c *restrictedCluster) check(resource string) error {
+	for _, f := range c.forbidden {
+		if f == resource {
+			return apierrors.NewForbidden(schema.GroupResource{Resource: resource}, "", errors.New("RBAC denied"))
+		}
+	}
+	return nil
+}
+
+func (c *restrictedCluster) QueryEndpointsList(ctx context.Context, namespace string) (*corev1.EndpointsList, error) {
+	if err := c.check("endpoints"); err != nil {
+		return nil, err
+	}
+	return c.Snapshot.QueryEndpointsList(ctx, namespace)
+}
+
+func (c *restrictedCluster) QueryNamespaceList(ctx context.Context) (*corev1.NamespaceList, error) {
+	if err := c.check("namespaces"); err != nil {
+		return nil, err
+	}
+	return c.Snapshot.QueryNamespaceList(ctx)
+}
+
+func (c *restrictedCluster) QueryNodeList(ctx context.Context) (*corev1.NodeList, error) {
+	if err := c.check("nodes"); err != nil {
+		return nil, err
+	}
+	return c.Snapshot.QueryNodeList(ctx)
+}
+
+func TestSnapshot(t *testing.T) {
+	source := &Snapshot{
+		Version: SnapshotVersion,
+		Namespaces: []corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "NamespaceOne", Labels: map[string]string{"name": "NamespaceOne"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "NamespaceTwo", Annotations: map[string]string{"secret": "value"}}},
+		},
+		Pods: []corev1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "PodOne",
+					Namespace:   "NamespaceOne",
+					Labels:      map[string]string{"name": "PodOne"},
+					Annotations: map[string]string{"secret": "value"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "ContainerOne",
+						Args:  []string{"--password=secret"},
+						Env:   []corev1.EnvVar{{Name: "PASSWORD", Value: "secret"}},
+						Ports: []corev1.ContainerPort{{Name: "api", ContainerPort: 3000, Protocol: corev1.ProtocolTCP}},
+					}},
+				},
+				Status: corev1.PodStatus{PodIP: "10.0.0.1"},
+			},
+			{ObjectMeta: metav1.ObjectMeta{Name: "PodTwo", Namespace: "NamespaceTwo"}},
+		},
+		NetworkPolicies: []nwv1.NetworkPolicy{
+			{ObjectMeta: metav1.ObjectMeta{Name: "PolicyOne", Namespace: "NamespaceOne"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "PolicyTwo", Namespace: "NamespaceTwo"}},
+		},
+		Services: []corev1.Service{{ObjectMeta: metav1.ObjectMeta{Name: "ServiceOne", Namespace: "NamespaceOne"}}},
+		Endpoints: []corev1.Endpoints{{
+			ObjectMeta: metav1.ObjectMeta{Name: "ServiceOne", Namespace: "NamespaceOne"},
+			Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+		}},
+		Nodes: []corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "NodeOne"}}},
+	}
+
+	Convey("TakeSnapshot", t, func() {
+		Convey("Strips env, args and annotations", func() {
+			snapshot, err := TakeSnapshot(context.TODO(), source, nil, true)
+			So(err, ShouldBeNil)
+			So(snapshot.Pods, ShouldHaveLength, 2)
+
+			pod := snapshot.Pods[0]
+			So(pod.Annotations, ShouldBeNil)
+			So(pod.Labels, ShouldResemble, map[string]string{"name": "PodOne"})
+			So(pod.Spec.Containers[0].Env, ShouldBeNil)
+			So(pod.Spec.Containers[0].Args, ShouldBeNil)
+			So(pod.Spec.Containers[0].Ports, ShouldResemble, source.Pods[0].Spec.Containers[0].Ports)
+			So(pod.Status.PodIP, ShouldEqual, "10.0.0.1")
+			So(snapshot.Namespaces[1].Annotations, ShouldBeNil)
+		})
+
+		Convey("Limits pods and policies, but not namespaces, to the requested namespaces", func() {
+			snapshot, err := TakeSnapshot(context.TODO(), source, []string{"NamespaceTwo"}, true)
+			So(err, ShouldBeNil)
+			So(snapshot.Namespaces, ShouldHaveLength, 2)
+			So(snapshot.Pods, ShouldHaveLength, 1)
+			So(snapshot.Pods[0].Name, ShouldEqual, "PodTwo")
+			So(snapshot.NetworkPolicies, ShouldHaveLength, 1)
+			So(snapshot.NetworkPolicies[0].Name, ShouldEqual, "PolicyTwo")
+		})
+
+		Convey("Captures the Endpoints of each namespace", func() {
+			snapshot, err := TakeSnapshot(context.TODO(), source, nil, true)
+			So(err, ShouldBeNil)
+			So(snapshot.Services, ShouldHaveLength, 1)
+			So(snapshot.Endpoints, ShouldHaveLength, 1)
+			So(snapshot.Endpoints[0].Subsets[0].Addresses[0].IP, ShouldEqual, "10.0.0.1")
+		})
+
+		Convey("Returns an error when Endpoints are forbidden instead of leaving Services without backends", func() {
+			_, err := TakeSnapshot(context.TODO(), &restrictedCluster{Snapshot: source, forbidden: []string{"endpoints"}}, nil, true)
+			So(apierrors.IsForbidden(err), ShouldBeTrue)
+		})
+
+		Convey("Only captures nodes when requested", func() {
+			snapshot, err := TakeSnapshot(context.TODO(), source, nil, true)
+			So(err, ShouldBeNil)
+			So(snapshot.Nodes, ShouldHaveLength, 1)
+
+			snapshot, err = TakeSnapshot(context.TODO(), source, nil, false)
+			So(err, ShouldBeNil)
+			So(snapshot.Nodes, ShouldBeEmpty)
+		})
+
+		Convey("Leaves out nodes when they are forbidden", func() {
+			restricted := &restrictedCluster{Snapshot: source, forbidden: []string{"nodes"}}
+			snapshot, err := TakeSnapshot(context.TODO(), restricted, nil, true)
+			So(err, ShouldBeNil)
+			So(snapshot.Nodes, ShouldBeEmpty)
+			So(snapshot.Pods, ShouldHaveLength, 2)
+		})
+
+		Convey("Captures only the requested namespaces when listing namespaces is forbidden", func() {
+			restricted := &restrictedCluster{Snapshot: source, forbidden: []string{"namespaces"}}
+			snapshot, err := TakeSnapshot(context.TODO(), restricted, []string{"NamespaceTwo"}, false)
+			So(err, ShouldBeNil)
+			So(snapshot.Namespaces, ShouldHaveLength, 1)
+			So(snapshot.Namespaces[0].Name, ShouldEqual, "NamespaceTwo")
+			So(snapshot.Pods, ShouldHaveLength, 1)
+
+			_, err = TakeSnapshot(context.TODO(), restricted, nil, false)
+			So(apierrors.IsForbidden(err), ShouldBeTrue)
+		})
+	})
+
+	Convey("A written snapshot can be read and queried", t, func() {
+		buf := &bytes.Buffer{}
+		So(source.Write(buf), ShouldBeNil)
+
+		snapshot, err := ReadSnapshot(buf)
+		So(err, ShouldBeNil)
+
+		pod, err := snapshot.QueryPod(context.TODO(), "NamespaceOne", "PodOne")
+		So(err, ShouldBeNil)
+		So(pod.Status.PodIP, ShouldEqual, "10.0.0.1")
+
+		_, err = snapshot.QueryPod(context.TODO(), "NamespaceTwo", "PodOne")
+		So(err, ShouldBeError)
+
+		netpolList, err := snapshot.QueryNetPolList(context.TODO(), "NamespaceOne")
+		So(err, ShouldBeNil)
+		So(netpolList.Items, ShouldHaveLength, 1)
+	})
+
+	Convey("Rejects an unknown snapshot version", t, func() {
+		_, err := ReadSnapshot(bytes.NewBufferString(`{"version": 99}`))
+		So(err, ShouldBeError)
+	})
+}
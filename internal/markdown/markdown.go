@@ -0,0 +1,119 @@
+// Package markdown summarizes results as GitHub flavored markdown sized for a pull request comment.
+package markdown
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/app/invariant"
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// MaxRows limits each table so a large change still fits in a comment. The rows left out are counted.
+const MaxRows = 30
+
+// WriteEquivalence summarizes the connections that differ between two sets of policies and the policies that changed.
+func WriteEquivalence(w io.Writer, e *app.Equivalence) error {
+	b := bufio.NewWriter(w)
+	if e.Equivalent() {
+		fmt.Fprintf(b, "### netpoltool equiv: equivalent\n\nAll %d pairs of pods allow the same ports.\n", e.Pairs)
+	} else {
+		fmt.Fprintf(b, "### netpoltool equiv: %d changed connections\n\n", len(e.Counterexamples))
+		fmt.Fprintf(b, "%d of %d pairs of pods allow different ports.\n\n", len(e.Counterexamples), e.Pairs)
+		rows := make([][]string, 0, len(e.Counterexamples))
+		for _, c := range e.Counterexamples {
+			rows = append(rows, []string{code(c.Source.GetName()), code(c.Dest.GetName()), portSet(c.Lost), portSet(c.Gained)})
+		}
+		writeTable(b, []string{"Source", "Destination", "Only before", "Only after"}, rows)
+	}
+
+	if len(e.Changes) > 0 {
+		fmt.Fprintf(b, "\n**Policies touched**\n\n")
+		rows := make([][]string, 0, len(e.Changes))
+		for _, c := range e.Changes {
+			rows = append(rows, []string{code(c.Policy), string(c.Change)})
+		}
+		writeTable(b, []string{"Policy", "Change"}, rows)
+	}
+	return b.Flush()
+}
+
+// WriteViolations summarizes the connections that break invariants and the policies that decided them.
+func WriteViolations(w io.Writer, invariants int, violations []invariant.Violation) error {
+	b := bufio.NewWriter(w)
+	if len(violations) == 0 {
+		fmt.Fprintf(b, "### netpoltool verify: all %d invariants hold\n", invariants)
+		return b.Flush()
+	}
+
+	fmt.Fprintf(b, "### netpoltool verify: %d failing expectations\n\n", len(violations))
+	touched := make(map[string]bool)
+	rows := make([][]string, 0, len(violations))
+	for _, v := range violations {
+		outcome := "denied"
+		if v.Result.Allowed {
+			outcome = "allowed"
+		}
+		// The directions that decided the outcome: both when allowed, the ones that denied otherwise
+		decisive := make([]string, 0)
+		if v.Result.EgressAllowed == v.Result.Allowed {
+			decisive = append(decisive, eval.DecisivePolicies(v.Result.Allowed, v.Result.Egress)...)
+		}
+		if v.Result.IngressAllowed == v.Result.Allowed {
+			decisive = append(decisive, eval.DecisivePolicies(v.Result.Allowed, v.Result.Ingress)...)
+		}
+		for _, np := range decisive {
+			touched[np] = true
+		}
+		rows = append(rows, []string{
+			code(v.Invariant.Name),
+			string(v.Invariant.Expect),
+			code(v.Source),
+			code(v.Dest),
+			fmt.Sprintf("%d/%s", v.Result.ToPort.Num, v.Result.ToPort.Protocol),
+			outcome,
+			strings.Join(util.Map(decisive, code), ", "),
+		})
+	}
+	writeTable(b, []string{"Invariant", "Expect", "Source", "Destination", "Port", "Result", "Decided by"}, rows)
+
+	if len(touched) > 0 {
+		policies := make([]string, 0, len(touched))
+		for np := range touched {
+			policies = append(policies, np)
+		}
+		sort.Strings(policies)
+		fmt.Fprintf(b, "\n**Policies touched:** %s\n", strings.Join(util.Map(policies, code), ", "))
+	}
+	return b.Flush()
+}
+
+// writeTable writes at most MaxRows rows, followed by a count of the rest.
+func writeTable(w io.Writer, header []string, rows [][]string) {
+	fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(header)))
+	for i, row := range rows {
+		if i == MaxRows {
+			fmt.Fprintf(w, "\n_and %d more_\n", len(rows)-MaxRows)
+			break
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+	}
+}
+
+func portSet(s eval.PortSet) string {
+	if s.IsEmpty() {
+		return ""
+	}
+	return s.String()
+}
+
+// code formats s as inline code, escaping the pipes that would otherwise split a table cell.
+func code(s string) string {
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
@@ -0,0 +1,115 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/app/invariant"
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+)
+
+func TestMarkdown(t *testing.T) {
+	newPod := func(name string) *eval.PodConnection {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "Shop"}}
+		pod, err := eval.NewPodConnection(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "Shop"},
+			Status:     corev1.PodStatus{PodIP: "10.0.0.1"},
+		}, ns, nil, "")
+		if err != nil {
+			panic(err.Error())
+		}
+		return pod
+	}
+	ports := func(first, last int32) eval.PortSet {
+		s := eval.PortSet{}
+		s.Add(corev1.ProtocolTCP, first, last)
+		return s
+	}
+
+	Convey("WriteEquivalence", t, func() {
+		e := &app.Equivalence{
+			Pairs:   2,
+			Changes: []app.PolicyChange{{Policy: "Shop/Web", Change: app.PolicyChanged}},
+			Counterexamples: []app.Counterexample{
+				{Source: newPod("Api"), Dest: newPod("Web"), Lost: ports(8443, 8443), Gained: eval.PortSet{}},
+			},
+		}
+		var buf bytes.Buffer
+		So(WriteEquivalence(&buf, e), ShouldBeNil)
+		So(buf.String(), ShouldEqual, strings.Join([]string{
+			"### netpoltool equiv: 1 changed connections",
+			"",
+			"1 of 2 pairs of pods allow different ports.",
+			"",
+			"| Source | Destination | Only before | Only after |",
+			"| --- | --- | --- | --- |",
+			"| `Shop/Api` | `Shop/Web` | TCP 8443 |  |",
+			"",
+			"**Policies touched**",
+			"",
+			"| Policy | Change |",
+			"| --- | --- |",
+			"| `Shop/Web` | changed |",
+			"",
+		}, "\n"))
+
+		Convey("Limits the rows", func() {
+			for i := 0; i < MaxRows+4; i++ {
+				e.Counterexamples = append(e.Counterexamples, e.Counterexamples[0])
+			}
+			buf.Reset()
+			So(WriteEquivalence(&buf, e), ShouldBeNil)
+			So(strings.Count(buf.String(), "| `Shop/Api` |"), ShouldEqual, MaxRows)
+			So(buf.String(), ShouldContainSubstring, "_and 5 more_")
+		})
+
+		Convey("Equivalent", func() {
+			e.Counterexamples = nil
+			buf.Reset()
+			So(WriteEquivalence(&buf, e), ShouldBeNil)
+			So(buf.String(), ShouldStartWith, "### netpoltool equiv: equivalent\n\nAll 2 pairs of pods allow the same ports.\n")
+		})
+	})
+
+	Convey("WriteViolations", t, func() {
+		deny := nwv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "Deny|All", Namespace: "Shop"}}
+		allow := nwv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "AllowEgress", Namespace: "Shop"}}
+		violation := invariant.Violation{
+			Invariant: invariant.Invariant{Name: "api-reaches-web", Expect: invariant.ExpectAllow},
+			Source:    "Shop/Api",
+			Dest:      "Shop/Web",
+			Result: eval.PortResult{
+				ToPort:        eval.DestinationPort{Num: 8080, Protocol: corev1.ProtocolTCP},
+				Egress:        []eval.NetpolResult{{Netpol: allow, EvalResult: eval.Allow}},
+				Ingress:       []eval.NetpolResult{{Netpol: deny, EvalResult: eval.Deny}},
+				EgressAllowed: true,
+			},
+		}
+
+		var buf bytes.Buffer
+		So(WriteViolations(&buf, 3, []invariant.Violation{violation}), ShouldBeNil)
+		So(buf.String(), ShouldEqual, strings.Join([]string{
+			"### netpoltool verify: 1 failing expectations",
+			"",
+			"| Invariant | Expect | Source | Destination | Port | Result | Decided by |",
+			"| --- | --- | --- | --- | --- | --- | --- |",
+			"| `api-reaches-web` | allow | `Shop/Api` | `Shop/Web` | 8080/TCP | denied | `Shop/Deny\\|All` |",
+			"",
+			"**Policies touched:** `Shop/Deny\\|All`",
+			"",
+		}, "\n"))
+
+		Convey("All hold", func() {
+			buf.Reset()
+			So(WriteViolations(&buf, 3, nil), ShouldBeNil)
+			So(buf.String(), ShouldEqual, "### netpoltool verify: all 3 invariants hold\n")
+		})
+	})
+}
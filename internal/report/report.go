@@ -0,0 +1,164 @@
+// Package report writes a self-contained HTML report of the connectivity of a set of namespaces, for readers without
+// cluster access. The page has no external dependencies so it can be attached to a ticket or sent to an auditor.
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	nwv1 "k8s.io/api/networking/v1"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/app/lint"
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+//go:embed report.html
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Parse(reportHTML))
+
+const (
+	// graphSize is the width and height of the graph's SVG viewBox.
+	graphSize = 600
+	// nodeRadius is the radius of a pod in the graph. Edges stop short of it so their arrows show.
+	nodeRadius = 10
+)
+
+type Report struct {
+	Namespaces []string
+	Generated  time.Time
+	Pods       []Pod
+	// Cells[i][j] is Pods[i] connecting to Pods[j].
+	Cells    [][]Cell
+	Edges    []Edge
+	Findings []lint.Finding
+	Skipped  []string
+}
+
+type Pod struct {
+	Index int
+	Name  string
+	// IngressPolicies and EgressPolicies select the pod. None means that direction is not isolated.
+	IngressPolicies []string
+	EgressPolicies  []string
+	// X and Y place the pod in the graph.
+	X, Y float64
+}
+
+type Cell struct {
+	// Class is allowed when every port is allowed, partial when some are, denied when none are and none when the
+	// destination declares no ports.
+	Class string
+	Title string
+}
+
+// Edge is an allowed connection between two different pods.
+type Edge struct {
+	Source, Dest           int
+	X1, Y1, X2, Y2         float64
+	Partial                bool
+	AllowedPorts, AllPorts int
+}
+
+// New builds a report from the matrix of namespaces and the lint findings of their policies.
+func New(namespaces []string, m *app.Matrix, findings []lint.Finding, generated time.Time) *Report {
+	r := &Report{
+		Namespaces: namespaces,
+		Generated:  generated,
+		Pods:       make([]Pod, 0, len(m.Pods)),
+		Cells:      make([][]Cell, 0, len(m.Cells)),
+		Edges:      make([]Edge, 0),
+		Findings:   findings,
+		Skipped:    util.Map(m.Skipped, func(err error) string { return err.Error() }),
+	}
+
+	radius := graphSize/2 - 80.0
+	for i, pod := range m.Pods {
+		angle := 2*math.Pi*float64(i)/float64(len(m.Pods)) - math.Pi/2
+		r.Pods = append(r.Pods, Pod{
+			Index:           i,
+			Name:            pod.GetName(),
+			IngressPolicies: selectingPolicies(pod, nwv1.PolicyTypeIngress),
+			EgressPolicies:  selectingPolicies(pod, nwv1.PolicyTypeEgress),
+			X:               graphSize/2 + radius*math.Cos(angle),
+			Y:               graphSize/2 + radius*math.Sin(angle),
+		})
+	}
+
+	for i, row := range m.Cells {
+		cells := make([]Cell, 0, len(row))
+		for j, e := range row {
+			cells = append(cells, newCell(e))
+			if i != j && e.AllowedCount() > 0 {
+				x1, y1, x2, y2 := shorten(r.Pods[i].X, r.Pods[i].Y, r.Pods[j].X, r.Pods[j].Y, nodeRadius+4)
+				r.Edges = append(r.Edges, Edge{
+					Source:       i,
+					Dest:         j,
+					X1:           x1,
+					Y1:           y1,
+					X2:           x2,
+					Y2:           y2,
+					Partial:      e.AllowedCount() < len(e.Results),
+					AllowedPorts: e.AllowedCount(),
+					AllPorts:     len(e.Results),
+				})
+			}
+		}
+		r.Cells = append(r.Cells, cells)
+	}
+	return r
+}
+
+func (r *Report) Write(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+func newCell(e app.Evaluation) Cell {
+	lines := []string{fmt.Sprintf("%s -> %s", e.Source.GetName(), e.Dest.GetName())}
+	for _, pr := range e.Results {
+		verdict := "denied"
+		if pr.Allowed {
+			verdict = "allowed"
+		}
+		lines = append(lines, fmt.Sprintf("%s %d/%s %s", pr.ToPort.Name, pr.ToPort.Num, pr.ToPort.Protocol, verdict))
+	}
+
+	c := Cell{Class: "allowed", Title: strings.Join(lines, "\n")}
+	switch allowed := e.AllowedCount(); {
+	case len(e.Results) == 0:
+		c.Class = "none"
+	case allowed == 0:
+		c.Class = "denied"
+	case allowed < len(e.Results):
+		c.Class = "partial"
+	}
+	return c
+}
+
+// selectingPolicies names the policies of pod's namespace that select it for policyType.
+func selectingPolicies(pod *eval.PodConnection, policyType nwv1.PolicyType) []string {
+	names := make([]string, 0)
+	for _, np := range pod.GetPolicies() {
+		if util.Contains(np.Spec.PolicyTypes, policyType) && pod.MatchPodSelector(np.Spec.PodSelector) {
+			names = append(names, np.Namespace+"/"+np.Name)
+		}
+	}
+	return names
+}
+
+// shorten moves both ends of a line toward each other by by.
+func shorten(x1, y1, x2, y2, by float64) (float64, float64, float64, float64) {
+	length := math.Hypot(x2-x1, y2-y1)
+	if length <= 2*by {
+		return x1, y1, x2, y2
+	}
+	dx, dy := (x2-x1)/length*by, (y2-y1)/length*by
+	return x1 + dx, y1 + dy, x2 - dx, y2 - dy
+}
@@ -0,0 +1,103 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/app/lint"
+	"github.com/cheriot/netpoltool/internal/k8s"
+)
+
+func TestReport(t *testing.T) {
+	newPod := func(name, ip string, ports ...corev1.ContainerPort) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "Shop", Labels: map[string]string{"app": name}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Ports: ports}}},
+			Status:     corev1.PodStatus{PodIP: ip},
+		}
+	}
+	http := corev1.ContainerPort{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}
+	httpPort := intstr.FromInt(8080)
+	admin := corev1.ContainerPort{Name: "admin", ContainerPort: 9090, Protocol: corev1.ProtocolTCP}
+	snapshot := &k8s.Snapshot{
+		Version:    k8s.SnapshotVersion,
+		Namespaces: []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "Shop"}}},
+		Pods: []corev1.Pod{
+			newPod("Api", "10.0.0.1", http),
+			newPod("Web", "10.0.0.2", http, admin),
+			newPod("Job", "10.0.0.3"),
+		},
+		NetworkPolicies: []nwv1.NetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "WebHttp", Namespace: "Shop"},
+			Spec: nwv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "Web"}},
+				PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+				Ingress: []nwv1.NetworkPolicyIngressRule{
+					{
+						Ports: []nwv1.NetworkPolicyPort{{Port: &httpPort}},
+						From:  []nwv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "Api"}}}},
+					},
+					{
+						From: []nwv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "Job"}}}},
+					},
+				},
+			},
+		}},
+	}
+	a := app.NewClusterApp(snapshot, "Shop")
+	m, err := a.Matrix(context.TODO(), nil)
+	if err != nil {
+		panic(err.Error())
+	}
+	findings, err := a.Lint(context.TODO(), []string{"Shop"})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	Convey("Report", t, func() {
+		r := New([]string{"Shop"}, m, findings, time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC))
+
+		Convey("Classifies cells", func() {
+			So(r.Cells[0][1].Class, ShouldEqual, "partial")
+			So(r.Cells[0][1].Title, ShouldEqual, "Shop/Api -> Shop/Web\nhttp 8080/TCP allowed\nadmin 9090/TCP denied")
+			So(r.Cells[1][0].Class, ShouldEqual, "allowed")
+			So(r.Cells[2][1].Class, ShouldEqual, "allowed")
+			So(r.Cells[1][1].Class, ShouldEqual, "denied")
+			So(r.Cells[0][2].Class, ShouldEqual, "none")
+		})
+
+		Convey("Draws an edge for each allowed connection between different pods", func() {
+			So(r.Edges, ShouldHaveLength, 4)
+			So(r.Edges[0].Source, ShouldEqual, 0)
+			So(r.Edges[0].Dest, ShouldEqual, 1)
+			So(r.Edges[0].Partial, ShouldBeTrue)
+		})
+
+		Convey("Lists the policies selecting each pod", func() {
+			So(r.Pods[1].IngressPolicies, ShouldResemble, []string{"Shop/WebHttp"})
+			So(r.Pods[1].EgressPolicies, ShouldBeEmpty)
+			So(r.Pods[0].IngressPolicies, ShouldBeEmpty)
+		})
+
+		Convey("Writes a self-contained page", func() {
+			var buf bytes.Buffer
+			So(r.Write(&buf), ShouldBeNil)
+			html := buf.String()
+			So(html, ShouldContainSubstring, "Generated 2022-01-02 03:04:05 UTC")
+			So(html, ShouldContainSubstring, `<td class="partial" title="Shop/Api -&gt; Shop/Web`)
+			So(html, ShouldContainSubstring, `data-source="0" data-dest="1"`)
+			So(html, ShouldContainSubstring, string(lint.CheckAllPorts))
+			So(html, ShouldNotContainSubstring, "<script src")
+			So(html, ShouldNotContainSubstring, "<link")
+		})
+	})
+}
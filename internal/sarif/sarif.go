@@ -0,0 +1,179 @@
+// Package sarif writes findings in the Static Analysis Results Interchange Format so code scanning tools can annotate
+// the manifests that caused them. Only the parts of SARIF 2.1.0 that netpoltool uses are modeled.
+package sarif
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+
+	"github.com/cheriot/netpoltool/internal/app/flowlog"
+	"github.com/cheriot/netpoltool/internal/app/lint"
+)
+
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	toolName = "netpoltool"
+	toolURI  = "https://github.com/cheriot/netpoltool"
+)
+
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []Run  `json:"runs"`
+}
+
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+type Driver struct {
+	Name           string                `json:"name"`
+	InformationURI string                `json:"informationUri"`
+	Rules          []ReportingDescriptor `json:"rules"`
+}
+
+type ReportingDescriptor struct {
+	ID               string  `json:"id"`
+	ShortDescription Message `json:"shortDescription"`
+}
+
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+}
+
+type Message struct {
+	Text string `json:"text"`
+}
+
+type Location struct {
+	PhysicalLocation *PhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []LogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           Region           `json:"region"`
+}
+
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type Region struct {
+	StartLine int `json:"startLine"`
+}
+
+type LogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind,omitempty"`
+}
+
+func (l Log) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(l)
+}
+
+func newLog(rules []ReportingDescriptor, results []Result) Log {
+	return Log{
+		Version: Version,
+		Schema:  Schema,
+		Runs: []Run{{
+			Tool: Tool{Driver: Driver{
+				Name:           toolName,
+				InformationURI: toolURI,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+func newPhysicalLocation(path string, line int) *PhysicalLocation {
+	return &PhysicalLocation{
+		ArtifactLocation: ArtifactLocation{URI: filepath.ToSlash(path)},
+		Region:           Region{StartLine: line},
+	}
+}
+
+// Locator finds the file and line of a lint finding. ok is false for findings from a cluster instead of files.
+type Locator func(f lint.Finding) (path string, line int, ok bool)
+
+// FromLint converts lint findings. locate may be nil when the policies did not come from files.
+func FromLint(findings []lint.Finding, locate Locator) Log {
+	rules := make([]ReportingDescriptor, 0, len(lint.Checks))
+	for _, c := range lint.Checks {
+		rules = append(rules, ReportingDescriptor{ID: string(c), ShortDescription: Message{Text: c.Description()}})
+	}
+
+	results := make([]Result, 0, len(findings))
+	for _, f := range findings {
+		location := Location{LogicalLocations: []LogicalLocation{{
+			FullyQualifiedName: f.Namespace + "/" + f.Policy + "/" + f.Location(),
+			Kind:               "object",
+		}}}
+		if locate != nil {
+			if path, line, ok := locate(f); ok {
+				location.PhysicalLocation = newPhysicalLocation(path, line)
+			}
+		}
+		results = append(results, Result{
+			RuleID:    string(f.Check),
+			Level:     lintLevel(f.Severity),
+			Message:   Message{Text: f.Message},
+			Locations: []Location{location},
+		})
+	}
+	return newLog(rules, results)
+}
+
+func lintLevel(s lint.Severity) string {
+	switch s {
+	case lint.SeverityHigh:
+		return "error"
+	case lint.SeverityMedium:
+		return "warning"
+	}
+	return "note"
+}
+
+// FromAudit converts audit findings, located at their line in the flow log.
+func FromAudit(findings []flowlog.Finding, flowLogPath string) Log {
+	rules := []ReportingDescriptor{
+		{ID: auditRuleID(flowlog.DeniedButForwarded), ShortDescription: Message{Text: "A flow the CNI forwarded is denied by NetworkPolicy and will break when policies are enforced."}},
+		{ID: auditRuleID(flowlog.AllowedButDropped), ShortDescription: Message{Text: "A flow NetworkPolicy allows was dropped by the CNI."}},
+	}
+
+	results := make([]Result, 0, len(findings))
+	for _, f := range findings {
+		level := "error"
+		if f.Disagreement == flowlog.AllowedButDropped {
+			level = "warning"
+		}
+		results = append(results, Result{
+			RuleID:    auditRuleID(f.Disagreement),
+			Level:     level,
+			Message:   Message{Text: f.Message()},
+			Locations: []Location{{PhysicalLocation: newPhysicalLocation(flowLogPath, f.Flow.Line)}},
+		})
+	}
+	return newLog(rules, results)
+}
+
+func auditRuleID(d flowlog.Disagreement) string {
+	if d == flowlog.AllowedButDropped {
+		return "allowed-but-dropped"
+	}
+	return "denied-but-forwarded"
+}
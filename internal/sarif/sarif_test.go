@@ -0,0 +1,67 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	nwv1 "k8s.io/api/networking/v1"
+
+	"github.com/cheriot/netpoltool/internal/app/flowlog"
+	"github.com/cheriot/netpoltool/internal/app/lint"
+)
+
+func TestFromLint(t *testing.T) {
+	findings := []lint.Finding{
+		{Namespace: "NamespaceOne", Policy: "Broad", Direction: nwv1.PolicyTypeEgress, Rule: 1, Peer: 0, Port: -1, Check: lint.CheckAnyIP, Severity: lint.SeverityHigh, Message: "any IP"},
+		{Namespace: "NamespaceOne", Policy: "Remote", Direction: nwv1.PolicyTypeIngress, Rule: 0, Peer: -1, Port: -1, Check: lint.CheckAllPorts, Severity: lint.SeverityLow, Message: "all ports"},
+	}
+	locate := func(f lint.Finding) (string, int, bool) {
+		if f.Policy == "Broad" {
+			return "policies/broad.yaml", 12, true
+		}
+		return "", 0, false
+	}
+
+	Convey("Findings become results with the file and line when known", t, func() {
+		log := FromLint(findings, locate)
+		So(log.Version, ShouldEqual, Version)
+		So(log.Runs[0].Tool.Driver.Rules, ShouldHaveLength, len(lint.Checks))
+
+		results := log.Runs[0].Results
+		So(results, ShouldHaveLength, 2)
+		So(results[0].RuleID, ShouldEqual, "any-ip")
+		So(results[0].Level, ShouldEqual, "error")
+		So(results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI, ShouldEqual, "policies/broad.yaml")
+		So(results[0].Locations[0].PhysicalLocation.Region.StartLine, ShouldEqual, 12)
+		So(results[0].Locations[0].LogicalLocations[0].FullyQualifiedName, ShouldEqual, "NamespaceOne/Broad/egress[1].to[0]")
+
+		So(results[1].Level, ShouldEqual, "note")
+		So(results[1].Locations[0].PhysicalLocation, ShouldBeNil)
+	})
+
+	Convey("The log is written as JSON", t, func() {
+		buf := &bytes.Buffer{}
+		So(FromLint(findings, nil).Write(buf), ShouldBeNil)
+		decoded := map[string]any{}
+		So(json.Unmarshal(buf.Bytes(), &decoded), ShouldBeNil)
+		So(decoded["$schema"], ShouldEqual, Schema)
+	})
+}
+
+func TestFromAudit(t *testing.T) {
+	Convey("Audit findings are located in the flow log", t, func() {
+		log := FromAudit([]flowlog.Finding{{
+			Flow:         flowlog.ResolvedFlow{Flow: flowlog.Flow{Port: 80, Protocol: "TCP", Line: 7}},
+			Disagreement: flowlog.AllowedButDropped,
+		}}, "flows.json")
+
+		results := log.Runs[0].Results
+		So(results, ShouldHaveLength, 1)
+		So(results[0].RuleID, ShouldEqual, "allowed-but-dropped")
+		So(results[0].Level, ShouldEqual, "warning")
+		So(results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI, ShouldEqual, "flows.json")
+		So(results[0].Locations[0].PhysicalLocation.Region.StartLine, ShouldEqual, 7)
+	})
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/app/coverage"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// Exporter publishes isolation metrics in the Prometheus text format. Querying the whole cluster is expensive so
+// metrics are computed by Refresh and scrapes are served from the last result.
+type Exporter struct {
+	app *app.App
+
+	// refreshMu allows one Refresh at a time so a slow refresh is not repeated by the next.
+	refreshMu sync.Mutex
+
+	mu          sync.Mutex
+	body        []byte
+	errorsTotal int
+}
+
+func NewExporter(a *app.App) *Exporter {
+	return &Exporter{app: a}
+}
+
+// Run refreshes the metrics every interval until ctx is done. An interval of zero refreshes them once.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		if err := e.Refresh(ctx); err != nil {
+			util.Log.Errorf("error refreshing metrics: %s", err.Error())
+		}
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := e.Refresh(ctx); err != nil {
+			util.Log.Errorf("error refreshing metrics: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Exporter) Refresh(ctx context.Context) error {
+	e.refreshMu.Lock()
+	defer e.refreshMu.Unlock()
+
+	coverages, err := e.app.Coverage(ctx, nil)
+	if err != nil {
+		e.countErrors(1)
+		return err
+	}
+
+	namespaces := util.Map(coverages, func(c coverage.NamespaceCoverage) string { return c.Namespace })
+	skipped, err := e.app.SkippedPods(ctx, namespaces)
+	if err != nil {
+		e.countErrors(1)
+		return err
+	}
+
+	b := &bytes.Buffer{}
+	w := &metricWriter{b: b}
+
+	w.header("netpoltool_namespace_pods", "gauge", "Number of pods in the namespace.")
+	for _, c := range coverages {
+		w.sample("netpoltool_namespace_pods", len(c.Pods), "namespace", c.Namespace)
+	}
+
+	w.header("netpoltool_pods_without_ingress_policy", "gauge", "Number of pods not selected by any ingress NetworkPolicy, so all ingress is allowed.")
+	for _, c := range coverages {
+		w.sample("netpoltool_pods_without_ingress_policy", len(c.PodsWithoutIngress), "namespace", c.Namespace)
+	}
+
+	w.header("netpoltool_pods_without_egress_policy", "gauge", "Number of pods not selected by any egress NetworkPolicy, so all egress is allowed.")
+	for _, c := range coverages {
+		w.sample("netpoltool_pods_without_egress_policy", len(c.PodsWithoutEgress), "namespace", c.Namespace)
+	}
+
+	w.header("netpoltool_namespace_default_deny", "gauge", "1 if the namespace has a default-deny NetworkPolicy for the policy type.")
+	withoutIngress, withoutEgress := 0, 0
+	for _, c := range coverages {
+		w.sample("netpoltool_namespace_default_deny", boolToInt(c.HasDefaultDenyIngress()), "namespace", c.Namespace, "policy_type", "ingress")
+		w.sample("netpoltool_namespace_default_deny", boolToInt(c.HasDefaultDenyEgress()), "namespace", c.Namespace, "policy_type", "egress")
+		withoutIngress += 1 - boolToInt(c.HasDefaultDenyIngress())
+		withoutEgress += 1 - boolToInt(c.HasDefaultDenyEgress())
+	}
+
+	w.header("netpoltool_namespaces_without_default_deny", "gauge", "Number of namespaces without a default-deny NetworkPolicy for the policy type.")
+	w.sample("netpoltool_namespaces_without_default_deny", withoutIngress, "policy_type", "ingress")
+	w.sample("netpoltool_namespaces_without_default_deny", withoutEgress, "policy_type", "egress")
+
+	w.header("netpoltool_skipped_pods", "gauge", "Number of pods left out of the last refresh, usually because they have no IP yet.")
+	w.sample("netpoltool_skipped_pods", len(skipped))
+
+	w.header("netpoltool_last_refresh_timestamp_seconds", "gauge", "Unix time the metrics were last computed.")
+	w.sample("netpoltool_last_refresh_timestamp_seconds", int(time.Now().Unix()))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.body = b.Bytes()
+	return nil
+}
+
+// ServeHTTP serves the last refresh. Until there is one it is unavailable rather than starting a refresh per scrape.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.body == nil {
+		http.Error(w, "metrics have not been computed yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(e.body)
+
+	// The error counter changes between refreshes so it is not part of the cached body.
+	mw := &metricWriter{b: &bytes.Buffer{}}
+	mw.header("netpoltool_evaluation_errors_total", "counter", "Number of refreshes that failed.")
+	mw.sample("netpoltool_evaluation_errors_total", e.errorsTotal)
+	w.Write(mw.b.Bytes())
+}
+
+func (e *Exporter) countErrors(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errorsTotal += n
+}
+
+type metricWriter struct {
+	b *bytes.Buffer
+}
+
+func (w *metricWriter) header(name, metricType, help string) {
+	fmt.Fprintf(w.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// sample writes one value. labels are name, value pairs.
+func (w *metricWriter) sample(name string, value int, labels ...string) {
+	if len(labels) == 0 {
+		fmt.Fprintf(w.b, "%s %d\n", name, value)
+		return
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1])))
+	}
+	fmt.Fprintf(w.b, "%s{%s} %d\n", name, strings.Join(pairs, ","), value)
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,288 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	eval "github.com/cheriot/netpoltool/internal/app/netpoleval"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// Server exposes App queries as an HTTP/JSON API so connectivity can be checked without cluster credentials.
+type Server struct {
+	app      *app.App
+	mux      *http.ServeMux
+	Exporter *Exporter
+}
+
+func New(a *app.App) *Server {
+	s := &Server{
+		app:      a,
+		mux:      http.NewServeMux(),
+		Exporter: NewExporter(a),
+	}
+	s.mux.HandleFunc("/healthz", s.handleHealthz)
+	s.mux.Handle("/metrics", s.Exporter)
+	s.mux.HandleFunc("/api/v1/eval", s.handleEval(false))
+	s.mux.HandleFunc("/api/v1/explain", s.handleEval(true))
+	s.mux.HandleFunc("/api/v1/matrix", s.handleMatrix)
+	s.mux.HandleFunc("/api/v1/who-can-reach", s.handleWhoCanReach)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	util.Log.Debugf("%s %s", r.Method, r.URL)
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed, use GET", r.Method))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+type EvalResponse struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// Allowed is true when every port is, as the verdict allowed is.
+	Allowed bool `json:"allowed"`
+	// Verdict is allowed, partially allowed or denied, with the exit code rules of the CLI.
+	Verdict string         `json:"verdict"`
+	Ports   []PortResponse `json:"ports"`
+	// Via explains how the destination was found, like the backend of a Service.
+	Via string `json:"via,omitempty"`
+	// Backends are evaluated separately when the destination is a Service or hostname with more than one.
+	Backends []EvalResponse `json:"backends,omitempty"`
+}
+
+type PortResponse struct {
+	Name           string           `json:"name,omitempty"`
+	Port           int32            `json:"port"`
+	Protocol       string           `json:"protocol"`
+	Container      string           `json:"container,omitempty"`
+	Undeclared     bool             `json:"undeclared,omitempty"`
+	Allowed        bool             `json:"allowed"`
+	EgressAllowed  bool             `json:"egressAllowed"`
+	IngressAllowed bool             `json:"ingressAllowed"`
+	Egress         []PolicyResponse `json:"egress,omitempty"`
+	Ingress        []PolicyResponse `json:"ingress,omitempty"`
+}
+
+type PolicyResponse struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Result    string `json:"result"`
+}
+
+type MatrixResponse struct {
+	Pods []string `json:"pods"`
+	// Cells[i][j] describes Pods[i] connecting to Pods[j]
+	Cells [][]MatrixCellResponse `json:"cells"`
+}
+
+type MatrixCellResponse struct {
+	Allowed      bool           `json:"allowed"`
+	Verdict      string         `json:"verdict"`
+	AllowedPorts []PortResponse `json:"allowedPorts"`
+}
+
+type WhoCanReachResponse struct {
+	Destination string         `json:"destination"`
+	Sources     []EvalResponse `json:"sources"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleEval answers eval and, with explain, includes the result of every NetworkPolicy that was considered.
+func (s *Server) handleEval(explain bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		q := app.EvalQuery{
+			Namespace:    params.Get("namespace"),
+			PodName:      params.Get("pod"),
+			ToNamespace:  params.Get("toNamespace"),
+			ToPodName:    params.Get("toPod"),
+			ToPort:       params.Get("toPort"),
+			ToExternalIP: params.Get("toExtIP"),
+			ToProtocol:   params.Get("toProtocol"),
+			ToNode:       params.Get("toNode"),
+			ToAPIServer:  params.Get("toAPIServer") == "true",
+		}
+
+		if q.PodName == "" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("pod is required"))
+			return
+		}
+		destinations := len(util.Filter([]bool{q.ToPodName != "", q.ToExternalIP != "", q.ToNode != "", q.ToAPIServer}, func(b bool) bool { return b }))
+		if destinations != 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("exactly one of toPod, toExtIP, toNode or toAPIServer is required"))
+			return
+		}
+		if (q.ToExternalIP != "" || q.ToNode != "") && q.ToPort == "" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("toPort is required with toExtIP and toNode"))
+			return
+		}
+		if q.Namespace == "" {
+			q.Namespace = s.app.DefaultNamespace()
+		}
+		if q.ToPodName != "" && q.ToNamespace == "" {
+			q.ToNamespace = q.Namespace
+		}
+
+		es, err := s.app.Evaluate(r.Context(), q)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		if len(es) == 0 {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("no addresses to evaluate for the destination"))
+			return
+		}
+		if len(es) == 1 {
+			writeJSON(w, http.StatusOK, newEvalResponse(&es[0], explain))
+			return
+		}
+
+		// A Service, hostname, node or API server with several addresses. The verdict covers every port of every address.
+		destination := q.ToExternalIP
+		if q.ToNode != "" {
+			destination = "node/" + q.ToNode
+		} else if q.ToAPIServer {
+			destination = "apiserver"
+		}
+		resp := EvalResponse{
+			Source:      es[0].Source.GetName(),
+			Destination: destination,
+			Ports:       []PortResponse{},
+		}
+		allowed, total := 0, 0
+		for i := range es {
+			resp.Backends = append(resp.Backends, newEvalResponse(&es[i], explain))
+			allowed += es[i].AllowedCount()
+			total += len(es[i].Results)
+		}
+		verdict := app.NewVerdict(allowed, total)
+		resp.Allowed = verdict == app.VerdictAllowed
+		resp.Verdict = verdict.String()
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) handleMatrix(w http.ResponseWriter, r *http.Request) {
+	m, err := s.app.Matrix(r.Context(), r.URL.Query()["namespace"])
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	resp := MatrixResponse{
+		Pods:  util.Map(m.Pods, func(p *eval.PodConnection) string { return p.GetName() }),
+		Cells: make([][]MatrixCellResponse, 0, len(m.Cells)),
+	}
+	for _, row := range m.Cells {
+		cells := make([]MatrixCellResponse, 0, len(row))
+		for _, e := range row {
+			allowed := util.Filter(e.Results, func(pr eval.PortResult) bool { return pr.Allowed })
+			verdict := app.NewVerdict(len(allowed), len(e.Results))
+			cells = append(cells, MatrixCellResponse{
+				Allowed:      verdict == app.VerdictAllowed,
+				Verdict:      verdict.String(),
+				AllowedPorts: util.Map(allowed, func(pr eval.PortResult) PortResponse { return newPortResponse(pr, false) }),
+			})
+		}
+		resp.Cells = append(resp.Cells, cells)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleWhoCanReach(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	toNamespace := params.Get("toNamespace")
+	toPodName := params.Get("toPod")
+	if toPodName == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("toPod is required"))
+		return
+	}
+	if toNamespace == "" {
+		toNamespace = s.app.DefaultNamespace()
+	}
+
+	reaches, err := s.app.WhoCanReach(r.Context(), toNamespace, toPodName, params.Get("toPort"), params["namespace"])
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, WhoCanReachResponse{
+		Destination: toNamespace + "/" + toPodName,
+		Sources:     util.Map(reaches, func(e app.Evaluation) EvalResponse { return newEvalResponse(&e, false) }),
+	})
+}
+
+func newEvalResponse(e *app.Evaluation, explain bool) EvalResponse {
+	verdict := app.NewVerdict(e.AllowedCount(), len(e.Results))
+	return EvalResponse{
+		Source:      e.Source.GetName(),
+		Destination: e.Dest.GetName(),
+		Allowed:     verdict == app.VerdictAllowed,
+		Verdict:     verdict.String(),
+		Ports:       util.Map(e.Results, func(pr eval.PortResult) PortResponse { return newPortResponse(pr, explain) }),
+		Via:         e.Via,
+	}
+}
+
+func newPortResponse(pr eval.PortResult, explain bool) PortResponse {
+	resp := PortResponse{
+		Name:           pr.ToPort.Name,
+		Port:           pr.ToPort.Num,
+		Protocol:       string(pr.ToPort.Protocol),
+		Container:      pr.ToPort.Container,
+		Undeclared:     pr.ToPort.Undeclared,
+		Allowed:        pr.Allowed,
+		EgressAllowed:  pr.EgressAllowed,
+		IngressAllowed: pr.IngressAllowed,
+	}
+	if explain {
+		resp.Egress = util.Map(pr.Egress, newPolicyResponse)
+		resp.Ingress = util.Map(pr.Ingress, newPolicyResponse)
+	}
+	return resp
+}
+
+func newPolicyResponse(npr eval.NetpolResult) PolicyResponse {
+	return PolicyResponse{
+		Namespace: npr.Netpol.Namespace,
+		Name:      npr.Netpol.Name,
+		Result:    eval.EvalResultString(npr.EvalResult),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		util.Log.Errorf("error writing response: %s", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+// errorStatus maps the class of err to a status. A missing or invalid object is the request's fault. The API server
+// refusing a request is a bad gateway and one that cannot be reached is unavailable.
+func errorStatus(err error) int {
+	switch app.ClassifyError(err) {
+	case app.ErrorCluster:
+		return http.StatusBadGateway
+	case app.ErrorUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusUnprocessableEntity
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/k8s"
+	"github.com/cheriot/netpoltool/internal/k8s/builders"
+)
+
+func TestServer(t *testing.T) {
+	namespaceOne := builders.NewNamespaceBuilder("NamespaceOne")
+	namespaceOne.NewPodBuilder("PodOne").SetPodIP("10.0.0.1").AddPort("api", 3000)
+	namespaceTwo := builders.NewNamespaceBuilder("NamespaceTwo")
+	namespaceTwo.NewPodBuilder("PodTwo").SetPodIP("10.0.0.2").AddPort("api", 3000)
+	snapshot := &k8s.Snapshot{
+		Version:    k8s.SnapshotVersion,
+		Namespaces: []corev1.Namespace{namespaceOne.Namespace, namespaceTwo.Namespace},
+		Pods:       append(namespaceOne.Pods(), namespaceTwo.Pods()...),
+		NetworkPolicies: []nwv1.NetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "DenyIngress", Namespace: "NamespaceOne"},
+			Spec:       nwv1.NetworkPolicySpec{PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress}},
+		}},
+	}
+	s := New(app.NewClusterApp(snapshot, "NamespaceOne"))
+
+	get := func(url string, body any) int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		So(json.Unmarshal(rec.Body.Bytes(), body), ShouldBeNil)
+		return rec.Code
+	}
+
+	Convey("eval", t, func() {
+		Convey("Allows a connection to a pod without policies", func() {
+			resp := EvalResponse{}
+			status := get("/api/v1/eval?pod=PodOne&toNamespace=NamespaceTwo&toPod=PodTwo", &resp)
+			So(status, ShouldEqual, http.StatusOK)
+			So(resp.Source, ShouldEqual, "NamespaceOne/PodOne")
+			So(resp.Allowed, ShouldBeTrue)
+			So(resp.Verdict, ShouldEqual, "allowed")
+			So(resp.Ports, ShouldHaveLength, 1)
+			So(resp.Ports[0].Ingress, ShouldBeNil)
+		})
+
+		Convey("Requires a destination", func() {
+			resp := ErrorResponse{}
+			status := get("/api/v1/eval?pod=PodOne", &resp)
+			So(status, ShouldEqual, http.StatusBadRequest)
+			So(resp.Error, ShouldNotBeEmpty)
+		})
+
+		Convey("Reports pods that do not exist", func() {
+			resp := ErrorResponse{}
+			status := get("/api/v1/eval?pod=DoesNotExist&toPod=PodOne", &resp)
+			So(status, ShouldEqual, http.StatusUnprocessableEntity)
+		})
+
+		Convey("Reports a destination without addresses", func() {
+			a := app.NewClusterApp(snapshot, "NamespaceOne")
+			a.SetResolver(noAddresses{})
+			rec := httptest.NewRecorder()
+			New(a).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/eval?pod=PodOne&toExtIP=nothing.internal&toPort=443&toProtocol=tcp", nil))
+			So(rec.Code, ShouldEqual, http.StatusUnprocessableEntity)
+		})
+
+		Convey("Reports cluster errors as a bad or unavailable gateway", func() {
+			pods := schema.GroupResource{Resource: "pods"}
+			So(errorStatus(apierrors.NewNotFound(pods, "PodOne")), ShouldEqual, http.StatusUnprocessableEntity)
+			So(errorStatus(fmt.Errorf("error querying: %w", apierrors.NewForbidden(pods, "PodOne", errors.New("RBAC")))), ShouldEqual, http.StatusBadGateway)
+			So(errorStatus(&url.Error{Op: "Get", URL: "https://10.0.0.1", Err: errors.New("connection refused")}), ShouldEqual, http.StatusServiceUnavailable)
+		})
+	})
+
+	Convey("explain includes the policies evaluated", t, func() {
+		resp := EvalResponse{}
+		status := get("/api/v1/explain?namespace=NamespaceTwo&pod=PodTwo&toNamespace=NamespaceOne&toPod=PodOne", &resp)
+		So(status, ShouldEqual, http.StatusOK)
+		So(resp.Allowed, ShouldBeFalse)
+		So(resp.Ports[0].Ingress, ShouldResemble, []PolicyResponse{
+			{Namespace: "NamespaceOne", Name: "DenyIngress", Result: "Deny"},
+		})
+	})
+
+	Convey("matrix", t, func() {
+		resp := MatrixResponse{}
+		status := get("/api/v1/matrix?namespace=NamespaceOne&namespace=NamespaceTwo", &resp)
+		So(status, ShouldEqual, http.StatusOK)
+		So(resp.Pods, ShouldResemble, []string{"NamespaceOne/PodOne", "NamespaceTwo/PodTwo"})
+		So(resp.Cells[0][1].Allowed, ShouldBeTrue)
+		So(resp.Cells[1][0].Allowed, ShouldBeFalse)
+	})
+
+	Convey("who-can-reach", t, func() {
+		resp := WhoCanReachResponse{}
+		status := get("/api/v1/who-can-reach?toNamespace=NamespaceTwo&toPod=PodTwo", &resp)
+		So(status, ShouldEqual, http.StatusOK)
+		So(resp.Sources, ShouldHaveLength, 2)
+		So(resp.Sources[0].Source, ShouldEqual, "NamespaceOne/PodOne")
+	})
+
+	Convey("metrics", t, func() {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		So(rec.Code, ShouldEqual, http.StatusServiceUnavailable)
+
+		So(s.Exporter.Refresh(context.TODO()), ShouldBeNil)
+		rec = httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		So(rec.Code, ShouldEqual, http.StatusOK)
+
+		body := rec.Body.String()
+		So(body, ShouldContainSubstring, `netpoltool_namespace_default_deny{namespace="NamespaceOne",policy_type="ingress"} 1`)
+		So(body, ShouldContainSubstring, `netpoltool_pods_without_ingress_policy{namespace="NamespaceTwo"} 1`)
+		So(body, ShouldContainSubstring, `netpoltool_namespaces_without_default_deny{policy_type="egress"} 2`)
+		So(body, ShouldContainSubstring, "netpoltool_evaluation_errors_total 0\n")
+		So(body, ShouldContainSubstring, "netpoltool_skipped_pods 0\n")
+	})
+
+	Convey("Skipped pods are a gauge rather than errors", t, func() {
+		pending := *snapshot
+		pending.Pods = append(append([]corev1.Pod{}, snapshot.Pods...), namespaceOne.NewPodBuilder("Pending").AddPort("api", 3000).Pod)
+		e := NewExporter(app.NewClusterApp(&pending, "NamespaceOne"))
+		So(e.Refresh(context.TODO()), ShouldBeNil)
+		So(e.Refresh(context.TODO()), ShouldBeNil)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		body := rec.Body.String()
+		So(body, ShouldContainSubstring, "netpoltool_skipped_pods 1\n")
+		So(body, ShouldContainSubstring, "netpoltool_evaluation_errors_total 0\n")
+	})
+}
+
+// noAddresses resolves every host to nothing, as a name with only records of another type can.
+type noAddresses struct{}
+
+func (noAddresses) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, nil
+}
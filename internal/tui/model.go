@@ -0,0 +1,423 @@
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	nwv1 "k8s.io/api/networking/v1"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/k8s"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// Key is a key press decoded from the terminal. Printable keys are the character itself.
+type Key string
+
+const (
+	KeyUp     Key = "up"
+	KeyDown   Key = "down"
+	KeyLeft   Key = "left"
+	KeyRight  Key = "right"
+	KeyEnter  Key = "enter"
+	KeySpace  Key = " "
+	KeyEscape Key = "esc"
+	KeyTab    Key = "tab"
+	KeyCtrlC  Key = "ctrl-c"
+)
+
+type screen int
+
+const (
+	namespacesScreen screen = iota
+	podsScreen
+	matrixScreen
+	explainScreen
+	policiesScreen
+)
+
+var screenTitles = map[screen]string{
+	namespacesScreen: "Namespaces",
+	podsScreen:       "Pods",
+	matrixScreen:     "Matrix",
+	explainScreen:    "Explain",
+	policiesScreen:   "Policies",
+}
+
+// Loader takes a fresh snapshot of the cluster.
+type Loader func(ctx context.Context) (*k8s.Snapshot, error)
+
+// Model is the state of the terminal UI. It only changes through Update and is drawn by View, so it can be driven
+// without a terminal.
+type Model struct {
+	load             Loader
+	defaultNamespace string
+	snapshot         *k8s.Snapshot
+
+	screen  screen
+	cursors map[screen]int
+	status  string
+
+	namespaces []string
+	selected   map[string]bool
+	// hidden are the pods, by namespace/name, left out of the matrix.
+	hidden map[string]bool
+	// disabled are the policies, by namespace/name, removed from evaluation.
+	disabled map[string]bool
+
+	// matrix is recomputed on the next draw after the selection or policies change.
+	matrix  *app.Matrix
+	visible []int
+	row     int
+	col     int
+	explain []string
+}
+
+// NewModel loads a snapshot and starts with namespaces selected. No namespaces means the default namespace.
+func NewModel(ctx context.Context, load Loader, defaultNamespace string, namespaces []string) (*Model, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{defaultNamespace}
+	}
+	m := &Model{
+		load:             load,
+		defaultNamespace: defaultNamespace,
+		cursors:          make(map[screen]int),
+		selected:         make(map[string]bool, len(namespaces)),
+		hidden:           make(map[string]bool),
+		disabled:         make(map[string]bool),
+	}
+	for _, ns := range namespaces {
+		m.selected[ns] = true
+	}
+	if err := m.reload(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Update applies a key press. quit is true when the UI should exit.
+func (m *Model) Update(ctx context.Context, key Key) (quit bool) {
+	m.status = ""
+	switch key {
+	case KeyCtrlC, "q":
+		return true
+	case "1":
+		m.screen = namespacesScreen
+		return false
+	case "2":
+		m.screen = podsScreen
+		return false
+	case "3":
+		m.screen = matrixScreen
+		return false
+	case "4":
+		m.screen = policiesScreen
+		return false
+	case "r":
+		if err := m.reload(ctx); err != nil {
+			m.status = err.Error()
+		} else {
+			m.status = "Reloaded"
+		}
+		return false
+	case "k":
+		key = KeyUp
+	case "j":
+		key = KeyDown
+	case "h":
+		key = KeyLeft
+	case "l":
+		key = KeyRight
+	}
+
+	switch m.screen {
+	case namespacesScreen:
+		m.updateList(key, m.namespaces, m.selected, true, podsScreen)
+	case podsScreen:
+		m.updateList(key, m.pods(), m.hidden, false, matrixScreen)
+	case policiesScreen:
+		m.updateList(key, m.policies(), m.disabled, false, matrixScreen)
+	case matrixScreen:
+		m.updateMatrix(ctx, key)
+	case explainScreen:
+		switch key {
+		case KeyUp:
+			m.moveCursor(-1, len(m.explain))
+		case KeyDown:
+			m.moveCursor(1, len(m.explain))
+		case KeyEscape, KeyEnter:
+			m.screen = matrixScreen
+		}
+	}
+	return false
+}
+
+// updateList moves the cursor of a list of toggles, where set holds the items that are on when on is true, or the
+// items that are off otherwise. Enter moves to next.
+func (m *Model) updateList(key Key, items []string, set map[string]bool, on bool, next screen) {
+	// The list may have shrunk since the cursor last moved
+	m.moveCursor(0, len(items))
+	switch key {
+	case KeyUp:
+		m.moveCursor(-1, len(items))
+	case KeyDown:
+		m.moveCursor(1, len(items))
+	case KeySpace:
+		if len(items) == 0 {
+			return
+		}
+		item := items[m.cursors[m.screen]]
+		if set[item] {
+			delete(set, item)
+		} else {
+			set[item] = true
+		}
+		m.matrix = nil
+	case KeyEnter, KeyTab:
+		m.screen = next
+	}
+}
+
+func (m *Model) updateMatrix(ctx context.Context, key Key) {
+	if m.matrix == nil {
+		m.compute(ctx)
+	}
+	switch key {
+	case KeyUp:
+		m.row = util.Max(m.row-1, 0)
+	case KeyDown:
+		m.row = util.Min(m.row+1, util.Max(len(m.visible)-1, 0))
+	case KeyLeft:
+		m.col = util.Max(m.col-1, 0)
+	case KeyRight:
+		m.col = util.Min(m.col+1, util.Max(len(m.visible)-1, 0))
+	case KeyEnter:
+		if len(m.visible) == 0 {
+			return
+		}
+		cell := m.matrix.Cells[m.visible[m.row]][m.visible[m.col]]
+		var buf bytes.Buffer
+		v := app.ConsoleView{Writer: bufio.NewWriter(&buf), Verbosity: app.DetailNotMatching}
+		fmt.Fprintf(v.Writer, "%s -> %s\n\n", cell.Source.GetName(), cell.Dest.GetName())
+		app.RenderCheckAccess(v, cell.Results, cell.Source, cell.Dest)
+		v.Flush()
+		m.explain = strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+		m.cursors[explainScreen] = 0
+		m.screen = explainScreen
+	case KeyTab:
+		m.screen = policiesScreen
+	}
+}
+
+func (m *Model) moveCursor(delta, length int) {
+	m.cursors[m.screen] = util.Max(0, util.Min(m.cursors[m.screen]+delta, length-1))
+}
+
+// reload takes a new snapshot, keeping the selections that still exist.
+func (m *Model) reload(ctx context.Context) error {
+	snapshot, err := m.load(ctx)
+	if err != nil {
+		return err
+	}
+	m.snapshot = snapshot
+	m.namespaces = make([]string, 0, len(snapshot.Namespaces))
+	for _, ns := range snapshot.Namespaces {
+		m.namespaces = append(m.namespaces, ns.Name)
+	}
+	m.matrix = nil
+	return nil
+}
+
+// compute evaluates the selected namespaces with the disabled policies removed.
+func (m *Model) compute(ctx context.Context) {
+	snapshot := m.snapshot
+	for key := range m.disabled {
+		namespace, name, _ := strings.Cut(key, "/")
+		snapshot = snapshot.WithoutNetworkPolicy(namespace, name)
+	}
+
+	// Matrix treats no namespaces as the default namespace
+	matrix := &app.Matrix{}
+	if namespaces := m.selectedNamespaces(); len(namespaces) > 0 {
+		var err error
+		matrix, err = app.NewClusterApp(snapshot, m.defaultNamespace).Matrix(ctx, namespaces)
+		if err != nil {
+			m.status = err.Error()
+			matrix = &app.Matrix{}
+		}
+	}
+	m.matrix = matrix
+	m.visible = make([]int, 0, len(matrix.Pods))
+	for i, pod := range matrix.Pods {
+		if !m.hidden[pod.GetName()] {
+			m.visible = append(m.visible, i)
+		}
+	}
+	m.row = util.Min(m.row, util.Max(len(m.visible)-1, 0))
+	m.col = util.Min(m.col, util.Max(len(m.visible)-1, 0))
+}
+
+func (m *Model) selectedNamespaces() []string {
+	return util.Filter(m.namespaces, func(ns string) bool { return m.selected[ns] })
+}
+
+// pods are the pods of the selected namespaces.
+func (m *Model) pods() []string {
+	pods := make([]string, 0)
+	for _, pod := range m.snapshot.Pods {
+		if m.selected[pod.Namespace] {
+			pods = append(pods, pod.Namespace+"/"+pod.Name)
+		}
+	}
+	return pods
+}
+
+// policies are every policy in the snapshot, since policies of other namespaces decide egress from them.
+func (m *Model) policies() []string {
+	return util.Map(m.snapshot.NetworkPolicies, func(np nwv1.NetworkPolicy) string { return np.Namespace + "/" + np.Name })
+}
+
+// View draws the current screen to fit height lines.
+func (m *Model) View(ctx context.Context, height int) string {
+	lines := make([]string, 0)
+	tabs := make([]string, 0, 4)
+	for i, s := range []screen{namespacesScreen, podsScreen, matrixScreen, policiesScreen} {
+		title := fmt.Sprintf("%d %s", i+1, screenTitles[s])
+		if s == m.screen || (s == matrixScreen && m.screen == explainScreen) {
+			title = "[" + title + "]"
+		}
+		tabs = append(tabs, title)
+	}
+	lines = append(lines, strings.Join(tabs, "  "), "")
+
+	body := make([]string, 0)
+	cursor := 0
+	switch m.screen {
+	case namespacesScreen:
+		items := m.namespaces
+		m.moveCursor(0, len(items))
+		body = renderList(items, m.selected, true, m.cursors[m.screen])
+		cursor = m.cursors[m.screen]
+	case podsScreen:
+		items := m.pods()
+		m.moveCursor(0, len(items))
+		body = renderList(items, m.hidden, false, m.cursors[m.screen])
+		cursor = m.cursors[m.screen]
+	case policiesScreen:
+		items := m.policies()
+		m.moveCursor(0, len(items))
+		body = renderList(items, m.disabled, false, m.cursors[m.screen])
+		cursor = m.cursors[m.screen]
+	case matrixScreen:
+		if m.matrix == nil {
+			m.compute(ctx)
+		}
+		body = m.renderMatrix()
+		cursor = m.row + 1
+	case explainScreen:
+		body = m.explain[m.cursors[m.screen]:]
+	}
+
+	footer := []string{"", m.help()}
+	if m.status != "" {
+		footer = append(footer, m.status)
+	}
+	lines = append(lines, window(body, cursor, height-len(lines)-len(footer))...)
+	return strings.Join(append(lines, footer...), "\n")
+}
+
+func (m *Model) help() string {
+	switch m.screen {
+	case namespacesScreen, podsScreen:
+		return "space: toggle  enter: next  r: reload  q: quit"
+	case policiesScreen:
+		return "space: enable/disable  enter: matrix  r: reload  q: quit"
+	case matrixScreen:
+		return "arrows: move  enter: explain  tab: policies  r: reload  q: quit"
+	}
+	return "up/down: scroll  esc: back  q: quit"
+}
+
+// renderMatrix draws rows of sources against numbered columns of destinations. ✓ is every port allowed, ~ some and ✗
+// none. The selected cell is bracketed.
+func (m *Model) renderMatrix() []string {
+	if len(m.visible) == 0 {
+		return []string{"No pods selected."}
+	}
+
+	nameWidth := 0
+	for _, i := range m.visible {
+		nameWidth = util.Max(nameWidth, len(m.matrix.Pods[i].GetName()))
+	}
+
+	header := fmt.Sprintf("%*s ", nameWidth+4, "")
+	for c := range m.visible {
+		header += fmt.Sprintf(" %-2d", c+1)
+	}
+	lines := []string{header}
+	for r, i := range m.visible {
+		line := fmt.Sprintf("%2d  %-*s ", r+1, nameWidth, m.matrix.Pods[i].GetName())
+		for c, j := range m.visible {
+			symbol := cellSymbol(m.matrix.Cells[i][j])
+			if r == m.row && c == m.col {
+				line += "[" + symbol + "]"
+			} else {
+				line += " " + symbol + " "
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	cell := m.matrix.Cells[m.visible[m.row]][m.visible[m.col]]
+	lines = append(lines, "", fmt.Sprintf("%s -> %s: %d of %d ports allowed",
+		cell.Source.GetName(), cell.Dest.GetName(), cell.AllowedCount(), len(cell.Results)))
+	if len(m.disabled) > 0 {
+		lines = append(lines, fmt.Sprintf("%d policies disabled", len(m.disabled)))
+	}
+	if len(m.matrix.Skipped) > 0 {
+		lines = append(lines, fmt.Sprintf("%d pods skipped", len(m.matrix.Skipped)))
+	}
+	return lines
+}
+
+func cellSymbol(e app.Evaluation) string {
+	switch allowed := e.AllowedCount(); {
+	case allowed == 0:
+		return "✗"
+	case allowed < len(e.Results):
+		return "~"
+	}
+	return "✓"
+}
+
+// renderList draws items with a checkbox that is checked when the item is in set, or when it is not and on is false.
+func renderList(items []string, set map[string]bool, on bool, cursor int) []string {
+	if len(items) == 0 {
+		return []string{"None."}
+	}
+	lines := make([]string, 0, len(items))
+	for i, item := range items {
+		check := " "
+		if set[item] == on {
+			check = "x"
+		}
+		pointer := "  "
+		if i == cursor {
+			pointer = "> "
+		}
+		lines = append(lines, fmt.Sprintf("%s[%s] %s", pointer, check, item))
+	}
+	return lines
+}
+
+// window scrolls lines so cursor stays within height.
+func window(lines []string, cursor, height int) []string {
+	if height <= 0 || len(lines) <= height {
+		return lines
+	}
+	start := util.Max(0, util.Min(cursor-height/2, len(lines)-height))
+	return lines[start : start+height]
+}
@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cheriot/netpoltool/internal/k8s"
+)
+
+func TestModel(t *testing.T) {
+	newPod := func(namespace, name, ip string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": name}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+			}}},
+			Status: corev1.PodStatus{PodIP: ip},
+		}
+	}
+	snapshot := &k8s.Snapshot{
+		Version: k8s.SnapshotVersion,
+		Namespaces: []corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "Shop"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "Other"}},
+		},
+		Pods: []corev1.Pod{
+			newPod("Shop", "Api", "10.0.0.1"),
+			newPod("Shop", "Web", "10.0.0.2"),
+			newPod("Other", "Batch", "10.0.0.3"),
+		},
+		NetworkPolicies: []nwv1.NetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "DenyWeb", Namespace: "Shop"},
+			Spec: nwv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "Web"}},
+				PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+			},
+		}},
+	}
+	load := func(ctx context.Context) (*k8s.Snapshot, error) { return snapshot, nil }
+	ctx := context.TODO()
+
+	press := func(m *Model, keys ...Key) {
+		for _, k := range keys {
+			So(m.Update(ctx, k), ShouldBeFalse)
+		}
+	}
+
+	Convey("Model", t, func() {
+		m, err := NewModel(ctx, load, "Shop", nil)
+		So(err, ShouldBeNil)
+
+		Convey("Starts with the default namespace selected", func() {
+			view := m.View(ctx, 40)
+			So(view, ShouldContainSubstring, "> [x] Shop")
+			So(view, ShouldContainSubstring, "  [ ] Other")
+		})
+
+		Convey("Shows the matrix of the selected pods", func() {
+			press(m, KeyEnter, KeyEnter)
+			So(m.View(ctx, 40), ShouldContainSubstring, " 1  Shop/Api [✓] ✗ ")
+			So(m.View(ctx, 40), ShouldContainSubstring, "Shop/Api -> Shop/Api: 1 of 1 ports allowed")
+
+			Convey("And explains the selected cell", func() {
+				press(m, KeyRight, KeyEnter)
+				view := m.View(ctx, 40)
+				So(view, ShouldContainSubstring, "Shop/Api -> Shop/Web")
+				So(view, ShouldContainSubstring, "Shop/DenyWeb")
+
+				press(m, KeyEscape)
+				So(m.View(ctx, 40), ShouldContainSubstring, "[3 Matrix]")
+			})
+
+			Convey("And recomputes when a policy is disabled", func() {
+				press(m, "4", KeySpace)
+				So(m.View(ctx, 40), ShouldContainSubstring, "> [ ] Shop/DenyWeb")
+				press(m, KeyEnter, KeyRight)
+				view := m.View(ctx, 40)
+				So(view, ShouldContainSubstring, "Shop/Api -> Shop/Web: 1 of 1 ports allowed")
+				So(view, ShouldContainSubstring, "1 policies disabled")
+			})
+		})
+
+		Convey("Hides pods and adds namespaces", func() {
+			press(m, KeyDown, KeySpace, "2", KeySpace)
+			So(m.View(ctx, 40), ShouldContainSubstring, "> [ ] Shop/Api")
+			press(m, "3")
+			view := m.View(ctx, 40)
+			So(view, ShouldNotContainSubstring, "Shop/Api")
+			So(view, ShouldContainSubstring, "Other/Batch")
+		})
+
+		Convey("Quits", func() {
+			So(m.Update(ctx, "q"), ShouldBeTrue)
+		})
+	})
+
+	Convey("DecodeKey", t, func() {
+		So(DecodeKey([]byte("\x1b[A")), ShouldEqual, KeyUp)
+		So(DecodeKey([]byte("\r")), ShouldEqual, KeyEnter)
+		So(DecodeKey([]byte("q")), ShouldEqual, Key("q"))
+	})
+}
@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	exitAltScreen  = "\x1b[?25h\x1b[?1049l"
+	clearScreen    = "\x1b[H\x1b[2J"
+)
+
+// Run draws m on the terminal and applies key presses until the user quits.
+func Run(ctx context.Context, m *Model) error {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return fmt.Errorf("ui requires a terminal")
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return fmt.Errorf("error configuring terminal: %w", err)
+	}
+	defer term.Restore(in, state)
+	fmt.Print(enterAltScreen)
+	defer fmt.Print(exitAltScreen)
+
+	// Warnings about skipped pods would draw over the screen. The matrix counts them instead.
+	util.Log.SetOutput(io.Discard)
+	defer util.Log.SetOutput(os.Stderr)
+
+	buf := make([]byte, 16)
+	for {
+		_, height, err := term.GetSize(out)
+		if err != nil {
+			height = 24
+		}
+		// Raw mode does not translate newlines to carriage return, newline
+		fmt.Print(clearScreen + strings.ReplaceAll(m.View(ctx, height), "\n", "\r\n"))
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		if m.Update(ctx, DecodeKey(buf[:n])) {
+			return nil
+		}
+	}
+}
+
+// DecodeKey translates the bytes of one key press in raw mode.
+func DecodeKey(bs []byte) Key {
+	switch string(bs) {
+	case "\x1b[A", "\x1bOA":
+		return KeyUp
+	case "\x1b[B", "\x1bOB":
+		return KeyDown
+	case "\x1b[C", "\x1bOC":
+		return KeyRight
+	case "\x1b[D", "\x1bOD":
+		return KeyLeft
+	case "\r", "\n":
+		return KeyEnter
+	case "\t":
+		return KeyTab
+	case "\x1b":
+		return KeyEscape
+	case "\x03":
+		return KeyCtrlC
+	}
+	return Key(bs)
+}
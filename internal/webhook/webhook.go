@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/app/invariant"
+	"github.com/cheriot/netpoltool/internal/k8s"
+	"github.com/cheriot/netpoltool/internal/util"
+)
+
+// maxMessages limits the violations listed in a rejection so the message stays readable in kubectl output.
+const maxMessages = 5
+
+// Handler is a validating admission webhook for NetworkPolicies. It evaluates the cluster as it would be after the
+// change and rejects changes that break an invariant. Invariants that were already broken do not block changes.
+type Handler struct {
+	app        *app.App
+	invariants []invariant.Invariant
+}
+
+func NewHandler(a *app.App, invariants []invariant.Invariant) *Handler {
+	return &Handler{
+		app:        a,
+		invariants: invariants,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("%s not allowed, use POST", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	review := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("error decoding AdmissionReview: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "AdmissionReview has no request", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = h.Review(r.Context(), review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		util.Log.Errorf("error writing AdmissionReview: %s", err.Error())
+	}
+}
+
+func (h *Handler) Review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Kind.Kind != "NetworkPolicy" {
+		return allow()
+	}
+
+	current, err := h.app.TakeSnapshot(ctx, nil, false)
+	if err != nil {
+		return deny(metav1.StatusReasonInternalError, http.StatusInternalServerError, err.Error())
+	}
+
+	var proposed *k8s.Snapshot
+	switch req.Operation {
+	case admissionv1.Create, admissionv1.Update:
+		np := nwv1.NetworkPolicy{}
+		if err := json.Unmarshal(req.Object.Raw, &np); err != nil {
+			return deny(metav1.StatusReasonBadRequest, http.StatusBadRequest, fmt.Sprintf("error decoding NetworkPolicy: %s", err.Error()))
+		}
+		if np.Namespace == "" {
+			np.Namespace = req.Namespace
+		}
+		proposed = current.WithNetworkPolicy(np)
+	case admissionv1.Delete:
+		proposed = current.WithoutNetworkPolicy(req.Namespace, req.Name)
+	default:
+		return allow()
+	}
+
+	before, err := app.NewClusterApp(current, req.Namespace).CheckInvariants(ctx, h.invariants)
+	if err != nil {
+		return deny(metav1.StatusReasonInternalError, http.StatusInternalServerError, err.Error())
+	}
+	after, err := app.NewClusterApp(proposed, req.Namespace).CheckInvariants(ctx, h.invariants)
+	if err != nil {
+		return deny(metav1.StatusReasonInternalError, http.StatusInternalServerError, err.Error())
+	}
+
+	introduced := NewViolations(before, after)
+	if len(introduced) == 0 {
+		return allow()
+	}
+
+	util.Log.Infof("Rejecting %s of NetworkPolicy %s/%s: %d invariant violations", req.Operation, req.Namespace, req.Name, len(introduced))
+	messages := make([]string, 0, maxMessages+1)
+	for i, v := range introduced {
+		if i == maxMessages {
+			messages = append(messages, fmt.Sprintf("and %d more", len(introduced)-maxMessages))
+			break
+		}
+		messages = append(messages, v.Message())
+	}
+	return deny(metav1.StatusReasonForbidden, http.StatusForbidden, strings.Join(messages, "\n"))
+}
+
+// NewViolations are the violations in after that are not also in before.
+func NewViolations(before, after []invariant.Violation) []invariant.Violation {
+	existing := make(map[string]bool, len(before))
+	for _, v := range before {
+		existing[v.Key()] = true
+	}
+	return util.Filter(after, func(v invariant.Violation) bool { return !existing[v.Key()] })
+}
+
+func allow() *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func deny(reason metav1.StatusReason, code int32, message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    code,
+			Reason:  reason,
+			Message: message,
+		},
+	}
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/cheriot/netpoltool/internal/app"
+	"github.com/cheriot/netpoltool/internal/app/invariant"
+	"github.com/cheriot/netpoltool/internal/k8s"
+	"github.com/cheriot/netpoltool/internal/k8s/builders"
+)
+
+func TestWebhook(t *testing.T) {
+	denyIngress := nwv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "default-deny", Namespace: "payments"},
+		Spec:       nwv1.NetworkPolicySpec{PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress}},
+	}
+	payments := builders.NewNamespaceBuilder("payments").AddLabel("name", "payments")
+	payments.NewPodBuilder("db").AddLabel("app", "payments-db").SetPodIP("10.0.0.1").AddPort("postgres", 5432)
+	payments.NewPodBuilder("api").AddLabel("app", "payments-api").SetPodIP("10.0.0.2").AddPort("api", 3000)
+	frontend := builders.NewNamespaceBuilder("frontend").AddLabel("name", "frontend")
+	frontend.NewPodBuilder("web").AddLabel("app", "web").SetPodIP("10.0.0.3").AddPort("api", 3000)
+	snapshot := &k8s.Snapshot{
+		Version:         k8s.SnapshotVersion,
+		Namespaces:      []corev1.Namespace{payments.Namespace, frontend.Namespace},
+		Pods:            append(payments.Pods(), frontend.Pods()...),
+		NetworkPolicies: []nwv1.NetworkPolicy{denyIngress},
+	}
+
+	invariants, err := invariant.Parse([]byte(`
+invariants:
+  - name: payments-db-isolated
+    from:
+      namespaceSelector:
+        matchExpressions: [{key: name, operator: NotIn, values: [payments]}]
+    to:
+      namespace: payments
+      podSelector: {matchLabels: {app: payments-db}}
+    expect: deny
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewHandler(app.NewClusterApp(snapshot, "default"), invariants)
+
+	review := func(operation admissionv1.Operation, np nwv1.NetworkPolicy) *admissionv1.AdmissionResponse {
+		raw, err := json.Marshal(np)
+		So(err, ShouldBeNil)
+
+		body, err := json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       "request-uid",
+				Kind:      metav1.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"},
+				Name:      np.Name,
+				Namespace: np.Namespace,
+				Operation: operation,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+		So(rec.Code, ShouldEqual, http.StatusOK)
+
+		resp := admissionv1.AdmissionReview{}
+		So(json.Unmarshal(rec.Body.Bytes(), &resp), ShouldBeNil)
+		So(resp.Response.UID, ShouldEqual, "request-uid")
+		return resp.Response
+	}
+
+	Convey("Rejects deleting the default-deny that isolates payments-db", t, func() {
+		resp := review(admissionv1.Delete, denyIngress)
+		So(resp.Allowed, ShouldBeFalse)
+		So(resp.Result.Message, ShouldContainSubstring, "invariant payments-db-isolated (expect deny) violated: frontend/web -> payments/db port 5432/TCP is allowed")
+		So(resp.Result.Message, ShouldContainSubstring, "Ingress allowed (no matching policies)")
+	})
+
+	Convey("Rejects allowing ingress from every namespace", t, func() {
+		resp := review(admissionv1.Create, nwv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-all", Namespace: "payments"},
+			Spec: nwv1.NetworkPolicySpec{
+				PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+				Ingress: []nwv1.NetworkPolicyIngressRule{{
+					From: []nwv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+				}},
+			},
+		})
+		So(resp.Allowed, ShouldBeFalse)
+		So(resp.Result.Message, ShouldContainSubstring, "Ingress allowed by payments/allow-all")
+	})
+
+	Convey("Allows ingress from within payments", t, func() {
+		resp := review(admissionv1.Create, nwv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-payments", Namespace: "payments"},
+			Spec: nwv1.NetworkPolicySpec{
+				PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress},
+				Ingress: []nwv1.NetworkPolicyIngressRule{{
+					From: []nwv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+				}},
+			},
+		})
+		So(resp.Allowed, ShouldBeTrue)
+	})
+
+	Convey("Allows changes that do not touch NetworkPolicies", t, func() {
+		resp := handler.Review(context.TODO(), &admissionv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Kind: "Pod"}})
+		So(resp.Allowed, ShouldBeTrue)
+	})
+}